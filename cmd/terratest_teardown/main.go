@@ -0,0 +1,115 @@
+// A CLI command to destroy the terraform modules that tests recorded in a teardown ledger, but never destroyed (e.g.,
+// because the test was killed by go test -timeout, or crashed). See test_structure.TeardownLedger.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/entrypoint"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/urfave/cli"
+)
+
+const CustomUsageText = `Usage: terratest_teardown [OPTIONS]
+
+This tool runs terraform destroy for each module that tests recorded in a teardown ledger and did not destroy, from the most recently recorded to the least recently recorded, and removes the modules it destroys from the ledger.
+
+Options:
+
+  --ledger-dir DIR        The folder of the teardown ledger. Defaults to the TERRATEST_TEARDOWN_LEDGER environment
+                          variable, or terratest-teardown-ledger in the temp folder.
+  --older-than DURATION   Required. Only destroy the modules recorded more than DURATION ago (e.g., 2h), so that the
+                          modules of tests that are still running are left alone. Use a DURATION longer than the
+                          -timeout of the tests that record to the ledger, or 0s to destroy every module.
+  --dry-run               List the modules that would be destroyed, without destroying them.
+  --help                  Show this help text and exit.
+
+Example:
+
+  terratest_teardown --ledger-dir /var/lib/terratest-ledger --older-than 3h
+`
+
+func run(cliContext *cli.Context) error {
+	// Destroying the modules of tests that are still running would break those tests, so the age is never implied.
+	if !cliContext.IsSet("older-than") {
+		return errors.New("The --older-than flag is required. Use --older-than 0s to destroy every module in the ledger.")
+	}
+	ledger := test_structure.NewTeardownLedger(cliContext.String("ledger-dir"))
+	olderThan := cliContext.Duration("older-than")
+
+	// Create mock testing.T implementation so we can re-use Terratest methods
+	t := MockTestingT{MockName: "terratest_teardown"}
+
+	if !cliContext.Bool("dry-run") {
+		return ledger.ReplayE(t, olderThan)
+	}
+
+	entries, err := ledger.EntriesE(t)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if time.Since(entry.RecordedAt) < olderThan {
+			continue
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", entry.ID, entry.RecordedAt.Format(time.RFC3339), entry.TestName, entry.TerraformDir)
+	}
+	return nil
+}
+
+func main() {
+	app := entrypoint.NewApp()
+	cli.AppHelpTemplate = CustomUsageText
+	entrypoint.HelpTextLineWidth = 120
+
+	app.Name = "terratest_teardown"
+	app.Author = "Gruntwork <www.gruntwork.io>"
+	app.Description = `This tool destroys the terraform modules that tests recorded in a teardown ledger, but never destroyed.`
+	app.Action = run
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "ledger-dir",
+			Value: test_structure.DefaultTeardownLedgerDir(),
+			Usage: "The folder of the teardown ledger.",
+		},
+		cli.DurationFlag{
+			Name:  "older-than",
+			Usage: "Required. Only destroy the modules recorded more than `DURATION` ago.",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "List the modules that would be destroyed, without destroying them.",
+		},
+	}
+
+	entrypoint.RunApp(app)
+}
+
+// MockTestingT is a mock implementation of testing.TestingT. Failures are reported by the error returning versions of
+// the Terratest methods this tool uses, so the only failures reported through the mock are fatal ones (e.g., a ledger
+// entry that can't be parsed), which exit the tool.
+type MockTestingT struct {
+	MockName string
+}
+
+func (t MockTestingT) Fail()    {}
+func (t MockTestingT) FailNow() { os.Exit(1) }
+func (t MockTestingT) Fatal(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+	os.Exit(1)
+}
+func (t MockTestingT) Fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+func (t MockTestingT) Error(args ...interface{}) { fmt.Fprintln(os.Stderr, args...) }
+func (t MockTestingT) Errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+func (t MockTestingT) Name() string {
+	return t.MockName
+}
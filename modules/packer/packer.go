@@ -32,11 +32,21 @@ type Options struct {
 	RetryableErrors            map[string]string // If packer build fails with one of these (transient) errors, retry. The keys are a regexp to match against the error and the message is what to display to a user if that error is matched.
 	MaxRetries                 int               // Maximum number of times to retry errors matching RetryableErrors
 	TimeBetweenRetries         time.Duration     // The amount of time to wait between retries
+	RetryPolicy                *retry.Policy     // How to retry errors matching RetryableErrors (e.g., with exponential backoff). If set, MaxRetries and TimeBetweenRetries are ignored. See retry.Policy.
 	WorkingDir                 string            // The directory to run packer in
 	Logger                     *logger.Logger    // If set, use a non-default logger
 	DisableTemporaryPluginPath bool              // If set, do not use a temporary directory for Packer plugins.
 }
 
+// doWithRetryableErrorsE runs the given action, retrying the errors matching RetryableErrors with RetryPolicy if set, or
+// with MaxRetries and TimeBetweenRetries, as retry.DoWithRetryableErrorsE does, otherwise.
+func (options *Options) doWithRetryableErrorsE(t testing.TestingT, description string, action func() (string, error)) (string, error) {
+	if options.RetryPolicy != nil {
+		return retry.DoWithRetryableErrorsPolicyE(t, description, options.RetryableErrors, *options.RetryPolicy, action)
+	}
+	return retry.DoWithRetryableErrorsE(t, description, options.RetryableErrors, options.MaxRetries, options.TimeBetweenRetries, action)
+}
+
 // BuildArtifacts can take a map of identifierName <-> Options and then parallelize
 // the packer builds. Once all the packer builds have completed a map of identifierName <-> generated identifier
 // is returned. The identifierName can be anything you want, it is only used so that you can
@@ -128,7 +138,7 @@ func BuildArtifactE(t testing.TestingT, options *Options) (string, error) {
 	}
 
 	description := fmt.Sprintf("%s %v", cmd.Command, cmd.Args)
-	output, err := retry.DoWithRetryableErrorsE(t, description, options.RetryableErrors, options.MaxRetries, options.TimeBetweenRetries, func() (string, error) {
+	output, err := options.doWithRetryableErrorsE(t, description, func() (string, error) {
 		return shell.RunCommandAndGetOutputE(t, cmd)
 	})
 
@@ -228,7 +238,7 @@ func packerInit(t testing.TestingT, options *Options) error {
 	}
 
 	description := "Running Packer init"
-	_, err = retry.DoWithRetryableErrorsE(t, description, options.RetryableErrors, options.MaxRetries, options.TimeBetweenRetries, func() (string, error) {
+	_, err = options.doWithRetryableErrorsE(t, description, func() (string, error) {
 		return shell.RunCommandAndGetOutputE(t, cmd)
 	})
 
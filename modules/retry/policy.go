@@ -0,0 +1,254 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Policy configures how the DoWithPolicy functions retry an action: how many times, how long to sleep between attempts,
+// and when to give up.
+//
+// The sleep before retry n (starting at 1) is InitialInterval * Multiplier^(n-1), capped at MaxInterval. With Jitter,
+// a random duration between 0 and that sleep is used instead ("full jitter"), so that tests retrying the same flaky
+// API don't all retry at the same time.
+//
+// The retries stop once MaxRetries is exceeded, once the next attempt would start after MaxElapsedTime, or once
+// Context is done. If the test has a deadline (e.g., go test -timeout), the retries also stop if the next attempt
+// would start after it, so that the test fails with a retry error and runs its cleanup instead of being killed.
+type Policy struct {
+	MaxRetries      int           // Maximum number of retries. If negative, retry until MaxElapsedTime, Context or the test deadline stops it.
+	InitialInterval time.Duration // The amount of time to sleep before the first retry
+	Multiplier      float64       // The factor the sleep is multiplied by after each retry. Defaults to 1 (a constant sleep).
+	MaxInterval     time.Duration // The maximum amount of time to sleep between retries. Defaults to no maximum.
+	Jitter          bool          // Sleep a random duration between 0 and the computed sleep
+	MaxElapsedTime  time.Duration // Give up if the next attempt would start more than this long after the first one. Defaults to no limit.
+
+	// Stop retrying, and return a Canceled error, once this context is done. Defaults to the test deadline.
+	Context context.Context `json:"-"`
+
+	// If set, called after each attempt (e.g., to log attempts or collect metrics), in addition to the usual logging.
+	OnAttempt func(attempt Attempt) `json:"-"`
+
+	// Don't stop at the test deadline. This is only set by the DoWithRetry functions, which predate the deadline
+	// support and keep retrying until maxRetries is exceeded.
+	ignoreTestDeadline bool
+}
+
+// Attempt describes an attempt of an action retried with a Policy, for Policy.OnAttempt.
+type Attempt struct {
+	Description string        // The description of the action
+	Number      int           // The number of the attempt, starting at 1
+	Err         error         // The error the attempt returned, if any
+	Elapsed     time.Duration // The time elapsed since the first attempt started
+	Sleep       time.Duration // The amount of time to sleep before the next attempt, or 0 if there won't be one
+}
+
+// NewPolicy returns a Policy that sleeps for sleepBetweenRetries between retries, up to a maximum of maxRetries
+// retries, like DoWithRetry. As the retries should be limited, a negative maxRetries is treated as 0 rather than as
+// retrying without limit. Unlike DoWithRetry, the DoWithPolicy functions stop at the test deadline.
+func NewPolicy(maxRetries int, sleepBetweenRetries time.Duration) Policy {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return Policy{MaxRetries: maxRetries, InitialInterval: sleepBetweenRetries}
+}
+
+// ExponentialBackoff returns a Policy that doubles the sleep between retries, from initialInterval up to maxInterval,
+// with full jitter, up to a maximum of maxRetries retries.
+func ExponentialBackoff(maxRetries int, initialInterval time.Duration, maxInterval time.Duration) Policy {
+	return Policy{
+		MaxRetries:      maxRetries,
+		InitialInterval: initialInterval,
+		Multiplier:      2,
+		MaxInterval:     maxInterval,
+		Jitter:          true,
+	}
+}
+
+// Interval returns the amount of time to sleep before the given retry (starting at 1), before jitter is applied.
+func (policy Policy) Interval(retry int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	interval := float64(policy.InitialInterval) * math.Pow(multiplier, float64(retry-1))
+	if policy.MaxInterval > 0 && interval > float64(policy.MaxInterval) {
+		return policy.MaxInterval
+	}
+	if interval > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(interval)
+}
+
+// sleepBeforeRetry returns the amount of time to sleep before the given retry, with jitter applied.
+func (policy Policy) sleepBeforeRetry(retry int) time.Duration {
+	interval := policy.Interval(retry)
+	if policy.Jitter && interval > 0 {
+		return time.Duration(rand.Int63n(int64(interval) + 1))
+	}
+	return interval
+}
+
+// context returns the context of the policy, with the deadline of the given test, if it has one. testing.TestingT
+// does not include Deadline, but Golang's native testing.T has it.
+func (policy Policy) context(t testing.TestingT) (context.Context, context.CancelFunc) {
+	ctx := policy.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if withDeadline, ok := t.(interface{ Deadline() (time.Time, bool) }); ok && !policy.ignoreTestDeadline {
+		if deadline, hasDeadline := withDeadline.Deadline(); hasDeadline {
+			return context.WithDeadline(ctx, deadline)
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// DoWithPolicy runs the specified action. If it returns a string, return that string. If it returns a FatalError,
+// return that error immediately. If it returns any other type of error, retry it as configured by the given policy. If
+// the policy gives up, fail the test.
+func DoWithPolicy(t testing.TestingT, actionDescription string, policy Policy, action func() (string, error)) string {
+	out, err := DoWithPolicyE(t, actionDescription, policy, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// DoWithPolicyE runs the specified action. If it returns a string, return that string. If it returns a FatalError,
+// return that error immediately. If it returns any other type of error, retry it as configured by the given policy. If
+// the policy gives up, return a MaxRetriesExceeded, TimeoutExceeded or Canceled error.
+func DoWithPolicyE(t testing.TestingT, actionDescription string, policy Policy, action func() (string, error)) (string, error) {
+	out, err := DoWithPolicyInterfaceE(t, actionDescription, policy, func() (interface{}, error) { return action() })
+	if out == nil {
+		return "", err
+	}
+	return out.(string), err
+}
+
+// DoWithPolicyInterface runs the specified action. If it returns a value, return that value. If it returns a
+// FatalError, return that error immediately. If it returns any other type of error, retry it as configured by the
+// given policy. If the policy gives up, fail the test.
+func DoWithPolicyInterface(t testing.TestingT, actionDescription string, policy Policy, action func() (interface{}, error)) interface{} {
+	out, err := DoWithPolicyInterfaceE(t, actionDescription, policy, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// DoWithPolicyInterfaceE runs the specified action. If it returns a value, return that value. If it returns a
+// FatalError, return that error immediately. If it returns any other type of error, retry it as configured by the
+// given policy. If the policy gives up, return a MaxRetriesExceeded, TimeoutExceeded or Canceled error.
+func DoWithPolicyInterfaceE(t testing.TestingT, actionDescription string, policy Policy, action func() (interface{}, error)) (interface{}, error) {
+	ctx, cancel := policy.context(t)
+	defer cancel()
+
+	var output interface{}
+	var err error
+	start := time.Now()
+
+	for number := 1; ; number++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return output, Canceled{Description: actionDescription, Underlying: ctxErr}
+		}
+
+		logger.Log(t, actionDescription)
+
+		output, err = action()
+		attempt := Attempt{Description: actionDescription, Number: number, Err: err, Elapsed: time.Since(start)}
+		if err == nil {
+			policy.onAttempt(attempt)
+			return output, nil
+		}
+
+		if _, isFatalErr := err.(FatalError); isFatalErr {
+			policy.onAttempt(attempt)
+			logger.Logf(t, "Returning due to fatal error: %v", err)
+			return output, err
+		}
+
+		// The number of retries so far is one less than the number of attempts.
+		if policy.MaxRetries >= 0 && number > policy.MaxRetries {
+			policy.onAttempt(attempt)
+			return output, MaxRetriesExceeded{Description: actionDescription, MaxRetries: policy.MaxRetries}
+		}
+
+		sleep := policy.sleepBeforeRetry(number)
+		if policy.MaxElapsedTime > 0 && attempt.Elapsed+sleep > policy.MaxElapsedTime {
+			policy.onAttempt(attempt)
+			return output, TimeoutExceeded{Description: actionDescription, Timeout: policy.MaxElapsedTime}
+		}
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Now().Add(sleep).After(deadline) {
+			policy.onAttempt(attempt)
+			return output, Canceled{Description: actionDescription, Underlying: context.DeadlineExceeded}
+		}
+
+		attempt.Sleep = sleep
+		policy.onAttempt(attempt)
+		logger.Logf(t, "%s returned an error: %s. Sleeping for %s and will try again.", actionDescription, err.Error(), sleep)
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return output, Canceled{Description: actionDescription, Underlying: ctx.Err()}
+		}
+	}
+}
+
+func (policy Policy) onAttempt(attempt Attempt) {
+	if policy.OnAttempt != nil {
+		policy.OnAttempt(attempt)
+	}
+}
+
+// DoWithRetryableErrorsPolicy runs the specified action. If it returns a value, return that value. If it returns an
+// error, check if error message or the string output from the action (which is often stdout/stderr from running some
+// command) matches any of the regular expressions in the specified retryableErrors map. If there is a match, retry the
+// specified action as configured by the given policy. If there is no match, fail the test. If the policy gives up,
+// fail the test.
+func DoWithRetryableErrorsPolicy(t testing.TestingT, actionDescription string, retryableErrors map[string]string, policy Policy, action func() (string, error)) string {
+	out, err := DoWithRetryableErrorsPolicyE(t, actionDescription, retryableErrors, policy, action)
+	require.NoError(t, err)
+	return out
+}
+
+// DoWithRetryableErrorsPolicyE runs the specified action. If it returns a value, return that value. If it returns an
+// error, check if error message or the string output from the action (which is often stdout/stderr from running some
+// command) matches any of the regular expressions in the specified retryableErrors map. If there is a match, retry the
+// specified action as configured by the given policy. If there is no match, return that error immediately, wrapped in
+// a FatalError. If the policy gives up, return a MaxRetriesExceeded, TimeoutExceeded or Canceled error.
+func DoWithRetryableErrorsPolicyE(t testing.TestingT, actionDescription string, retryableErrors map[string]string, policy Policy, action func() (string, error)) (string, error) {
+	retryableAction, err := retryOnlyRetryableErrors(t, actionDescription, retryableErrors, action)
+	if err != nil {
+		return "", err
+	}
+	return DoWithPolicyE(t, actionDescription, policy, retryableAction)
+}
+
+// Canceled is an error that occurs when the context of a Policy is done (e.g., the test deadline is reached) before
+// an action succeeds.
+type Canceled struct {
+	Description string
+	Underlying  error
+}
+
+func (err Canceled) Error() string {
+	return fmt.Sprintf("'%s' canceled before it succeeded: %v", err.Description, err.Underlying)
+}
+
+// Unwrap returns the error of the context (e.g., context.DeadlineExceeded), so that errors.Is can inspect it.
+func (err Canceled) Unwrap() error {
+	return err.Underlying
+}
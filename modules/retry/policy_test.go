@@ -0,0 +1,153 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyInterval(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: 5 * time.Second}
+	assert.Equal(t, time.Second, policy.Interval(1))
+	assert.Equal(t, 2*time.Second, policy.Interval(2))
+	assert.Equal(t, 4*time.Second, policy.Interval(3))
+	assert.Equal(t, 5*time.Second, policy.Interval(4))
+	assert.Equal(t, 5*time.Second, policy.Interval(100))
+
+	constant := NewPolicy(3, time.Second)
+	assert.Equal(t, time.Second, constant.Interval(1))
+	assert.Equal(t, time.Second, constant.Interval(3))
+
+	jittered := ExponentialBackoff(3, time.Second, 10*time.Second)
+	for retry := 1; retry <= 5; retry++ {
+		sleep := jittered.sleepBeforeRetry(retry)
+		assert.GreaterOrEqual(t, sleep, time.Duration(0))
+		assert.LessOrEqual(t, sleep, jittered.Interval(retry))
+	}
+}
+
+func TestDoWithPolicyCallsOnAttempt(t *testing.T) {
+	t.Parallel()
+
+	expectedError := fmt.Errorf("expected error")
+	var attempts []Attempt
+	policy := Policy{MaxRetries: 5, InitialInterval: time.Millisecond, Multiplier: 2, OnAttempt: func(attempt Attempt) {
+		attempts = append(attempts, attempt)
+	}}
+
+	count := 0
+	out, err := DoWithPolicyE(t, "flaky", policy, func() (string, error) {
+		count++
+		if count < 3 {
+			return "", expectedError
+		}
+		return "done", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", out)
+
+	require.Len(t, attempts, 3)
+	assert.Equal(t, 1, attempts[0].Number)
+	assert.Equal(t, expectedError, attempts[0].Err)
+	assert.Equal(t, time.Millisecond, attempts[0].Sleep)
+	assert.Equal(t, 2*time.Millisecond, attempts[1].Sleep)
+	assert.NoError(t, attempts[2].Err)
+	assert.Equal(t, time.Duration(0), attempts[2].Sleep)
+}
+
+func TestDoWithPolicyGivesUp(t *testing.T) {
+	t.Parallel()
+
+	alwaysFails := func() (string, error) { return "", fmt.Errorf("expected error") }
+
+	_, err := DoWithPolicyE(t, "max retries", NewPolicy(2, time.Millisecond), alwaysFails)
+	assert.Equal(t, MaxRetriesExceeded{Description: "max retries", MaxRetries: 2}, err)
+
+	_, err = DoWithPolicyE(t, "max elapsed time", Policy{MaxRetries: -1, InitialInterval: 10 * time.Millisecond, MaxElapsedTime: 50 * time.Millisecond}, alwaysFails)
+	assert.Equal(t, TimeoutExceeded{Description: "max elapsed time", Timeout: 50 * time.Millisecond}, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	_, err = DoWithPolicyE(t, "canceled", Policy{MaxRetries: -1, InitialInterval: 10 * time.Second, Context: ctx}, func() (string, error) {
+		attempts++
+		go cancel()
+		return "", fmt.Errorf("expected error")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+// deadlineT is a testing.T with the given deadline.
+type deadlineT struct {
+	*testing.T
+	deadline time.Time
+}
+
+func (t deadlineT) Deadline() (time.Time, bool) {
+	return t.deadline, true
+}
+
+func TestDoWithPolicyStopsBeforeTestDeadline(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	start := time.Now()
+	_, err := DoWithPolicyE(deadlineT{T: t, deadline: time.Now().Add(time.Minute)}, "deadline", NewPolicy(3, time.Hour), func() (string, error) {
+		attempts++
+		return "", fmt.Errorf("expected error")
+	})
+
+	var canceled Canceled
+	require.True(t, errors.As(err, &canceled))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Minute)
+}
+
+func TestDoWithRetryIgnoresTestDeadline(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	_, err := DoWithRetryE(deadlineT{T: t, deadline: time.Now().Add(-time.Second)}, "legacy", 2, 0, func() (string, error) {
+		attempts++
+		return "", fmt.Errorf("expected error")
+	})
+	assert.Equal(t, MaxRetriesExceeded{Description: "legacy", MaxRetries: 2}, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, err = DoWithRetryE(t, "legacy", -1, 0, func() (string, error) {
+		attempts++
+		return "", fmt.Errorf("expected error")
+	})
+	assert.Equal(t, MaxRetriesExceeded{Description: "legacy", MaxRetries: 0}, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDoWithRetryableErrorsPolicy(t *testing.T) {
+	t.Parallel()
+
+	count := 0
+	out, err := DoWithRetryableErrorsPolicyE(t, "retryable", map[string]string{"throttled": "API throttling"}, ExponentialBackoff(3, time.Millisecond, 5*time.Millisecond), func() (string, error) {
+		count++
+		if count < 3 {
+			return "", fmt.Errorf("request throttled")
+		}
+		return "done", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", out)
+
+	underlying := fmt.Errorf("access denied")
+	_, err = DoWithRetryableErrorsPolicyE(t, "not retryable", map[string]string{"throttled": "API throttling"}, NewPolicy(3, time.Millisecond), func() (string, error) {
+		return "", underlying
+	})
+	assert.Equal(t, FatalError{Underlying: underlying}, err)
+}
@@ -65,8 +65,7 @@ func DoWithRetry(t testing.TestingT, actionDescription string, maxRetries int, s
 // immediately. If it returns any other type of error, sleep for sleepBetweenRetries and try again, up to a maximum of
 // maxRetries retries. If maxRetries is exceeded, return a MaxRetriesExceeded error.
 func DoWithRetryE(t testing.TestingT, actionDescription string, maxRetries int, sleepBetweenRetries time.Duration, action func() (string, error)) (string, error) {
-	out, err := DoWithRetryInterfaceE(t, actionDescription, maxRetries, sleepBetweenRetries, func() (interface{}, error) { return action() })
-	return out.(string), err
+	return DoWithPolicyE(t, actionDescription, retryPolicy(maxRetries, sleepBetweenRetries), action)
 }
 
 // DoWithRetryInterface runs the specified action. If it returns a value, return that value. If it returns a FatalError, return that error
@@ -84,27 +83,15 @@ func DoWithRetryInterface(t testing.TestingT, actionDescription string, maxRetri
 // immediately. If it returns any other type of error, sleep for sleepBetweenRetries and try again, up to a maximum of
 // maxRetries retries. If maxRetries is exceeded, return a MaxRetriesExceeded error.
 func DoWithRetryInterfaceE(t testing.TestingT, actionDescription string, maxRetries int, sleepBetweenRetries time.Duration, action func() (interface{}, error)) (interface{}, error) {
-	var output interface{}
-	var err error
-
-	for i := 0; i <= maxRetries; i++ {
-		logger.Log(t, actionDescription)
-
-		output, err = action()
-		if err == nil {
-			return output, nil
-		}
-
-		if _, isFatalErr := err.(FatalError); isFatalErr {
-			logger.Logf(t, "Returning due to fatal error: %v", err)
-			return output, err
-		}
-
-		logger.Logf(t, "%s returned an error: %s. Sleeping for %s and will try again.", actionDescription, err.Error(), sleepBetweenRetries)
-		time.Sleep(sleepBetweenRetries)
-	}
+	return DoWithPolicyInterfaceE(t, actionDescription, retryPolicy(maxRetries, sleepBetweenRetries), action)
+}
 
-	return output, MaxRetriesExceeded{Description: actionDescription, MaxRetries: maxRetries}
+// retryPolicy returns the policy the DoWithRetry functions retry with: the one NewPolicy returns, except that it keeps
+// retrying past the test deadline, as these functions always have.
+func retryPolicy(maxRetries int, sleepBetweenRetries time.Duration) Policy {
+	policy := NewPolicy(maxRetries, sleepBetweenRetries)
+	policy.ignoreTestDeadline = true
+	return policy
 }
 
 // DoWithRetryableErrors runs the specified action. If it returns a value, return that value. If it returns an error,
@@ -124,16 +111,22 @@ func DoWithRetryableErrors(t testing.TestingT, actionDescription string, retryab
 // sleepBetweenRetries, and retry the specified action, up to a maximum of maxRetries retries. If there is no match,
 // return that error immediately, wrapped in a FatalError. If maxRetries is exceeded, return a MaxRetriesExceeded error.
 func DoWithRetryableErrorsE(t testing.TestingT, actionDescription string, retryableErrors map[string]string, maxRetries int, sleepBetweenRetries time.Duration, action func() (string, error)) (string, error) {
+	return DoWithRetryableErrorsPolicyE(t, actionDescription, retryableErrors, retryPolicy(maxRetries, sleepBetweenRetries), action)
+}
+
+// retryOnlyRetryableErrors wraps the given action so that the errors that don't match any of the regular expressions
+// in the specified retryableErrors map are returned wrapped in a FatalError, which stops the retries.
+func retryOnlyRetryableErrors(t testing.TestingT, actionDescription string, retryableErrors map[string]string, action func() (string, error)) (func() (string, error), error) {
 	retryableErrorsRegexp := map[*regexp.Regexp]string{}
 	for errorStr, errorMessage := range retryableErrors {
 		errorRegex, err := regexp.Compile(errorStr)
 		if err != nil {
-			return "", FatalError{Underlying: err}
+			return nil, FatalError{Underlying: err}
 		}
 		retryableErrorsRegexp[errorRegex] = errorMessage
 	}
 
-	return DoWithRetryE(t, actionDescription, maxRetries, sleepBetweenRetries, func() (string, error) {
+	return func() (string, error) {
 		output, err := action()
 		if err == nil {
 			return output, nil
@@ -147,7 +140,7 @@ func DoWithRetryableErrorsE(t testing.TestingT, actionDescription string, retrya
 		}
 
 		return output, FatalError{Underlying: err}
-	})
+	}, nil
 }
 
 // Done can be stopped.
@@ -216,3 +209,8 @@ type FatalError struct {
 func (err FatalError) Error() string {
 	return fmt.Sprintf("FatalError{Underlying: %v}", err.Underlying)
 }
+
+// Unwrap returns the underlying error, so that errors.Is and errors.As can inspect it.
+func (err FatalError) Unwrap() error {
+	return err.Underlying
+}
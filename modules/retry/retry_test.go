@@ -261,3 +261,12 @@ type ErrorCounter int
 func (count ErrorCounter) Error() string {
 	return fmt.Sprintf("%d", int(count))
 }
+
+func TestFatalErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	underlying := fmt.Errorf("underlying error")
+	_, err := DoWithRetryableErrorsE(t, "fails", map[string]string{"no match": ""}, 3, time.Millisecond, func() (string, error) { return "", underlying })
+
+	assert.ErrorIs(t, err, underlying)
+}
@@ -1,7 +1,8 @@
 package terraform
 
 import (
-	"errors"
+	"io/ioutil"
+	"os"
 
 	"github.com/gruntwork-io/terratest/modules/testing"
 	"github.com/stretchr/testify/require"
@@ -16,9 +17,10 @@ func InitAndApply(t testing.TestingT, options *Options) string {
 	return out
 }
 
-// InitAndApplyE runs terraform init and apply with the given options and return stdout/stderr from the apply command. Note that this
-// method does NOT call destroy and assumes the caller is responsible for cleaning up any resources created by running
-// apply.
+// InitAndApplyE runs terraform init and apply with the given options and return stdout/stderr from the apply command. If
+// a PlanGuard is set on the options, the plan is checked against it first, and a PlanGuardViolations error is returned
+// without applying if the plan violates it. Note that this method does NOT call destroy and assumes the caller is
+// responsible for cleaning up any resources created by running apply.
 func InitAndApplyE(t testing.TestingT, options *Options) (string, error) {
 	if _, err := InitE(t, options); err != nil {
 		return "", err
@@ -43,25 +45,36 @@ func TgApplyAll(t testing.TestingT, options *Options) string {
 	return out
 }
 
-// ApplyE runs terraform apply with the given options and return stdout/stderr. Note that this method does NOT call destroy and
-// assumes the caller is responsible for cleaning up any resources created by running apply.
+// ApplyE runs terraform apply with the given options and return stdout/stderr. If a PlanGuard is set on the options,
+// the plan is checked against it first, and a PlanGuardViolations error is returned without applying if the plan
+// violates it. Note that this method does NOT call destroy and assumes the caller is responsible for cleaning up any
+// resources created by running apply.
 func ApplyE(t testing.TestingT, options *Options) (string, error) {
+	if options.PlanGuard != nil {
+		return applyWithPlanGuardE(t, options)
+	}
 	return RunTerraformCommandE(t, options, FormatArgs(options, "apply", "-input=false", "-auto-approve")...)
 }
 
-// TgApplyAllE runs terragrunt apply-all with the given options and return stdout/stderr. Note that this method does NOT call destroy and
-// assumes the caller is responsible for cleaning up any resources created by running apply.
+// TgApplyAllE runs terragrunt apply-all with the given options and return stdout/stderr. A PlanGuard can't be checked
+// before run-all applies each unit, so this returns a PlanGuardNotSupported error if one is set. Note that this method
+// does NOT call destroy and assumes the caller is responsible for cleaning up any resources created by running apply.
 func TgApplyAllE(t testing.TestingT, options *Options) (string, error) {
 	if options.TerraformBinary != "terragrunt" {
 		return "", TgInvalidBinary(options.TerraformBinary)
 	}
+	if options.PlanGuard != nil {
+		return "", PlanGuardNotSupported("TgApplyAllE")
+	}
 
 	return RunTerraformCommandE(t, options, FormatArgs(options, "run-all", "apply", "-input=false", "-auto-approve")...)
 }
 
 // ApplyAndIdempotent runs terraform apply with the given options and return stdout/stderr from the apply command. It then runs
-// plan again and will fail the test if plan requires additional changes. Note that this method does NOT call destroy and assumes
-// the caller is responsible for cleaning up any resources created by running apply.
+// plan again and will fail the test if plan requires additional changes, other than those allowed by the
+// IdempotencyExceptions of the options. The error (NotIdempotent) reports which resources and attributes would change,
+// and why. Note that this method does NOT call destroy and assumes the caller is responsible for cleaning up any
+// resources created by running apply.
 func ApplyAndIdempotent(t testing.TestingT, options *Options) string {
 	out, err := ApplyAndIdempotentE(t, options)
 	require.NoError(t, err)
@@ -70,8 +83,10 @@ func ApplyAndIdempotent(t testing.TestingT, options *Options) string {
 }
 
 // ApplyAndIdempotentE runs terraform apply with the given options and return stdout/stderr from the apply command. It then runs
-// plan again and will fail the test if plan requires additional changes. Note that this method does NOT call destroy and assumes
-// the caller is responsible for cleaning up any resources created by running apply.
+// plan again and will fail the test if plan requires additional changes, other than those allowed by the
+// IdempotencyExceptions of the options. The error (NotIdempotent) reports which resources and attributes would change,
+// and why. Note that this method does NOT call destroy and assumes the caller is responsible for cleaning up any
+// resources created by running apply.
 func ApplyAndIdempotentE(t testing.TestingT, options *Options) (string, error) {
 	out, err := ApplyE(t, options)
 
@@ -79,22 +94,47 @@ func ApplyAndIdempotentE(t testing.TestingT, options *Options) (string, error) {
 		return out, err
 	}
 
-	exitCode, err := PlanExitCodeE(t, options)
-
+	plan, err := planAfterApplyE(t, options)
 	if err != nil {
 		return out, err
 	}
 
-	if exitCode != 0 {
-		return out, errors.New("terraform configuration not idempotent")
+	if changes := GetIdempotencyChanges(plan, options.IdempotencyExceptions); len(changes) > 0 {
+		return out, NotIdempotent(changes)
 	}
 
 	return out, nil
 }
 
+// planAfterApplyE runs terraform plan and then terraform show with the given options, and parses the json result into
+// a go struct. The plan is saved to a temporary file, as PlanFilePath may hold the plan that was just applied.
+func planAfterApplyE(t testing.TestingT, options *Options) (*PlanStruct, error) {
+	planOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpFile, err := ioutil.TempFile("", "terratest-idempotency-plan-file-")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile.Name())
+	planOptions.PlanFilePath = tmpFile.Name()
+
+	if _, err := PlanE(t, planOptions); err != nil {
+		return nil, err
+	}
+	return ShowWithStructE(t, planOptions)
+}
+
 // InitAndApplyAndIdempotent runs terraform init and apply with the given options and return stdout/stderr from the apply command. It then runs
-// plan again and will fail the test if plan requires additional changes. Note that this method does NOT call destroy and assumes
-// the caller is responsible for cleaning up any resources created by running apply.
+// plan again and will fail the test if plan requires additional changes, other than those allowed by the
+// IdempotencyExceptions of the options. The error (NotIdempotent) reports which resources and attributes would change,
+// and why. Note that this method does NOT call destroy and assumes the caller is responsible for cleaning up any
+// resources created by running apply.
 func InitAndApplyAndIdempotent(t testing.TestingT, options *Options) string {
 	out, err := InitAndApplyAndIdempotentE(t, options)
 	require.NoError(t, err)
@@ -103,8 +143,10 @@ func InitAndApplyAndIdempotent(t testing.TestingT, options *Options) string {
 }
 
 // InitAndApplyAndIdempotentE runs terraform init and apply with the given options and return stdout/stderr from the apply command. It then runs
-// plan again and will fail the test if plan requires additional changes. Note that this method does NOT call destroy and assumes
-// the caller is responsible for cleaning up any resources created by running apply.
+// plan again and will fail the test if plan requires additional changes, other than those allowed by the
+// IdempotencyExceptions of the options. The error (NotIdempotent) reports which resources and attributes would change,
+// and why. Note that this method does NOT call destroy and assumes the caller is responsible for cleaning up any
+// resources created by running apply.
 func InitAndApplyAndIdempotentE(t testing.TestingT, options *Options) (string, error) {
 	if _, err := InitE(t, options); err != nil {
 		return "", err
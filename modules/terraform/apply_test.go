@@ -151,7 +151,33 @@ func TestIdempotentWithChanges(t *testing.T) {
 
 	require.NotEmpty(t, out)
 	require.Error(t, err)
-	require.EqualError(t, err, "terraform configuration not idempotent")
+	require.ErrorContains(t, err, "terraform configuration not idempotent")
+
+	var notIdempotent NotIdempotent
+	require.ErrorAs(t, err, &notIdempotent)
+	require.Len(t, notIdempotent, 1)
+	assert.Equal(t, "null_resource.test", notIdempotent[0].Address)
+	assert.Equal(t, IdempotencyCausePerpetualDiff, notIdempotent[0].Cause)
+	var paths []string
+	for _, change := range notIdempotent[0].AttributeChanges {
+		paths = append(paths, change.Path)
+	}
+	assert.Contains(t, paths, "triggers.time")
+}
+
+func TestIdempotentWithAllowedChanges(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-not-idempotent", t.Name())
+	require.NoError(t, err)
+
+	options := WithDefaultRetryableErrors(t, &Options{
+		TerraformDir:          testFolder,
+		NoColor:               true,
+		IdempotencyExceptions: []IdempotencyException{{Address: "null_resource.test", Attributes: []string{"triggers"}}},
+	})
+
+	InitAndApplyAndIdempotent(t, options)
 }
 
 func TestParallelism(t *testing.T) {
@@ -0,0 +1,267 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+)
+
+// BinaryFlavor is the flavor of the binary used to run terraform commands.
+type BinaryFlavor string
+
+const (
+	BinaryFlavorTerraform BinaryFlavor = "terraform"
+	BinaryFlavorOpenTofu  BinaryFlavor = "opentofu"
+)
+
+// The features that can be checked with SupportsFeature. The -lock and -lock-timeout flags are not among them, as
+// every terraform release since 0.9 and every OpenTofu release supports them, so FormatArgs always sets them for the
+// commands that lock the state.
+const (
+	FeatureMigrateState      = "migrate-state"      // The -migrate-state flag of init
+	FeatureJsonOutput        = "json-output"        // The -json flag of plan, apply and destroy
+	FeatureRefreshOnly       = "refresh-only"       // The -refresh-only flag of plan and apply
+	FeatureConditions        = "conditions"         // precondition and postcondition blocks
+	FeatureCheckBlocks       = "check-blocks"       // check blocks
+	FeatureImportBlocks      = "import-blocks"      // import blocks
+	FeatureTesting           = "testing"            // The test command with .tftest.hcl files
+	FeatureProviderFunctions = "provider-functions" // Functions defined by providers
+	FeatureStateEncryption   = "state-encryption"   // Client side encryption of state and plan files
+)
+
+// featureVersionConstraints maps each feature to the versions of each flavor that support it. A flavor that is
+// missing from the map does not support the feature at all. Note that the first release of OpenTofu is 1.6.0, which
+// supports everything terraform 1.5 does.
+var featureVersionConstraints = map[string]map[BinaryFlavor]string{
+	FeatureMigrateState:      {BinaryFlavorTerraform: ">= 0.15.0", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureJsonOutput:        {BinaryFlavorTerraform: ">= 0.15.3", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureRefreshOnly:       {BinaryFlavorTerraform: ">= 0.15.4", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureConditions:        {BinaryFlavorTerraform: ">= 1.2.0", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureCheckBlocks:       {BinaryFlavorTerraform: ">= 1.5.0", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureImportBlocks:      {BinaryFlavorTerraform: ">= 1.5.0", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureTesting:           {BinaryFlavorTerraform: ">= 1.6.0", BinaryFlavorOpenTofu: ">= 1.6.0"},
+	FeatureProviderFunctions: {BinaryFlavorTerraform: ">= 1.8.0", BinaryFlavorOpenTofu: ">= 1.7.0"},
+	FeatureStateEncryption:   {BinaryFlavorOpenTofu: ">= 1.7.0"},
+}
+
+// BinaryInfo describes the binary used to run terraform commands.
+type BinaryInfo struct {
+	// The binary that was inspected. When running terragrunt, this is the binary terragrunt runs.
+	Binary   string
+	Flavor   BinaryFlavor
+	Version  *version.Version
+	Platform string
+}
+
+func (info BinaryInfo) String() string {
+	return fmt.Sprintf("%s v%s", info.Flavor, info.Version)
+}
+
+// SupportsFeature returns true if this binary supports the given feature (one of the Feature constants).
+func (info BinaryInfo) SupportsFeature(feature string) (bool, error) {
+	constraints, isKnownFeature := featureVersionConstraints[feature]
+	if !isKnownFeature {
+		return false, UnknownFeature(feature)
+	}
+	constraintStr, isSupported := constraints[info.Flavor]
+	if !isSupported {
+		return false, nil
+	}
+	constraint, err := version.NewConstraint(constraintStr)
+	if err != nil {
+		return false, err
+	}
+	// Compare the core version, so that pre-releases (e.g., 1.6.0-rc1) are considered to support the features of
+	// the release.
+	return constraint.Check(info.Version.Core()), nil
+}
+
+// binaryInfoCache caches the BinaryInfo of each binary, so that the version command only runs once per binary.
+var binaryInfoCache = struct {
+	sync.Mutex
+	infos map[string]*BinaryInfo
+}{infos: map[string]*BinaryInfo{}}
+
+// GetBinaryInfo returns the flavor and version of the binary the given options run terraform commands with. The
+// result is cached per binary for the lifetime of the process. This will fail the test if the binary can't be run.
+func GetBinaryInfo(t testing.TestingT, options *Options) *BinaryInfo {
+	info, err := GetBinaryInfoE(t, options)
+	require.NoError(t, err)
+	return info
+}
+
+// GetBinaryInfoE returns the flavor and version of the binary the given options run terraform commands with. The
+// result is cached per binary for the lifetime of the process. When TerraformBinary is terragrunt, this returns the
+// information of the binary terragrunt runs, as set in TERRAGRUNT_TFPATH (terraform by default).
+func GetBinaryInfoE(t testing.TestingT, options *Options) (*BinaryInfo, error) {
+	binary := underlyingBinary(options)
+
+	binaryInfoCache.Lock()
+	defer binaryInfoCache.Unlock()
+
+	if info, isCached := binaryInfoCache.infos[binary]; isCached {
+		return info, nil
+	}
+	info, err := detectBinaryInfoE(t, options, binary)
+	if err != nil {
+		return nil, err
+	}
+	binaryInfoCache.infos[binary] = info
+	return info, nil
+}
+
+// SupportsFeature returns true if the binary the given options run terraform commands with supports the given
+// feature (one of the Feature constants). This is useful to skip tests that need a newer binary:
+//
+//	if !terraform.SupportsFeature(t, options, terraform.FeatureTesting) {
+//		t.Skip("terraform test is not supported")
+//	}
+//
+// This will fail the test if the binary can't be run or the feature is unknown.
+func SupportsFeature(t testing.TestingT, options *Options, feature string) bool {
+	supported, err := SupportsFeatureE(t, options, feature)
+	require.NoError(t, err)
+	return supported
+}
+
+// SupportsFeatureE returns true if the binary the given options run terraform commands with supports the given
+// feature (one of the Feature constants).
+func SupportsFeatureE(t testing.TestingT, options *Options, feature string) (bool, error) {
+	info, err := GetBinaryInfoE(t, options)
+	if err != nil {
+		return false, err
+	}
+	return info.SupportsFeature(feature)
+}
+
+// checkFeatureSupport returns an UnsupportedFeature error if the binary the given options run terraform commands with
+// is known not to support the given feature. If the binary can't be inspected, this returns nil and leaves it to the
+// binary to report the problem when the command runs.
+func checkFeatureSupport(t testing.TestingT, options *Options, feature string) error {
+	info, err := GetBinaryInfoE(t, options)
+	if err != nil {
+		return nil
+	}
+	if supported, err := info.SupportsFeature(feature); err == nil && !supported {
+		return UnsupportedFeature{Feature: feature, Binary: *info}
+	}
+	return nil
+}
+
+// underlyingBinary returns the binary that actually runs the terraform commands for the given options.
+func underlyingBinary(options *Options) string {
+	binary := options.TerraformBinary
+	if binary == "" {
+		binary = "terraform"
+	}
+	if binary != "terragrunt" {
+		return binary
+	}
+	if tfPath := options.EnvVars["TERRAGRUNT_TFPATH"]; tfPath != "" {
+		return tfPath
+	}
+	if tfPath := os.Getenv("TERRAGRUNT_TFPATH"); tfPath != "" {
+		return tfPath
+	}
+	return "terraform"
+}
+
+var versionOutputRegexp = regexp.MustCompile(`(Terraform|OpenTofu) v(\S+)`)
+
+// detectBinaryInfoE runs the version command of the given binary to find out its flavor and version. Terraform and
+// OpenTofu report their version in the same json format, so the flavor is derived from the name of the binary, or
+// from the human readable output of the version command if the name is inconclusive. Terraform versions older than
+// 0.13 don't support version -json, in which case only the human readable output is used.
+func detectBinaryInfoE(t testing.TestingT, options *Options, binary string) (*BinaryInfo, error) {
+	runVersion := func(args ...string) (string, error) {
+		env := map[string]string{"CHECKPOINT_DISABLE": "1"}
+		for key, value := range options.EnvVars {
+			env[key] = value
+		}
+		return shell.RunCommandAndGetStdOutE(t, shell.Command{
+			Command:    binary,
+			Args:       append([]string{"version"}, args...),
+			WorkingDir: options.TerraformDir,
+			Env:        env,
+			Logger:     options.Logger,
+		})
+	}
+
+	info := &BinaryInfo{Binary: binary, Flavor: flavorFromBinaryName(binary)}
+
+	var textOutput string
+	jsonOutput, err := runVersion("-json")
+	if err == nil {
+		info.Version, info.Platform, err = parseVersionJson(jsonOutput)
+	}
+	if err != nil || info.Flavor == "" {
+		textOutput, err = runVersion()
+		if err != nil {
+			return nil, err
+		}
+		flavor, textVersion, err := parseVersionText(textOutput)
+		if err != nil {
+			return nil, err
+		}
+		info.Flavor = flavor
+		if info.Version == nil {
+			info.Version = textVersion
+		}
+	}
+	return info, nil
+}
+
+// flavorFromBinaryName returns the flavor of the binary with the given name, or an empty string if the name is
+// inconclusive.
+func flavorFromBinaryName(binary string) BinaryFlavor {
+	name := strings.ToLower(filepath.Base(binary))
+	switch {
+	case strings.Contains(name, "tofu"):
+		return BinaryFlavorOpenTofu
+	case strings.Contains(name, "terraform"):
+		return BinaryFlavorTerraform
+	default:
+		return ""
+	}
+}
+
+// parseVersionJson parses the output of version -json.
+func parseVersionJson(output string) (*version.Version, string, error) {
+	var parsed struct {
+		TerraformVersion string `json:"terraform_version"`
+		Platform         string `json:"platform"`
+	}
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		return nil, "", err
+	}
+	parsedVersion, err := version.NewVersion(parsed.TerraformVersion)
+	if err != nil {
+		return nil, "", err
+	}
+	return parsedVersion, parsed.Platform, nil
+}
+
+// parseVersionText parses the human readable output of version, e.g. "OpenTofu v1.6.0".
+func parseVersionText(output string) (BinaryFlavor, *version.Version, error) {
+	matches := versionOutputRegexp.FindStringSubmatch(output)
+	if matches == nil {
+		return "", nil, UnrecognizedVersionOutput(output)
+	}
+	parsedVersion, err := version.NewVersion(matches[2])
+	if err != nil {
+		return "", nil, err
+	}
+	if matches[1] == "OpenTofu" {
+		return BinaryFlavorOpenTofu, parsedVersion, nil
+	}
+	return BinaryFlavorTerraform, parsedVersion, nil
+}
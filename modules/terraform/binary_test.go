@@ -0,0 +1,141 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeVersionBinary writes a script with the given name that mimics the version command of terraform or OpenTofu,
+// and records each invocation in a log file, whose path is returned along with the path of the script.
+func writeFakeVersionBinary(t *testing.T, name string, jsonOutput string, textOutput string) (string, string) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "invocations.log")
+	binaryPath := filepath.Join(dir, name)
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %q
+if [ "$2" = "-json" ]; then
+  if [ -z %q ]; then echo "flag provided but not defined: -json" >&2; exit 1; fi
+  echo %q
+else
+  echo %q
+fi
+`, logPath, jsonOutput, jsonOutput, textOutput)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath, logPath
+}
+
+func readInvocations(t *testing.T, logPath string) []string {
+	out, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestGetBinaryInfoTerraform(t *testing.T) {
+	t.Parallel()
+
+	binary, logPath := writeFakeVersionBinary(t, "terraform", `{"terraform_version":"1.5.7","platform":"linux_amd64","provider_selections":{},"terraform_outdated":true}`, "Terraform v1.5.7")
+	options := &Options{TerraformBinary: binary, TerraformDir: t.TempDir()}
+
+	info := GetBinaryInfo(t, options)
+	assert.Equal(t, BinaryFlavorTerraform, info.Flavor)
+	assert.Equal(t, "1.5.7", info.Version.String())
+	assert.Equal(t, "linux_amd64", info.Platform)
+	assert.Equal(t, "terraform v1.5.7", info.String())
+
+	// The result is cached, so the binary only runs once.
+	GetBinaryInfo(t, options)
+	assert.Equal(t, []string{"version -json"}, readInvocations(t, logPath))
+
+	assert.True(t, SupportsFeature(t, options, FeatureCheckBlocks))
+	assert.False(t, SupportsFeature(t, options, FeatureTesting))
+	assert.False(t, SupportsFeature(t, options, FeatureStateEncryption))
+	_, err := SupportsFeatureE(t, options, "time-travel")
+	assert.Equal(t, UnknownFeature("time-travel"), err)
+}
+
+func TestGetBinaryInfoOpenTofuWithInconclusiveName(t *testing.T) {
+	t.Parallel()
+
+	binary, logPath := writeFakeVersionBinary(t, "tf", `{"terraform_version":"1.7.1","platform":"darwin_arm64","provider_selections":{}}`, "OpenTofu v1.7.1\non darwin_arm64")
+	options := &Options{TerraformBinary: binary}
+
+	info := GetBinaryInfo(t, options)
+	assert.Equal(t, BinaryFlavorOpenTofu, info.Flavor)
+	assert.Equal(t, "1.7.1", info.Version.String())
+	assert.Equal(t, []string{"version -json", "version"}, readInvocations(t, logPath))
+
+	assert.True(t, SupportsFeature(t, options, FeatureTesting))
+	assert.True(t, SupportsFeature(t, options, FeatureStateEncryption))
+}
+
+func TestGetBinaryInfoWithoutJsonVersion(t *testing.T) {
+	t.Parallel()
+
+	binary, _ := writeFakeVersionBinary(t, "terraform", "", "Terraform v0.12.31\n\nYour version of Terraform is out of date!")
+	options := &Options{TerraformBinary: binary}
+
+	info := GetBinaryInfo(t, options)
+	assert.Equal(t, BinaryFlavorTerraform, info.Flavor)
+	assert.Equal(t, "0.12.31", info.Version.String())
+
+	err := checkFeatureSupport(t, options, FeatureRefreshOnly)
+	assert.Equal(t, UnsupportedFeature{Feature: FeatureRefreshOnly, Binary: *info}, err)
+	assert.EqualError(t, err, `terraform v0.12.31 does not support the "refresh-only" feature`)
+}
+
+func TestGetBinaryInfoForTerragrunt(t *testing.T) {
+	t.Parallel()
+
+	binary, _ := writeFakeVersionBinary(t, "tofu", `{"terraform_version":"1.6.0-rc1","platform":"linux_amd64"}`, "OpenTofu v1.6.0-rc1")
+	options := &Options{
+		TerraformBinary: "terragrunt",
+		EnvVars:         map[string]string{"TERRAGRUNT_TFPATH": binary},
+	}
+
+	info := GetBinaryInfo(t, options)
+	assert.Equal(t, binary, info.Binary)
+	assert.Equal(t, BinaryFlavorOpenTofu, info.Flavor)
+	assert.True(t, SupportsFeature(t, options, FeatureTesting), "pre-releases should support the features of the release")
+}
+
+func TestBinaryInfoSupportsFeature(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor   BinaryFlavor
+		version  string
+		feature  string
+		expected bool
+	}{
+		{BinaryFlavorTerraform, "0.14.11", FeatureMigrateState, false},
+		{BinaryFlavorTerraform, "0.15.0", FeatureMigrateState, true},
+		{BinaryFlavorTerraform, "0.15.3", FeatureRefreshOnly, false},
+		{BinaryFlavorTerraform, "0.15.4", FeatureRefreshOnly, true},
+		{BinaryFlavorTerraform, "1.1.9", FeatureConditions, false},
+		{BinaryFlavorTerraform, "1.2.0", FeatureConditions, true},
+		{BinaryFlavorTerraform, "1.9.0", FeatureStateEncryption, false},
+		{BinaryFlavorOpenTofu, "1.6.0", FeatureProviderFunctions, false},
+		{BinaryFlavorOpenTofu, "1.7.0", FeatureProviderFunctions, true},
+	}
+
+	for _, testCase := range testCases {
+		info := BinaryInfo{Flavor: testCase.flavor, Version: version.Must(version.NewVersion(testCase.version))}
+		supported, err := info.SupportsFeature(testCase.feature)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.expected, supported, "%s %s", info, testCase.feature)
+	}
+}
+
+func TestParseVersionText(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := parseVersionText("command not found")
+	assert.IsType(t, UnrecognizedVersionOutput(""), err)
+}
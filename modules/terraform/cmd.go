@@ -1,10 +1,11 @@
 package terraform
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gruntwork-io/terratest/modules/collections"
-	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/shell"
 	"github.com/gruntwork-io/terratest/modules/testing"
 )
@@ -17,6 +18,9 @@ func generateCommand(options *Options, args ...string) shell.Command {
 		Env:        options.EnvVars,
 		Logger:     options.Logger,
 	}
+	if options.JsonOutput {
+		cmd.Logger = logger.New(jsonEventLogger{underlying: options.Logger, callback: options.JsonEventCallback})
+	}
 	return cmd
 }
 
@@ -55,6 +59,18 @@ func GetCommonOptions(options *Options, args ...string) (*Options, []string) {
 	return options, args
 }
 
+// prepareCommandE sets up what the given options need before running terraform with the given arguments (the
+// isolated state and the generated var file), and returns the options and arguments to run the command with, along
+// with a function that cleans up after the command.
+func prepareCommandE(t testing.TestingT, options *Options, args []string) (*Options, []string, func(), error) {
+	options, err := isolatedOptionsE(t, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	args, release, err := prepareVarsFileE(options, args)
+	return options, args, release, err
+}
+
 // RunTerraformCommand runs terraform with the given arguments and options and return stdout/stderr.
 func RunTerraformCommand(t testing.TestingT, additionalOptions *Options, args ...string) string {
 	out, err := RunTerraformCommandE(t, additionalOptions, args...)
@@ -67,11 +83,17 @@ func RunTerraformCommand(t testing.TestingT, additionalOptions *Options, args ..
 // RunTerraformCommandE runs terraform with the given arguments and options and return stdout/stderr.
 func RunTerraformCommandE(t testing.TestingT, additionalOptions *Options, additionalArgs ...string) (string, error) {
 	options, args := GetCommonOptions(additionalOptions, additionalArgs...)
+	options, args, release, err := prepareCommandE(t, options, args)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
 	cmd := generateCommand(options, args...)
 	description := fmt.Sprintf("%s %v", options.TerraformBinary, args)
-	return retry.DoWithRetryableErrorsE(t, description, options.RetryableTerraformErrors, options.MaxRetries, options.TimeBetweenRetries, func() (string, error) {
-		return shell.RunCommandAndGetOutputE(t, cmd)
+	return options.doWithRetryableErrorsE(t, description, func() (string, error) {
+		out, err := shell.RunCommandAndGetOutputE(t, cmd)
+		return out, wrapTerraformError(err)
 	})
 }
 
@@ -79,11 +101,51 @@ func RunTerraformCommandE(t testing.TestingT, additionalOptions *Options, additi
 // (but not stderr).
 func RunTerraformCommandAndGetStdoutE(t testing.TestingT, additionalOptions *Options, additionalArgs ...string) (string, error) {
 	options, args := GetCommonOptions(additionalOptions, additionalArgs...)
+	options, args, release, err := prepareCommandE(t, options, args)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
 	cmd := generateCommand(options, args...)
 	description := fmt.Sprintf("%s %v", options.TerraformBinary, args)
-	return retry.DoWithRetryableErrorsE(t, description, options.RetryableTerraformErrors, options.MaxRetries, options.TimeBetweenRetries, func() (string, error) {
-		return shell.RunCommandAndGetStdOutE(t, cmd)
+	return options.doWithRetryableErrorsE(t, description, func() (string, error) {
+		out, err := shell.RunCommandAndGetStdOutE(t, cmd)
+		return out, wrapTerraformError(err)
+	})
+}
+
+// runTerraformCommandAndGetRedactedStdoutE runs terraform with the given arguments and options and returns solely its
+// stdout, like RunTerraformCommandAndGetStdoutE. The difference is that the output is not streamed to the logger while
+// the command runs: instead, once the command succeeds, the output is passed through the given redact function and
+// the result is logged. If the command fails, only its stderr is logged. This is used for commands that print
+// sensitive values in their json output (e.g., output and show), as terraform only hides those values in its human
+// readable output.
+func runTerraformCommandAndGetRedactedStdoutE(t testing.TestingT, additionalOptions *Options, redact func(string) string, additionalArgs ...string) (string, error) {
+	options, args := GetCommonOptions(additionalOptions, additionalArgs...)
+	options, args, release, err := prepareCommandE(t, options, args)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	cmd := generateCommand(options, args...)
+	cmd.Logger = logger.Discard
+	description := fmt.Sprintf("%s %v", options.TerraformBinary, args)
+	return options.doWithRetryableErrorsE(t, description, func() (string, error) {
+		options.Logger.Logf(t, "Running command %s with args %s", cmd.Command, cmd.Args)
+		out, err := shell.RunCommandAndGetStdOutE(t, cmd)
+		if err != nil {
+			// The stderr of a failed command is needed to debug it, and terraform doesn't print sensitive values in
+			// its diagnostics.
+			var cmdErr *shell.ErrWithCmdOutput
+			if errors.As(err, &cmdErr) {
+				options.Logger.Logf(t, "%s", cmdErr.Output.Stderr())
+			}
+			return out, wrapTerraformError(err)
+		}
+		options.Logger.Logf(t, "%s", redact(out))
+		return out, nil
 	})
 }
 
@@ -99,10 +161,15 @@ func GetExitCodeForTerraformCommand(t testing.TestingT, additionalOptions *Optio
 // GetExitCodeForTerraformCommandE runs terraform with the given arguments and options and returns exit code
 func GetExitCodeForTerraformCommandE(t testing.TestingT, additionalOptions *Options, additionalArgs ...string) (int, error) {
 	options, args := GetCommonOptions(additionalOptions, additionalArgs...)
+	options, args, release, err := prepareCommandE(t, options, args)
+	if err != nil {
+		return DefaultErrorExitCode, err
+	}
+	defer release()
 
 	additionalOptions.Logger.Logf(t, "Running %s with args %v", options.TerraformBinary, args)
 	cmd := generateCommand(options, args...)
-	_, err := shell.RunCommandAndGetOutputE(t, cmd)
+	_, err = shell.RunCommandAndGetOutputE(t, cmd)
 	if err == nil {
 		return DefaultSuccessExitCode, nil
 	}
@@ -0,0 +1,249 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+)
+
+// The severities of the diagnostics terraform reports.
+const (
+	DiagnosticSeverityError   = "error"
+	DiagnosticSeverityWarning = "warning"
+)
+
+// Diagnostic is an error or warning reported by terraform.
+type Diagnostic struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail"`
+	Address  string           `json:"address,omitempty"`
+	Range    *DiagnosticRange `json:"range,omitempty"`
+
+	// The source code the diagnostic refers to, and the values of the expressions in it.
+	Snippet *DiagnosticSnippet `json:"snippet,omitempty"`
+}
+
+// String renders the diagnostic on a single line, e.g. `Error: Invalid value for variable (at main.tf:4): detail`.
+func (diagnostic Diagnostic) String() string {
+	var location []string
+	if diagnostic.Address != "" {
+		location = append(location, "with "+diagnostic.Address)
+	}
+	if diagnostic.Range != nil {
+		location = append(location, "at "+diagnostic.Range.String())
+	}
+
+	severity := diagnostic.Severity
+	if severity != "" {
+		severity = strings.ToUpper(severity[:1]) + severity[1:]
+	}
+	out := fmt.Sprintf("%s: %s", severity, diagnostic.Summary)
+	if len(location) > 0 {
+		out += fmt.Sprintf(" (%s)", strings.Join(location, ", "))
+	}
+	if diagnostic.Detail != "" {
+		out += ": " + strings.Join(strings.Fields(diagnostic.Detail), " ")
+	}
+	return out
+}
+
+// DiagnosticRange is the location in the source code a diagnostic refers to.
+type DiagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    DiagnosticPos `json:"start"`
+	End      DiagnosticPos `json:"end"`
+}
+
+// String renders the range as file:line, or file:line,column if the column is known.
+func (diagnosticRange DiagnosticRange) String() string {
+	if diagnosticRange.Start.Column > 0 {
+		return fmt.Sprintf("%s:%d,%d", diagnosticRange.Filename, diagnosticRange.Start.Line, diagnosticRange.Start.Column)
+	}
+	return fmt.Sprintf("%s:%d", diagnosticRange.Filename, diagnosticRange.Start.Line)
+}
+
+// DiagnosticPos is a position in a source file.
+type DiagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// DiagnosticSnippet is the source code a diagnostic refers to.
+type DiagnosticSnippet struct {
+	// The block the code is in, e.g. `resource "aws_instance" "foo"` or `check "health"`. This is empty if the code is
+	// not in a nested block (e.g., a variable declaration).
+	Context   string                      `json:"context"`
+	Code      string                      `json:"code"`
+	StartLine int                         `json:"start_line"`
+	Values    []DiagnosticExpressionValue `json:"values"`
+}
+
+// DiagnosticExpressionValue describes the value of an expression referenced by the code of a diagnostic, e.g.
+// Traversal var.instance_type and Statement `is "t2.huge"`.
+type DiagnosticExpressionValue struct {
+	Traversal string `json:"traversal"`
+	Statement string `json:"statement"`
+}
+
+// TerraformError is returned when a terraform command fails and terraform explained why in one or more error
+// diagnostics. The embedded Diagnostic is the first error terraform reported; all of them are in Diagnostics. Use
+// errors.As to get it from the error returned by the E functions of this package (e.g., ApplyE).
+type TerraformError struct {
+	Diagnostic
+	Diagnostics []Diagnostic
+	Underlying  error
+}
+
+func (err TerraformError) Error() string {
+	lines := make([]string, 0, len(err.Diagnostics))
+	for _, diagnostic := range err.Diagnostics {
+		lines = append(lines, diagnostic.String())
+	}
+	return fmt.Sprintf("terraform reported %d error(s):\n%s\n%v", len(err.Diagnostics), strings.Join(lines, "\n"), err.Underlying)
+}
+
+func (err TerraformError) Unwrap() error {
+	return err.Underlying
+}
+
+// wrapTerraformError wraps the error of a failed terraform command in a TerraformError if terraform reported any error
+// diagnostics in its output. As the message of a TerraformError includes the summary and detail of each diagnostic,
+// this also allows RetryableTerraformErrors to match them when terraform is run with -json, where they would otherwise
+// only appear json-encoded in the output.
+func wrapTerraformError(err error) error {
+	var cmdErr *shell.ErrWithCmdOutput
+	if !errors.As(err, &cmdErr) || cmdErr.Output == nil {
+		return err
+	}
+
+	var errorDiagnostics []Diagnostic
+	for _, diagnostic := range ParseDiagnostics(cmdErr.Output.Combined()) {
+		if diagnostic.Severity == DiagnosticSeverityError {
+			errorDiagnostics = append(errorDiagnostics, diagnostic)
+		}
+	}
+	if len(errorDiagnostics) == 0 {
+		return err
+	}
+	return TerraformError{Diagnostic: errorDiagnostics[0], Diagnostics: errorDiagnostics, Underlying: err}
+}
+
+// ParseDiagnostics parses the errors and warnings terraform reported in the given output of a terraform command. This
+// supports both the json output of commands run with -json and the human readable output (with or without color).
+// Note that terraform does not print the column of the source range nor, before 0.15, the resource address in its
+// human readable output.
+func ParseDiagnostics(output string) []Diagnostic {
+	if result := ParseJsonOutput(output); len(result.Events) > 0 {
+		return result.Diagnostics
+	}
+	return parseHumanReadableDiagnostics(output)
+}
+
+var (
+	ansiEscapeRegexp        = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	diagnosticHeaderRegexp  = regexp.MustCompile(`^(Error|Warning): (.+)$`)
+	diagnosticAddressRegexp = regexp.MustCompile(`^\s+with (.+),$`)
+	diagnosticSubjectRegexp = regexp.MustCompile(`^\s+on (.+?) line (\d+)(?:, in (.+?))?:?$`)
+	diagnosticCodeRegexp    = regexp.MustCompile(`^\s*(\d+): (.*)$`)
+	diagnosticValueRegexp   = regexp.MustCompile(`^\s+│ (\S+) (.+)$`)
+)
+
+// parseHumanReadableDiagnostics parses diagnostics in the format terraform prints them when not run with -json:
+//
+//	╷
+//	│ Error: Invalid value for variable
+//	│
+//	│   with module.foo.aws_instance.bar,
+//	│   on main.tf line 1:
+//	│    1: variable "instance_type" {
+//	│     ├────────────────
+//	│     │ var.instance_type is "t2.huge"
+//	│
+//	│ Instance type must be t3.*.
+//	╵
+//
+// Before 0.15, terraform did not draw the box around each diagnostic, in which case the detail of the last diagnostic
+// extends to the end of the output.
+func parseHumanReadableDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	var current *Diagnostic
+	var detail []string
+	inSnippet := false
+
+	finishCurrent := func() {
+		if current != nil {
+			current.Detail = strings.TrimSpace(strings.Join(detail, "\n"))
+			diagnostics = append(diagnostics, *current)
+		}
+		current = nil
+		detail = nil
+		inSnippet = false
+	}
+
+	for _, line := range strings.Split(ansiEscapeRegexp.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, " \r")
+		switch {
+		case strings.HasPrefix(line, "╷"), strings.HasPrefix(line, "╵"):
+			finishCurrent()
+			continue
+		case strings.HasPrefix(line, "│"):
+			line = strings.TrimPrefix(strings.TrimPrefix(line, "│"), " ")
+		}
+
+		if matches := diagnosticHeaderRegexp.FindStringSubmatch(line); matches != nil {
+			finishCurrent()
+			current = &Diagnostic{Severity: strings.ToLower(matches[1]), Summary: matches[2]}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		// The address and source location come before the detail, and the source location is followed by a
+		// snippet of the code, up to the next blank line.
+		if inSnippet {
+			if matches := diagnosticCodeRegexp.FindStringSubmatch(line); matches != nil {
+				if current.Snippet.Code == "" {
+					current.Snippet.StartLine, _ = strconv.Atoi(matches[1])
+					current.Snippet.Code = matches[2]
+				} else {
+					current.Snippet.Code += "\n" + matches[2]
+				}
+			} else if matches := diagnosticValueRegexp.FindStringSubmatch(line); matches != nil {
+				current.Snippet.Values = append(current.Snippet.Values, DiagnosticExpressionValue{Traversal: matches[1], Statement: matches[2]})
+			}
+			inSnippet = line != ""
+			continue
+		}
+		if len(detail) == 0 {
+			if line == "" {
+				continue
+			}
+			if matches := diagnosticAddressRegexp.FindStringSubmatch(line); matches != nil && current.Address == "" {
+				current.Address = matches[1]
+				continue
+			}
+			if matches := diagnosticSubjectRegexp.FindStringSubmatch(line); matches != nil && current.Range == nil {
+				lineNumber, _ := strconv.Atoi(matches[2])
+				current.Range = &DiagnosticRange{
+					Filename: matches[1],
+					Start:    DiagnosticPos{Line: lineNumber},
+					End:      DiagnosticPos{Line: lineNumber},
+				}
+				current.Snippet = &DiagnosticSnippet{Context: matches[3]}
+				inSnippet = true
+				continue
+			}
+		}
+		detail = append(detail, line)
+	}
+	finishCurrent()
+
+	return diagnostics
+}
@@ -0,0 +1,175 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const humanReadableDiagnostics = "\x1b[31m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mInvalid value for variable\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[0m  on main.tf line 1:\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m   1: \x1b[4mvariable \"instance_type\" {\x1b[0m\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m    \x1b[90m├────────────────\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[0m    \x1b[90m│\x1b[0m \x1b[1mvar.instance_type\x1b[0m is \"t2.huge\"\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0mInstance type must be t3.*.\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0mThis was checked by the validation rule at main.tf:4,3-13.\n" +
+	"\x1b[31m╵\x1b[0m\x1b[0m\n" +
+	`╷
+│ Warning: Deprecated attribute
+│
+│   with null_resource.old,
+│   on main.tf line 12, in resource "null_resource" "old":
+│   12:   foo = null_resource.bar.baz
+│
+│ The attribute "baz" is deprecated.
+╵
+╷
+│ Error: local-exec provisioner error
+│
+│   with null_resource.fail,
+│   on main.tf line 8, in resource "null_resource" "fail":
+│    8:   provisioner "local-exec" {
+│
+│ Error running command 'exit 1': exit status 1. Output:
+╵
+`
+
+const legacyDiagnostics = `
+Error: Unsupported argument
+
+  on main.tf line 3, in resource "null_resource" "test":
+   3:   foo = "bar"
+
+An argument named "foo" is not expected here.
+`
+
+func TestParseHumanReadableDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diagnostics := ParseDiagnostics(humanReadableDiagnostics)
+	require.Len(t, diagnostics, 3)
+
+	assert.Equal(t, Diagnostic{
+		Severity: DiagnosticSeverityError,
+		Summary:  "Invalid value for variable",
+		Detail:   "Instance type must be t3.*.\n\nThis was checked by the validation rule at main.tf:4,3-13.",
+		Range:    &DiagnosticRange{Filename: "main.tf", Start: DiagnosticPos{Line: 1}, End: DiagnosticPos{Line: 1}},
+		Snippet: &DiagnosticSnippet{
+			Code:      `variable "instance_type" {`,
+			StartLine: 1,
+			Values:    []DiagnosticExpressionValue{{Traversal: "var.instance_type", Statement: `is "t2.huge"`}},
+		},
+	}, diagnostics[0])
+
+	assert.Equal(t, DiagnosticSeverityWarning, diagnostics[1].Severity)
+	assert.Equal(t, "null_resource.old", diagnostics[1].Address)
+	assert.Equal(t, 12, diagnostics[1].Range.Start.Line)
+	assert.Equal(t, `resource "null_resource" "old"`, diagnostics[1].Snippet.Context)
+	assert.Equal(t, "  foo = null_resource.bar.baz", diagnostics[1].Snippet.Code)
+
+	assert.Equal(t, "local-exec provisioner error", diagnostics[2].Summary)
+	assert.Equal(t, "null_resource.fail", diagnostics[2].Address)
+	assert.Equal(t, "Error running command 'exit 1': exit status 1. Output:", diagnostics[2].Detail)
+}
+
+func TestParseLegacyDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diagnostics := ParseDiagnostics(legacyDiagnostics)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "Unsupported argument", diagnostics[0].Summary)
+	assert.Equal(t, "main.tf", diagnostics[0].Range.Filename)
+	assert.Equal(t, 3, diagnostics[0].Range.Start.Line)
+	assert.Equal(t, `An argument named "foo" is not expected here.`, diagnostics[0].Detail)
+}
+
+func TestParseJsonDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diagnostics := ParseDiagnostics(applyJsonOutput)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "null_resource.fail", diagnostics[0].Address)
+	assert.Equal(t, "main.tf:8,28", diagnostics[0].Range.String())
+	assert.Equal(t, "Error: local-exec provisioner error (with null_resource.fail, at main.tf:8,28): Error running command 'exit 1': exit status 1.", diagnostics[0].String())
+}
+
+// writeFakeTerraformBinary writes a script that prints the given output to stderr and exits with an error, to test
+// how failed terraform commands are handled without running terraform.
+func writeFakeTerraformBinary(t *testing.T, stderr string) string {
+	outputPath := filepath.Join(t.TempDir(), "stderr.txt")
+	require.NoError(t, ioutil.WriteFile(outputPath, []byte(stderr), 0644))
+
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	script := fmt.Sprintf("#!/bin/sh\ncat %q >&2\nexit 1\n", outputPath)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath
+}
+
+func TestFailedCommandReturnsTerraformError(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformBinary: writeFakeTerraformBinary(t, humanReadableDiagnostics),
+		TerraformDir:    t.TempDir(),
+	}
+	_, err := ApplyE(t, options)
+	require.Error(t, err)
+
+	var tfErr TerraformError
+	require.True(t, errors.As(err, &tfErr))
+	assert.Equal(t, "Invalid value for variable", tfErr.Summary)
+	assert.Len(t, tfErr.Diagnostics, 2)
+	assert.Equal(t, "null_resource.fail", tfErr.Diagnostics[1].Address)
+	assert.Contains(t, err.Error(), "Error: Invalid value for variable (at main.tf:1): Instance type must be t3.*.")
+
+	var fatalErr retry.FatalError
+	assert.True(t, errors.As(err, &fatalErr))
+}
+
+func TestRetryableTerraformErrorsMatchJsonDiagnosticSummaries(t *testing.T) {
+	t.Parallel()
+
+	lockError := `{"@level":"error","@message":"Error: Error acquiring the state lock","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:00.000000Z","diagnostic":{"severity":"error","summary":"Error acquiring the state lock","detail":"Lock held by \"someone else\""},"type":"diagnostic"}`
+	options := &Options{
+		TerraformBinary:          writeFakeTerraformBinary(t, lockError),
+		TerraformDir:             t.TempDir(),
+		JsonOutput:               true,
+		RetryableTerraformErrors: map[string]string{`Lock held by "someone else"`: "State is locked."},
+		MaxRetries:               1,
+	}
+	_, err := ApplyE(t, options)
+
+	var maxRetriesErr retry.MaxRetriesExceeded
+	assert.True(t, errors.As(err, &maxRetriesErr), "expected the lock error to be retried, got: %v", err)
+}
+
+func TestApplyWithErrorReturnsTerraformError(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-with-error", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	Init(t, options)
+
+	_, err = ApplyE(t, options)
+	var tfErr TerraformError
+	require.True(t, errors.As(err, &tfErr), "expected a TerraformError, got: %v", err)
+	assert.Equal(t, "local-exec provisioner error", tfErr.Summary)
+	assert.Equal(t, "null_resource.fail_on_first_run", tfErr.Address)
+	require.NotNil(t, tfErr.Range)
+	assert.Equal(t, "main.tf", tfErr.Range.Filename)
+}
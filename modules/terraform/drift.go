@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ResourceDrift represents a change that was made to a resource outside of terraform, as detected by terraform when
+// refreshing the state of that resource.
+type ResourceDrift struct {
+	// The full address of the resource (e.g., module.foo.aws_instance.bar).
+	Address string
+
+	// The type of the resource (e.g., aws_instance).
+	Type string
+
+	// The actions describing the drift: update if the resource was changed outside of terraform, or delete if it was
+	// deleted outside of terraform.
+	Actions tfjson.Actions
+
+	// The attributes that changed outside of terraform, with their values in state (Before) and in the real
+	// infrastructure (After).
+	AttributeChanges []AttributeChange
+}
+
+// String renders the drift as a human readable diff.
+func (drift ResourceDrift) String() string {
+	return fmt.Sprintf("%s (%v):\n%s", drift.Address, drift.Actions, formatAttributeChanges(drift.AttributeChanges))
+}
+
+// GetResourceDrift returns the drift terraform detected in the given plan, sorted by resource address. If there is no
+// drift, this returns an empty list.
+func GetResourceDrift(plan *PlanStruct) []ResourceDrift {
+	addresses := make([]string, 0, len(plan.ResourceDriftMap))
+	for address := range plan.ResourceDriftMap {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	out := []ResourceDrift{}
+	for _, address := range addresses {
+		resourceChange := plan.ResourceDriftMap[address]
+		if resourceChange.Change == nil || resourceChange.Change.Actions.NoOp() {
+			continue
+		}
+		out = append(out, ResourceDrift{
+			Address:          address,
+			Type:             resourceChange.Type,
+			Actions:          resourceChange.Change.Actions,
+			AttributeChanges: GetAttributeChanges(resourceChange.Change),
+		})
+	}
+	return out
+}
+
+// PlanRefreshOnlyWithStruct runs terraform plan in refresh-only mode with the given options, and then terraform show
+// on the resulting plan, and parses the json result into a go struct. If PlanFilePath is not set on the options, a
+// temporary plan file is used. The drift terraform detected is available in ResourceDriftMap of the returned struct,
+// or through GetResourceDrift. This will fail the test if there is an error in the command.
+func PlanRefreshOnlyWithStruct(t testing.TestingT, options *Options) *PlanStruct {
+	plan, err := PlanRefreshOnlyWithStructE(t, options)
+	require.NoError(t, err)
+	return plan
+}
+
+// PlanRefreshOnlyWithStructE runs terraform plan in refresh-only mode with the given options, and then terraform show
+// on the resulting plan, and parses the json result into a go struct. If PlanFilePath is not set on the options, a
+// temporary plan file is used. The drift terraform detected is available in ResourceDriftMap of the returned struct,
+// or through GetResourceDrift. This returns an UnsupportedFeature error if the binary is too old to support
+// -refresh-only.
+func PlanRefreshOnlyWithStructE(t testing.TestingT, options *Options) (*PlanStruct, error) {
+	if err := checkFeatureSupport(t, options, FeatureRefreshOnly); err != nil {
+		return nil, err
+	}
+
+	planOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if planOptions.PlanFilePath == "" {
+		tmpFile, err := ioutil.TempFile("", "terratest-refresh-only-plan-file-")
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpFile.Close(); err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmpFile.Name())
+		planOptions.PlanFilePath = tmpFile.Name()
+	}
+
+	if _, err := RunTerraformCommandE(t, planOptions, FormatArgs(planOptions, "plan", "-refresh-only", "-input=false", "-lock=false")...); err != nil {
+		return nil, err
+	}
+	return ShowWithStructE(t, planOptions)
+}
+
+// DetectDrift runs terraform plan in refresh-only mode with the given options and returns the drift terraform
+// detected, sorted by resource address. This will fail the test if there is an error in the command.
+func DetectDrift(t testing.TestingT, options *Options) []ResourceDrift {
+	drift, err := DetectDriftE(t, options)
+	require.NoError(t, err)
+	return drift
+}
+
+// DetectDriftE runs terraform plan in refresh-only mode with the given options and returns the drift terraform
+// detected, sorted by resource address.
+func DetectDriftE(t testing.TestingT, options *Options) ([]ResourceDrift, error) {
+	plan, err := PlanRefreshOnlyWithStructE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return GetResourceDrift(plan), nil
+}
+
+// AssertNoDrift runs terraform plan in refresh-only mode with the given options and checks that terraform did not
+// detect any changes made outside of terraform, failing the test if it did.
+func AssertNoDrift(t testing.TestingT, options *Options) {
+	drift, err := DetectDriftE(t, options)
+	if assert.NoError(t, err) {
+		assert.Empty(t, drift, formatDrift(drift))
+	}
+}
+
+// RequireNoDrift runs terraform plan in refresh-only mode with the given options and checks that terraform did not
+// detect any changes made outside of terraform, failing and halting the test if it did.
+func RequireNoDrift(t testing.TestingT, options *Options) {
+	drift := DetectDrift(t, options)
+	require.Empty(t, drift, formatDrift(drift))
+}
+
+// formatDrift renders the given drift as a message for a failed assertion.
+func formatDrift(drift []ResourceDrift) string {
+	sections := make([]string, 0, len(drift))
+	for _, resourceDrift := range drift {
+		sections = append(sections, resourceDrift.String())
+	}
+	return fmt.Sprintf("Terraform detected changes made outside of terraform to %d resource(s):\n%s", len(drift), strings.Join(sections, "\n"))
+}
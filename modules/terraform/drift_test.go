@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const planJsonWithDrift = `
+{
+  "format_version": "1.0",
+  "resource_drift": [
+    {
+      "address": "aws_instance.web",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "i-1", "instance_type": "t3.micro", "tags": {"Name": "web"}, "user_data": "a"},
+        "after": {"id": "i-1", "instance_type": "t3.large", "tags": {"Name": "web", "Owner": "ops"}, "user_data": "b"},
+        "before_sensitive": {"user_data": true},
+        "after_sensitive": {"user_data": true}
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {
+        "actions": ["delete"],
+        "before": {"id": "logs"},
+        "after": null
+      }
+    }
+  ]
+}
+`
+
+func TestGetResourceDrift(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithDrift)
+	require.NoError(t, err)
+
+	drift := GetResourceDrift(plan)
+	require.Len(t, drift, 2)
+
+	assert.Equal(t, "aws_instance.web", drift[0].Address)
+	assert.True(t, drift[0].Actions.Update())
+	assert.Equal(t, []AttributeChange{
+		{Path: "instance_type", Before: "t3.micro", After: "t3.large", InBefore: true, InAfter: true},
+		{Path: "tags.Owner", After: "ops", InAfter: true},
+		{Path: "user_data", Before: SensitiveValuePlaceholder, After: SensitiveValuePlaceholder, InBefore: true, InAfter: true, Sensitive: true},
+	}, drift[0].AttributeChanges)
+
+	assert.Equal(t, "aws_s3_bucket.logs", drift[1].Address)
+	assert.True(t, drift[1].Actions.Delete())
+	assert.Contains(t, drift[1].String(), `- id: "logs"`)
+}
+
+func TestGetResourceDriftWithoutDrift(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+	assert.Empty(t, GetResourceDrift(plan))
+}
+
+func TestDetectDrift(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-drift", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	defer Destroy(t, options)
+	InitAndApply(t, options)
+
+	RequireNoDrift(t, options)
+
+	// Remove the file out of band, which terraform should detect as drift.
+	require.NoError(t, os.Remove(filepath.Join(testFolder, "drift.txt")))
+
+	drift := DetectDrift(t, options)
+	require.Len(t, drift, 1)
+	assert.Equal(t, "local_file.test", drift[0].Address)
+}
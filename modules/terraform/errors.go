@@ -3,6 +3,8 @@ package terraform
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // TgInvalidBinary occurs when a terragrunt function is called and the TerraformBinary is
@@ -88,6 +90,17 @@ func (err PanicWhileParsingVarFile) Error() string {
 	return fmt.Sprintf("Recovering panic while parsing '%s'. Got error of type '%v': %v", err.ConfigFile, reflect.TypeOf(err.RecoveredValue), err.RecoveredValue)
 }
 
+// PanicWhileInspectingModule is returned when the HCL parsing routine panics while InspectModuleE reads the
+// configuration of a module.
+type PanicWhileInspectingModule struct {
+	ModuleDir      string
+	RecoveredValue interface{}
+}
+
+func (err PanicWhileInspectingModule) Error() string {
+	return fmt.Sprintf("Recovering panic while inspecting the module in '%s'. Got error of type '%v': %v", err.ModuleDir, reflect.TypeOf(err.RecoveredValue), err.RecoveredValue)
+}
+
 // UnsupportedDefaultWorkspaceDeletion is returned when user tries to delete the workspace "default"
 type UnsupportedDefaultWorkspaceDeletion struct{}
 
@@ -101,3 +114,166 @@ type WorkspaceDoesNotExist string
 func (err WorkspaceDoesNotExist) Error() string {
 	return fmt.Sprintf("The workspace %q does not exist.", string(err))
 }
+
+// ResourceNotFoundInPlan is returned when the plan does not contain a resource with the given address.
+type ResourceNotFoundInPlan string
+
+func (address ResourceNotFoundInPlan) Error() string {
+	return fmt.Sprintf("The plan does not contain a resource with address %q", string(address))
+}
+
+// AttributeNotFoundInPlan is returned when the planned values of a resource do not contain an attribute at the given
+// path.
+type AttributeNotFoundInPlan struct {
+	Address string
+	Path    string
+}
+
+func (err AttributeNotFoundInPlan) Error() string {
+	return fmt.Sprintf("The planned values of resource %q do not contain an attribute at path %q", err.Address, err.Path)
+}
+
+// InvalidAttributePath is returned when an attribute path can't be parsed.
+type InvalidAttributePath string
+
+func (path InvalidAttributePath) Error() string {
+	return fmt.Sprintf("Invalid attribute path %q. Expected a path such as foo.bar[0][\"baz\"]", string(path))
+}
+
+// ExpectedDiagnosticNotFound is returned when terraform did not report a diagnostic that a test expected it to report.
+type ExpectedDiagnosticNotFound struct {
+	Expected    string
+	Diagnostics []Diagnostic
+}
+
+func (err ExpectedDiagnosticNotFound) Error() string {
+	if len(err.Diagnostics) == 0 {
+		return fmt.Sprintf("Expected terraform to report %s, but it did not report any diagnostics", err.Expected)
+	}
+	lines := make([]string, 0, len(err.Diagnostics))
+	for _, diagnostic := range err.Diagnostics {
+		lines = append(lines, diagnostic.String())
+	}
+	return fmt.Sprintf("Expected terraform to report %s, but it only reported:\n%s", err.Expected, strings.Join(lines, "\n"))
+}
+
+// UnknownFeature is returned when checking whether the binary supports a feature that terratest does not know about.
+type UnknownFeature string
+
+func (feature UnknownFeature) Error() string {
+	return fmt.Sprintf("Unknown feature %q", string(feature))
+}
+
+// UnsupportedFeature is returned when a function needs a feature that the binary running terraform commands does not
+// support.
+type UnsupportedFeature struct {
+	Feature string
+	Binary  BinaryInfo
+}
+
+func (err UnsupportedFeature) Error() string {
+	return fmt.Sprintf("%s does not support the %q feature", err.Binary, err.Feature)
+}
+
+// UnrecognizedVersionOutput is returned when the version of the binary running terraform commands can't be parsed
+// from the output of its version command.
+type UnrecognizedVersionOutput string
+
+func (output UnrecognizedVersionOutput) Error() string {
+	return fmt.Sprintf("Could not find the terraform or OpenTofu version in the output of the version command: %s", string(output))
+}
+
+// IsolatedStateNotSupported is returned when the IsolatedState option can't be used with the given options.
+type IsolatedStateNotSupported string
+
+func (reason IsolatedStateNotSupported) Error() string {
+	return fmt.Sprintf("IsolatedState is not supported: %s", string(reason))
+}
+
+// PluginDirLocked is returned when the lock on a plugin cache or provider mirror is not released in time.
+type PluginDirLocked string
+
+func (lockPath PluginDirLocked) Error() string {
+	return fmt.Sprintf("Timed out waiting for the lock %s to be released. Remove it if no test is running.", string(lockPath))
+}
+
+// OverrideNotSupported is returned when the BackendOverride or ProviderOverrides options can't be rendered to an
+// override file.
+type OverrideNotSupported string
+
+func (reason OverrideNotSupported) Error() string {
+	return fmt.Sprintf("Can't generate the override file: %s", string(reason))
+}
+
+// OverrideFileConflict is returned when parallel tests sharing a folder would generate override files with different
+// contents at the same path.
+type OverrideFileConflict string
+
+func (path OverrideFileConflict) Error() string {
+	return fmt.Sprintf("Another test uses the override file %s with different overrides. Use a copy of the folder for each test (e.g., with files.CopyTerraformFolderToTemp).", string(path))
+}
+
+// TgUnitSkipped is the error of a unit of a terragrunt stack that terragrunt skipped because a unit it depends on (or,
+// when destroying, that depends on it) failed.
+type TgUnitSkipped string
+
+func (unit TgUnitSkipped) Error() string {
+	return fmt.Sprintf("Terragrunt skipped unit %s because a unit it depends on failed", string(unit))
+}
+
+// TgStackFailed is returned when the command failed in some units of a terragrunt stack. It maps the path of each of
+// those units to its error.
+type TgStackFailed map[string]error
+
+func (failed TgStackFailed) Error() string {
+	paths := make([]string, 0, len(failed))
+	for path := range failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		lines = append(lines, fmt.Sprintf("%s: %v", path, failed[path]))
+	}
+	return fmt.Sprintf("The command failed in %d terragrunt unit(s):\n%s", len(failed), strings.Join(lines, "\n"))
+}
+
+// UnrecognizedGraphOutput is returned when the output of terraform graph is not a DOT graph.
+type UnrecognizedGraphOutput string
+
+func (output UnrecognizedGraphOutput) Error() string {
+	return fmt.Sprintf("Expected terraform graph to output a DOT graph, but got: %s", string(output))
+}
+
+// PlanGuardNotSupported is returned when a PlanGuard is set on the Options of a function that applies without a plan
+// that can be checked first (e.g., terragrunt run-all apply).
+type PlanGuardNotSupported string
+
+func (function PlanGuardNotSupported) Error() string {
+	return fmt.Sprintf("%s does not support PlanGuard, as it can't check the plan before applying it. Unset PlanGuard, or check the plan with PlanGuard.Check first.", string(function))
+}
+
+// PlanGuardViolations is returned when a plan violates the PlanGuard set on the Options, in which case it is not
+// applied.
+type PlanGuardViolations []PlanGuardViolation
+
+func (violations PlanGuardViolations) Error() string {
+	lines := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		lines = append(lines, "  - "+violation.String())
+	}
+	return fmt.Sprintf("The plan violates the PlanGuard in %d place(s), so it was not applied:\n%s", len(violations), strings.Join(lines, "\n"))
+}
+
+// NotIdempotent is returned when terraform plans changes right after apply, other than the changes allowed by the
+// IdempotencyExceptions of the Options.
+type NotIdempotent []IdempotencyChange
+
+func (changes NotIdempotent) Error() string {
+	sections := make([]string, 0, len(changes))
+	for _, change := range changes {
+		sections = append(sections, change.String())
+	}
+	return fmt.Sprintf("terraform configuration not idempotent: the plan after apply changes %d address(es):\n%s", len(changes), strings.Join(sections, "\n"))
+}
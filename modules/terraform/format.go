@@ -3,6 +3,7 @@ package terraform
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -36,8 +37,17 @@ var TerraformCommandsWithPlanFileSupport = []string{
 	"graph",
 }
 
+// TerraformCommandsWithJsonOutputSupport is a list of all the Terraform commands that can stream their output as
+// machine readable json events with the -json flag.
+var TerraformCommandsWithJsonOutputSupport = []string{
+	"plan",
+	"apply",
+	"destroy",
+	"refresh",
+}
+
 // FormatArgs converts the inputs to a format palatable to terraform. This includes converting the given vars to the
-// format the Terraform CLI expects (-var key=value).
+// format the Terraform CLI expects (-var key=value, or -var-file pointing at a generated file if VarsAsFile is set).
 func FormatArgs(options *Options, args ...string) []string {
 	var terraformArgs []string
 	commandType := args[0]
@@ -49,6 +59,7 @@ func FormatArgs(options *Options, args ...string) []string {
 	}
 	lockSupported := collections.ListContains(TerraformCommandsWithLockSupport, commandType)
 	planFileSupported := collections.ListContains(TerraformCommandsWithPlanFileSupport, commandType)
+	jsonOutputSupported := collections.ListContains(TerraformCommandsWithJsonOutputSupport, commandType)
 
 	// Include -var and -var-file flags unless we're running 'apply' with a plan file
 	includeVars := !(commandType == "apply" && len(options.PlanFilePath) > 0)
@@ -58,9 +69,9 @@ func FormatArgs(options *Options, args ...string) []string {
 	if includeVars {
 		if options.SetVarsAfterVarFiles {
 			terraformArgs = append(terraformArgs, FormatTerraformArgs("-var-file", options.VarFiles)...)
-			terraformArgs = append(terraformArgs, FormatTerraformVarsAsArgs(options.Vars)...)
+			terraformArgs = append(terraformArgs, formatVarsAsArgs(options)...)
 		} else {
-			terraformArgs = append(terraformArgs, FormatTerraformVarsAsArgs(options.Vars)...)
+			terraformArgs = append(terraformArgs, formatVarsAsArgs(options)...)
 			terraformArgs = append(terraformArgs, FormatTerraformArgs("-var-file", options.VarFiles)...)
 		}
 	}
@@ -71,6 +82,10 @@ func FormatArgs(options *Options, args ...string) []string {
 		terraformArgs = append(terraformArgs, "-no-color")
 	}
 
+	if options.JsonOutput && jsonOutputSupported {
+		terraformArgs = append(terraformArgs, "-json")
+	}
+
 	if lockSupported {
 		// If command supports locking, handle lock arguments
 		terraformArgs = append(terraformArgs, FormatTerraformLockAsArgs(options.Lock, options.LockTimeout)...)
@@ -105,6 +120,8 @@ func FormatTerraformVarsAsArgs(vars map[string]interface{}) []string {
 
 // FormatTerraformLockAsArgs formats the lock and lock-timeout variables
 // -lock, -lock-timeout
+// Unlike -migrate-state or -refresh-only, these flags don't depend on the binary: every terraform release since 0.9
+// and every OpenTofu release supports them.
 func FormatTerraformLockAsArgs(lockCheck bool, lockTimeout string) []string {
 	lockArgs := []string{fmt.Sprintf("-lock=%v", lockCheck)}
 	if lockTimeout != "" {
@@ -233,12 +250,17 @@ func sliceToHclString(slice []interface{}) string {
 	return fmt.Sprintf("[%s]", strings.Join(hclValues, ", "))
 }
 
-// Convert a map to an HCL string. See ToHclString for details.
+// Convert a map to an HCL string, with the keys in sorted order. See ToHclString for details.
 func mapToHclString(m map[string]interface{}) string {
 	keyValuePairs := []string{}
 
-	for key, value := range m {
-		keyValuePair := fmt.Sprintf(`"%s" = %s`, key, toHclString(value, true))
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyValuePair := fmt.Sprintf(`"%s" = %s`, key, toHclString(m[key], true))
 		keyValuePairs = append(keyValuePairs, keyValuePair)
 	}
 
@@ -0,0 +1,373 @@
+package terraform
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// DependencyGraph is the dependency graph of a module, as reported by terraform graph. The nodes are the addresses
+// of the objects of the module (e.g., aws_instance.web, module.vpc.aws_subnet.private, data.aws_ami.ubuntu,
+// var.name, provider["registry.terraform.io/hashicorp/aws"]), without instance keys, as the graph is built before
+// count and for_each are expanded.
+type DependencyGraph struct {
+	// dependencies maps each node to the nodes it depends on directly.
+	dependencies map[string]map[string]bool
+
+	// dependents maps each node to the nodes that depend on it directly.
+	dependents map[string]map[string]bool
+}
+
+// Graph runs terraform graph with the given options and returns the parsed dependency graph of the plan. Note that
+// terraform init must run first. This will fail the test if there is an error.
+func Graph(t testing.TestingT, options *Options) *DependencyGraph {
+	graph, err := GraphE(t, options)
+	require.NoError(t, err)
+	return graph
+}
+
+// GraphE runs terraform graph with the given options and returns the parsed dependency graph of the plan. Note that
+// terraform init must run first.
+func GraphE(t testing.TestingT, options *Options) (*DependencyGraph, error) {
+	out, err := RunTerraformCommandAndGetStdoutE(t, options, "graph", "-type=plan")
+	if err != nil {
+		return nil, err
+	}
+	return ParseGraph(out)
+}
+
+var (
+	// dotIdPattern matches a quoted DOT id, which may contain escaped quotes (e.g., provider[\"...\"]).
+	dotIdPattern     = `"((?:[^"\\]|\\.)*)"`
+	dotEdgeRegexp    = regexp.MustCompile(`^\s*` + dotIdPattern + `\s*->\s*` + dotIdPattern)
+	dotNodeRegexp    = regexp.MustCompile(`^\s*` + dotIdPattern + `\s*(\[|;|$)`)
+	nodeSuffixRegexp = regexp.MustCompile(`\s+\([^()]*\)$`)
+)
+
+// ParseGraph parses the DOT output of terraform graph into a dependency graph. This supports the graphs of all
+// terraform versions, where the node names are either plain addresses or prefixed with the module path (e.g.,
+// "[root] aws_instance.web (expand)"). Those prefixes and suffixes are removed, so that the nodes are addresses.
+func ParseGraph(dot string) (*DependencyGraph, error) {
+	if !strings.Contains(dot, "digraph") {
+		return nil, UnrecognizedGraphOutput(dot)
+	}
+
+	graph := &DependencyGraph{
+		dependencies: map[string]map[string]bool{},
+		dependents:   map[string]map[string]bool{},
+	}
+	for _, line := range strings.Split(dot, "\n") {
+		if matches := dotEdgeRegexp.FindStringSubmatch(line); matches != nil {
+			from, keepFrom := graphNodeAddress(matches[1])
+			to, keepTo := graphNodeAddress(matches[2])
+			if keepFrom && keepTo {
+				graph.addEdge(from, to)
+			}
+		} else if matches := dotNodeRegexp.FindStringSubmatch(line); matches != nil {
+			if address, keep := graphNodeAddress(matches[1]); keep {
+				graph.addNode(address)
+			}
+		}
+	}
+	delete(graph.dependencies, "root")
+	delete(graph.dependents, "root")
+	for _, dependents := range graph.dependents {
+		delete(dependents, "root")
+	}
+	return graph, nil
+}
+
+// graphNodeAddress returns the address of the object of the given DOT node, removing the module path prefix and the
+// operation suffix of older terraform versions (e.g., "[root] aws_instance.web (expand)" becomes aws_instance.web).
+// This returns false for the nodes of those versions that close or destroy an object, as they depend on the objects
+// that depend on the object, which would otherwise show up as cycles.
+func graphNodeAddress(node string) (string, bool) {
+	address := strings.ReplaceAll(node, `\"`, `"`)
+	address = strings.TrimPrefix(address, "[root] ")
+	if suffix := nodeSuffixRegexp.FindString(address); suffix != "" {
+		operation := strings.Trim(suffix, " ()")
+		if operation == "close" || strings.HasPrefix(operation, "destroy") {
+			return "", false
+		}
+		address = strings.TrimSuffix(address, suffix)
+	}
+	return address, true
+}
+
+func (graph *DependencyGraph) addNode(address string) {
+	if _, hasNode := graph.dependencies[address]; !hasNode {
+		graph.dependencies[address] = map[string]bool{}
+		graph.dependents[address] = map[string]bool{}
+	}
+}
+
+func (graph *DependencyGraph) addEdge(from string, to string) {
+	graph.addNode(from)
+	graph.addNode(to)
+	// The expand and close nodes of the same object depend on each other, which is not a dependency between objects.
+	if from != to {
+		graph.dependencies[from][to] = true
+		graph.dependents[to][from] = true
+	}
+}
+
+// normalizeGraphAddress removes the instance keys from the given resource address (e.g., aws_instance.web[0] becomes
+// aws_instance.web), so that addresses from plans and state can be looked up in the graph.
+func normalizeGraphAddress(address string) string {
+	if strings.HasPrefix(address, "provider[") {
+		return address
+	}
+	steps := splitAddressSteps(address)
+	for i, step := range steps {
+		if index := strings.Index(step, "["); index >= 0 {
+			steps[i] = step[:index]
+		}
+	}
+	return strings.Join(steps, ".")
+}
+
+// Nodes returns the addresses of all the nodes of the graph, sorted.
+func (graph *DependencyGraph) Nodes() []string {
+	return sortedKeys(graph.dependencies)
+}
+
+// HasNode returns true if the graph has a node for the given address. Instance keys are ignored, so this accepts the
+// addresses of a PlanStruct (e.g., aws_instance.web[0]).
+func (graph *DependencyGraph) HasNode(address string) bool {
+	_, hasNode := graph.dependencies[normalizeGraphAddress(address)]
+	return hasNode
+}
+
+// DirectDependencies returns the addresses the given address depends on directly, sorted.
+func (graph *DependencyGraph) DirectDependencies(address string) []string {
+	return sortedKeys(graph.dependencies[normalizeGraphAddress(address)])
+}
+
+// DirectDependents returns the addresses that depend directly on the given address, sorted.
+func (graph *DependencyGraph) DirectDependents(address string) []string {
+	return sortedKeys(graph.dependents[normalizeGraphAddress(address)])
+}
+
+// Dependencies returns all the addresses the given address depends on, directly or not, sorted.
+func (graph *DependencyGraph) Dependencies(address string) []string {
+	return sortedKeys(graph.reachable(normalizeGraphAddress(address), graph.dependencies))
+}
+
+// Dependents returns all the addresses that depend on the given address, directly or not, sorted.
+func (graph *DependencyGraph) Dependents(address string) []string {
+	return sortedKeys(graph.reachable(normalizeGraphAddress(address), graph.dependents))
+}
+
+// DependsOn returns true if the object at the from address depends on the object at the to address, directly or not.
+// E.g., DependsOn("aws_s3_bucket.logs", "aws_kms_key.logs").
+func (graph *DependencyGraph) DependsOn(from string, to string) bool {
+	return graph.Path(from, to) != nil
+}
+
+// Path returns the shortest chain of dependencies from the from address to the to address, including both, or nil if
+// from does not depend on to.
+func (graph *DependencyGraph) Path(from string, to string) []string {
+	from = normalizeGraphAddress(from)
+	to = normalizeGraphAddress(to)
+	if _, hasNode := graph.dependencies[from]; !hasNode || from == to {
+		return nil
+	}
+
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range sortedKeys(graph.dependencies[current]) {
+			if _, visited := previous[next]; visited {
+				continue
+			}
+			previous[next] = current
+			if next == to {
+				path := []string{to}
+				for node := current; node != ""; node = previous[node] {
+					path = append([]string{node}, path...)
+				}
+				return path
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+// Cycles returns the groups of addresses that depend on each other in a cycle (each group sorted, and the groups
+// sorted by their first address). Terraform refuses to plan a module whose objects depend on each other in a cycle,
+// so this is mostly useful with the graphs returned by Subgraph and ModuleGraph.
+func (graph *DependencyGraph) Cycles() [][]string {
+	// Tarjan's strongly connected components algorithm.
+	index := 0
+	indexes := map[string]int{}
+	lowLinks := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var cycles [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		indexes[node] = index
+		lowLinks[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range sortedKeys(graph.dependencies[node]) {
+			if _, visited := indexes[next]; !visited {
+				visit(next)
+				if lowLinks[next] < lowLinks[node] {
+					lowLinks[node] = lowLinks[next]
+				}
+			} else if onStack[next] && indexes[next] < lowLinks[node] {
+				lowLinks[node] = indexes[next]
+			}
+		}
+
+		if lowLinks[node] == indexes[node] {
+			var component []string
+			for {
+				last := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[last] = false
+				component = append(component, last)
+				if last == node {
+					break
+				}
+			}
+			if len(component) > 1 {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	for _, node := range graph.Nodes() {
+		if _, visited := indexes[node]; !visited {
+			visit(node)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// Subgraph returns the graph of the nodes whose address matches the given pattern (see MatchResourceAddress). A node
+// of the subgraph depends on another one if it does in the graph, either directly or through nodes that are left out.
+// E.g., Subgraph("aws_*.*") returns how the aws resources of the root module depend on each other, through variables,
+// locals and data sources.
+func (graph *DependencyGraph) Subgraph(pattern string) *DependencyGraph {
+	return graph.collapse(func(address string) string {
+		if MatchResourceAddress(pattern, address) {
+			return address
+		}
+		return ""
+	})
+}
+
+// ModuleGraph returns the graph of the module calls of the module (e.g., module.vpc or module.app.module.db), where a
+// module depends on another one if any of its objects does. Objects of the root module are left out, but dependencies
+// through them are kept. This is useful to check that modules don't depend on each other in a cycle, which terraform
+// allows as long as the objects of the modules don't:
+//
+//	assert.Empty(t, terraform.Graph(t, options).ModuleGraph().Cycles())
+func (graph *DependencyGraph) ModuleGraph() *DependencyGraph {
+	return graph.collapse(moduleOfAddress)
+}
+
+// moduleOfAddress returns the path of the module call the given address is in (e.g., module.vpc for
+// module.vpc.aws_subnet.private), or an empty string for the root module.
+func moduleOfAddress(address string) string {
+	steps := splitAddressSteps(address)
+	var module []string
+	for i := 0; i+1 < len(steps) && steps[i] == "module"; i += 2 {
+		// Drop the instance keys of the module call, if any.
+		name := steps[i+1]
+		if index := strings.Index(name, "["); index >= 0 {
+			name = name[:index]
+		}
+		module = append(module, "module", name)
+	}
+	return strings.Join(module, ".")
+}
+
+// collapse returns the graph whose nodes are the groups the given function maps each node to, where a group depends
+// on another one if any of its nodes depends on a node of the other group, directly or through nodes the function
+// maps to an empty string (which are left out).
+func (graph *DependencyGraph) collapse(group func(address string) string) *DependencyGraph {
+	collapsed := &DependencyGraph{
+		dependencies: map[string]map[string]bool{},
+		dependents:   map[string]map[string]bool{},
+	}
+	for _, node := range graph.Nodes() {
+		from := group(node)
+		if from == "" {
+			continue
+		}
+		collapsed.addNode(from)
+
+		// Follow the dependencies of the node until they reach a node that belongs to a group.
+		visited := map[string]bool{node: true}
+		queue := sortedKeys(graph.dependencies[node])
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if visited[current] {
+				continue
+			}
+			visited[current] = true
+			if to := group(current); to != "" {
+				collapsed.addEdge(from, to)
+				continue
+			}
+			queue = append(queue, sortedKeys(graph.dependencies[current])...)
+		}
+	}
+	return collapsed
+}
+
+// reachable returns the nodes that can be reached from the given node by following the given edges.
+func (graph *DependencyGraph) reachable(node string, edges map[string]map[string]bool) map[string]bool {
+	reached := map[string]bool{}
+	queue := []string{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next := range edges[current] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	delete(reached, node)
+	return reached
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AssertDependsOn checks that the object at the from address depends on the object at the to address, directly or
+// not, failing the test if it does not.
+func AssertDependsOn(t testing.TestingT, graph *DependencyGraph, from string, to string) {
+	assert.True(t, graph.DependsOn(from, to), "Expected %s to depend on %s, but it only depends on %v", from, to, graph.Dependencies(from))
+}
+
+// RequireDependsOn checks that the object at the from address depends on the object at the to address, directly or
+// not, failing and halting the test if it does not.
+func RequireDependsOn(t testing.TestingT, graph *DependencyGraph, from string, to string) {
+	require.True(t, graph.DependsOn(from, to), "Expected %s to depend on %s, but it only depends on %v", from, to, graph.Dependencies(from))
+}
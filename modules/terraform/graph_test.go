@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyGraphOutput is the output of terraform graph -type=plan for the terraform-graph fixture, in the format used by
+// terraform versions before 1.7.
+const legacyGraphOutput = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] module.app.terraform_data.app (expand)" [label = "module.app.terraform_data.app", shape = "box"]
+		"[root] module.storage.terraform_data.bucket (expand)" [label = "module.storage.terraform_data.bucket", shape = "box"]
+		"[root] provider[\"terraform.io/builtin/terraform\"]" [label = "provider[\"terraform.io/builtin/terraform\"]", shape = "diamond"]
+		"[root] terraform_data.kms_key (expand)" [label = "terraform_data.kms_key", shape = "box"]
+		"[root] module.app (close)" -> "[root] module.app.output.app (expand)"
+		"[root] module.app (expand)" -> "[root] module.storage.output.bucket (expand)"
+		"[root] module.app.output.app (expand)" -> "[root] module.app.terraform_data.app (expand)"
+		"[root] module.app.terraform_data.app (expand)" -> "[root] module.app.var.bucket (expand)"
+		"[root] module.app.terraform_data.app (expand)" -> "[root] provider[\"terraform.io/builtin/terraform\"]"
+		"[root] module.app.var.bucket (expand)" -> "[root] module.app (expand)"
+		"[root] module.storage.output.bucket (expand)" -> "[root] module.storage.terraform_data.bucket (expand)"
+		"[root] module.storage.terraform_data.bucket (expand)" -> "[root] module.storage.var.key_id (expand)"
+		"[root] module.storage.terraform_data.bucket (expand)" -> "[root] provider[\"terraform.io/builtin/terraform\"]"
+		"[root] module.storage.var.key_id (expand)" -> "[root] module.storage (expand)"
+		"[root] module.storage.var.key_id (expand)" -> "[root] terraform_data.kms_key (expand)"
+		"[root] output.app (expand)" -> "[root] module.app.output.app (expand)"
+		"[root] provider[\"terraform.io/builtin/terraform\"] (close)" -> "[root] module.app.terraform_data.app (expand)"
+		"[root] root" -> "[root] output.app (expand)"
+		"[root] root" -> "[root] provider[\"terraform.io/builtin/terraform\"] (close)"
+		"[root] terraform_data.kms_key (expand)" -> "[root] provider[\"terraform.io/builtin/terraform\"]"
+	}
+}
+`
+
+// graphOutput is the output of terraform graph in the format used by terraform 1.7 and newer.
+const graphOutput = `digraph G {
+  rankdir = "RL";
+  node [shape = rect, fontname = "sans-serif"];
+  "terraform_data.kms_key" [label="terraform_data.kms_key"];
+  subgraph "cluster_module.storage" {
+    label = "module.storage"
+    fontname = "sans-serif"
+    "module.storage.terraform_data.bucket" [label="terraform_data.bucket"];
+  }
+  subgraph "cluster_module.app" {
+    label = "module.app"
+    fontname = "sans-serif"
+    "module.app.terraform_data.app" [label="terraform_data.app"];
+  }
+  "module.storage.terraform_data.bucket" -> "terraform_data.kms_key";
+  "module.app.terraform_data.app" -> "module.storage.terraform_data.bucket";
+}
+`
+
+func TestParseLegacyGraph(t *testing.T) {
+	t.Parallel()
+
+	graph, err := ParseGraph(legacyGraphOutput)
+	require.NoError(t, err)
+
+	provider := `provider["terraform.io/builtin/terraform"]`
+	assert.False(t, graph.HasNode("root"))
+	assert.True(t, graph.HasNode(provider))
+	assert.True(t, graph.HasNode("module.app.terraform_data.app[0]"))
+	assert.Equal(t, []string{"module.storage.var.key_id", provider}, graph.DirectDependencies("module.storage.terraform_data.bucket"))
+	assert.Equal(t, []string{"module.storage.var.key_id"}, graph.DirectDependents("terraform_data.kms_key"))
+
+	AssertDependsOn(t, graph, "module.app.terraform_data.app", "terraform_data.kms_key")
+	assert.False(t, graph.DependsOn("terraform_data.kms_key", "module.app.terraform_data.app"))
+	assert.Equal(t, []string{
+		"module.app.terraform_data.app",
+		"module.app.var.bucket",
+		"module.app",
+		"module.storage.output.bucket",
+		"module.storage.terraform_data.bucket",
+		"module.storage.var.key_id",
+		"terraform_data.kms_key",
+	}, graph.Path("module.app.terraform_data.app", "terraform_data.kms_key"))
+
+	assert.Contains(t, graph.Dependents("terraform_data.kms_key"), "output.app")
+	assert.Empty(t, graph.Cycles())
+}
+
+func TestParseGraph(t *testing.T) {
+	t.Parallel()
+
+	graph, err := ParseGraph(graphOutput)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"module.app.terraform_data.app",
+		"module.storage.terraform_data.bucket",
+		"terraform_data.kms_key",
+	}, graph.Nodes())
+	assert.Equal(t, []string{"module.storage.terraform_data.bucket", "terraform_data.kms_key"}, graph.Dependencies("module.app.terraform_data.app"))
+	assert.Equal(t, []string{"module.app.terraform_data.app", "module.storage.terraform_data.bucket"}, graph.Dependents("terraform_data.kms_key"))
+}
+
+func TestParseGraphWithoutDot(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseGraph("Error: no configuration files")
+	assert.IsType(t, UnrecognizedGraphOutput(""), err)
+}
+
+func TestModuleGraphCycles(t *testing.T) {
+	t.Parallel()
+
+	// The resources of the modules don't depend on each other in a cycle, but the modules do.
+	graph, err := ParseGraph(`digraph {
+		"module.a.aws_s3_bucket.logs" -> "module.a.var.kms_key_arn"
+		"module.a.var.kms_key_arn" -> "module.b.output.kms_key_arn"
+		"module.b.output.kms_key_arn" -> "module.b.aws_kms_key.key"
+		"module.b.aws_iam_policy.read_logs" -> "module.b.var.bucket_arn"
+		"module.b.var.bucket_arn" -> "module.a.output.bucket_arn"
+		"module.a.output.bucket_arn" -> "module.a.aws_s3_bucket.logs"
+		"aws_instance.web" -> "module.b.output.kms_key_arn"
+	}`)
+	require.NoError(t, err)
+	assert.Empty(t, graph.Cycles())
+
+	modules := graph.ModuleGraph()
+	assert.Equal(t, []string{"module.a", "module.b"}, modules.Nodes())
+	assert.Equal(t, [][]string{{"module.a", "module.b"}}, modules.Cycles())
+
+	resources := graph.Subgraph("*.*")
+	assert.Equal(t, []string{"aws_instance.web"}, resources.Nodes())
+	buckets := graph.Subgraph("module.*.aws_*.*")
+	assert.Equal(t, []string{"module.a.aws_s3_bucket.logs"}, buckets.DirectDependents("module.b.aws_kms_key.key"))
+}
+
+func TestGraph(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-graph", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{TerraformDir: testFolder}
+	Init(t, options)
+
+	graph := Graph(t, options)
+	RequireDependsOn(t, graph, "module.app.terraform_data.app", "terraform_data.kms_key")
+	assert.False(t, graph.DependsOn("module.storage.terraform_data.bucket", "module.app.terraform_data.app"))
+	assert.Empty(t, graph.ModuleGraph().Cycles())
+}
@@ -0,0 +1,127 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// IdempotencyCause is the reason terraform plans to change a resource right after it was applied.
+type IdempotencyCause string
+
+const (
+	// IdempotencyCauseDrift means terraform found that the resource changed after it was applied, when refreshing it
+	// (e.g., the API normalized a value, or something outside of terraform modified the resource).
+	IdempotencyCauseDrift IdempotencyCause = "drift"
+
+	// IdempotencyCausePerpetualDiff means the configuration plans a different value on every run (e.g., a value
+	// computed with timestamp()), although the resource did not change since it was applied.
+	IdempotencyCausePerpetualDiff IdempotencyCause = "perpetual diff"
+)
+
+// IdempotencyException allows changes that are known to occur on every plan, so that the idempotency check can be
+// adopted on modules that are not (yet) fully idempotent.
+type IdempotencyException struct {
+	// The address of the resource, or a pattern as supported by MatchResourceAddress (e.g., aws_instance.web[*]).
+	// Outputs are addressed as output.<name>.
+	Address string
+
+	// The paths of the attributes that are allowed to change, in the syntax of GetPlannedAttribute. A path also
+	// allows changes to the attributes nested in it (e.g., tags allows tags.LastModified). If empty, any change to
+	// the resource is allowed.
+	Attributes []string
+}
+
+// IdempotencyChange is a change terraform plans right after apply.
+type IdempotencyChange struct {
+	// The full address of the resource (e.g., module.foo.aws_instance.bar), or output.<name> for an output.
+	Address string
+
+	Actions tfjson.Actions
+	Cause   IdempotencyCause
+
+	// The attributes that would change. Attributes allowed by an IdempotencyException are not included.
+	AttributeChanges []AttributeChange
+}
+
+// String renders the change as a human readable diff.
+func (change IdempotencyChange) String() string {
+	return fmt.Sprintf("%s (%v, %s):\n%s", change.Address, change.Actions, change.Cause, formatAttributeChanges(change.AttributeChanges))
+}
+
+// GetIdempotencyChanges returns the changes the given plan, made right after apply, would make, sorted by address.
+// Changes allowed by the given exceptions are left out. If the plan makes no other changes, this returns an empty
+// list.
+func GetIdempotencyChanges(plan *PlanStruct, exceptions []IdempotencyException) []IdempotencyChange {
+	out := []IdempotencyChange{}
+	for _, address := range sortedResourceChangeAddresses(plan) {
+		resourceChange := plan.ResourceChangesMap[address]
+		if resourceChange.Change == nil || resourceChange.Change.Actions.NoOp() || resourceChange.Change.Actions.Read() {
+			continue
+		}
+		cause := IdempotencyCausePerpetualDiff
+		if drift, hasDrift := plan.ResourceDriftMap[address]; hasDrift && drift.Change != nil && !drift.Change.Actions.NoOp() {
+			cause = IdempotencyCauseDrift
+		}
+		if change, isChanged := newIdempotencyChange(address, resourceChange.Change, cause, exceptions); isChanged {
+			out = append(out, change)
+		}
+	}
+
+	outputNames := make([]string, 0, len(plan.RawPlan.OutputChanges))
+	for name := range plan.RawPlan.OutputChanges {
+		outputNames = append(outputNames, name)
+	}
+	sort.Strings(outputNames)
+	for _, name := range outputNames {
+		outputChange := plan.RawPlan.OutputChanges[name]
+		if outputChange == nil || outputChange.Actions.NoOp() {
+			continue
+		}
+		if change, isChanged := newIdempotencyChange("output."+name, outputChange, IdempotencyCausePerpetualDiff, exceptions); isChanged {
+			out = append(out, change)
+		}
+	}
+	return out
+}
+
+// newIdempotencyChange returns the change to the given address, without the attribute changes allowed by the given
+// exceptions. This returns false if all the changes are allowed.
+func newIdempotencyChange(address string, change *tfjson.Change, cause IdempotencyCause, exceptions []IdempotencyException) (IdempotencyChange, bool) {
+	var allowedAttributes []string
+	for _, exception := range exceptions {
+		if !MatchResourceAddress(exception.Address, address) {
+			continue
+		}
+		if len(exception.Attributes) == 0 {
+			return IdempotencyChange{}, false
+		}
+		allowedAttributes = append(allowedAttributes, exception.Attributes...)
+	}
+
+	attributeChanges := GetAttributeChanges(change)
+	remaining := []AttributeChange{}
+	for _, attributeChange := range attributeChanges {
+		if !isAttributeAllowed(attributeChange.Path, allowedAttributes) {
+			remaining = append(remaining, attributeChange)
+		}
+	}
+	// A change without attribute changes (e.g., a replacement forced by replace_triggered_by) can only be allowed by an
+	// exception on the whole resource.
+	if len(attributeChanges) > 0 && len(remaining) == 0 {
+		return IdempotencyChange{}, false
+	}
+	return IdempotencyChange{Address: address, Actions: change.Actions, Cause: cause, AttributeChanges: remaining}, true
+}
+
+// isAttributeAllowed returns true if the attribute at the given path is, or is nested in, one of the given attributes.
+func isAttributeAllowed(path string, allowedAttributes []string) bool {
+	for _, allowed := range allowedAttributes {
+		if path == allowed || strings.HasPrefix(path, allowed+".") || strings.HasPrefix(path, allowed+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package terraform
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planJsonAfterApply is a hand written plan, as made right after apply, where the tags of a bucket drifted and a
+// resource and an output change on every plan.
+const planJsonAfterApply = `
+{
+  "format_version": "1.0",
+  "terraform_version": "1.5.7",
+  "resource_drift": [
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {"actions": ["update"], "before": {"tags": {"Env": "test"}}, "after": {"tags": {"Env": "test", "Owner": "ops"}}}
+    }
+  ],
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {"actions": ["update"], "before": {"bucket": "logs", "tags": {"Env": "test", "Owner": "ops"}}, "after": {"bucket": "logs", "tags": {"Env": "test"}}}
+    },
+    {
+      "address": "null_resource.timestamp",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "timestamp",
+      "change": {"actions": ["delete", "create"], "before": {"triggers": {"time": "2023-01-01T00:00:00Z"}}, "after": {"triggers": {}}, "after_unknown": {"triggers": {"time": true}}}
+    },
+    {
+      "address": "aws_instance.web",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "change": {"actions": ["no-op"], "before": {"instance_type": "t3.micro"}, "after": {"instance_type": "t3.micro"}}
+    }
+  ],
+  "output_changes": {
+    "applied_at": {"actions": ["update"], "before": "2023-01-01T00:00:00Z", "after_unknown": true},
+    "bucket": {"actions": ["no-op"], "before": "logs", "after": "logs"}
+  }
+}
+`
+
+func TestGetIdempotencyChanges(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonAfterApply)
+	require.NoError(t, err)
+
+	changes := GetIdempotencyChanges(plan, nil)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, "aws_s3_bucket.logs", changes[0].Address)
+	assert.Equal(t, IdempotencyCauseDrift, changes[0].Cause)
+	assert.Equal(t, []AttributeChange{
+		{Path: "tags.Owner", Before: "ops", InBefore: true},
+	}, changes[0].AttributeChanges)
+
+	assert.Equal(t, "null_resource.timestamp", changes[1].Address)
+	assert.Equal(t, tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}, changes[1].Actions)
+	assert.Equal(t, IdempotencyCausePerpetualDiff, changes[1].Cause)
+	assert.Equal(t, "null_resource.timestamp ([delete create], perpetual diff):\n  + triggers: {}\n  ~ triggers.time: \"2023-01-01T00:00:00Z\" -> (known after apply)", changes[1].String())
+
+	assert.Equal(t, "output.applied_at", changes[2].Address)
+}
+
+func TestGetIdempotencyChangesWithExceptions(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonAfterApply)
+	require.NoError(t, err)
+
+	changes := GetIdempotencyChanges(plan, []IdempotencyException{
+		{Address: "aws_s3_bucket.*", Attributes: []string{"tags"}},
+		{Address: "null_resource.timestamp", Attributes: []string{"triggers.other"}},
+		{Address: "output.applied_at"},
+	})
+	require.Len(t, changes, 1)
+	assert.Equal(t, "null_resource.timestamp", changes[0].Address)
+
+	changes = GetIdempotencyChanges(plan, []IdempotencyException{
+		{Address: "aws_s3_bucket.logs"},
+		{Address: "null_resource.timestamp", Attributes: []string{"triggers"}},
+		{Address: "output.*"},
+	})
+	assert.Empty(t, changes)
+}
+
+func TestApplyAndIdempotentReportsChanges(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakePlanBinary(t, planJsonAfterApply)
+	options := &Options{
+		TerraformDir:          t.TempDir(),
+		TerraformBinary:       binaryPath,
+		IdempotencyExceptions: []IdempotencyException{{Address: "aws_s3_bucket.logs"}},
+	}
+	_, err := ApplyAndIdempotentE(t, options)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "terraform configuration not idempotent: the plan after apply changes 2 address(es):\nnull_resource.timestamp")
+
+	var notIdempotent NotIdempotent
+	require.ErrorAs(t, err, &notIdempotent)
+	assert.Equal(t, "output.applied_at", notIdempotent[1].Address)
+	assert.Equal(t, []string{"apply", "plan"}, readInvocations(t, logPath))
+}
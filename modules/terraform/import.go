@@ -0,0 +1,46 @@
+package terraform
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// Import runs terraform import with the given options to import the existing infrastructure object with the given id
+// into the resource at the given address, and returns stdout/stderr. This will fail the test if there is an error in
+// the command.
+func Import(t testing.TestingT, options *Options, address string, id string) string {
+	out, err := ImportE(t, options, address, id)
+	require.NoError(t, err)
+	return out
+}
+
+// ImportE runs terraform import with the given options to import the existing infrastructure object with the given id
+// into the resource at the given address, and returns stdout/stderr. Vars and VarFiles are passed to the command, but
+// Targets are ignored as import does not support them.
+func ImportE(t testing.TestingT, options *Options, address string, id string) (string, error) {
+	// import does not accept -target, so we format the args from a copy of the options without targets.
+	importOptions := *options
+	importOptions.Targets = nil
+
+	args := FormatArgs(&importOptions, "import", "-input=false")
+	args = append(args, address, id)
+	return RunTerraformCommandE(t, options, args...)
+}
+
+// InitAndImport runs terraform init and import with the given options and returns stdout/stderr from the import
+// command. This will fail the test if there is an error in the command.
+func InitAndImport(t testing.TestingT, options *Options, address string, id string) string {
+	out, err := InitAndImportE(t, options, address, id)
+	require.NoError(t, err)
+	return out
+}
+
+// InitAndImportE runs terraform init and import with the given options and returns stdout/stderr from the import
+// command.
+func InitAndImportE(t testing.TestingT, options *Options, address string, id string) (string, error) {
+	if _, err := InitE(t, options); err != nil {
+		return "", err
+	}
+
+	return ImportE(t, options, address, id)
+}
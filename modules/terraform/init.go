@@ -2,6 +2,7 @@ package terraform
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/gruntwork-io/terratest/modules/testing"
 )
@@ -17,15 +18,29 @@ func Init(t testing.TestingT, options *Options) string {
 
 // InitE calls terraform init and return stdout/stderr.
 func InitE(t testing.TestingT, options *Options) (string, error) {
+	// The isolated state is set up before the backend config is formatted, as it points the backend at the state file
+	// of the test. The override files must exist before init, as they may change the backend and providers.
+	options, err := isolatedOptionsE(t, options)
+	if err != nil {
+		return "", err
+	}
+	if err := prepareOverrideFileE(t, options); err != nil {
+		return "", err
+	}
+
 	args := []string{"init", fmt.Sprintf("-upgrade=%t", options.Upgrade)}
 
 	// Append reconfigure option if specified
 	if options.Reconfigure {
 		args = append(args, "-reconfigure")
 	}
-	// Append combination of migrate-state and force-copy to suppress answer prompt
+	// Append combination of migrate-state and force-copy to suppress answer prompt. Binaries that predate
+	// -migrate-state offer to migrate the state by default, so -force-copy is enough for them.
 	if options.MigrateState {
-		args = append(args, "-migrate-state", "-force-copy")
+		if checkFeatureSupport(t, options, FeatureMigrateState) == nil {
+			args = append(args, "-migrate-state")
+		}
+		args = append(args, "-force-copy")
 	}
 	// Append no-color option if needed
 	if options.NoColor {
@@ -34,5 +49,18 @@ func InitE(t testing.TestingT, options *Options) (string, error) {
 
 	args = append(args, FormatTerraformBackendConfigAsArgs(options.BackendConfig)...)
 	args = append(args, FormatTerraformPluginDirAsArgs(options.PluginDir)...)
+
+	// Terraform does not support concurrent writes to the plugin cache, so only one init at a time may use the caches
+	// that terratest shares between tests.
+	if cacheDir := managedPluginCacheDir(options); cacheDir != "" && options.PluginDir == "" {
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			return "", err
+		}
+		unlock, err := lockPluginDirE(t, options, cacheDir)
+		if err != nil {
+			return "", err
+		}
+		defer unlock()
+	}
 	return RunTerraformCommandE(t, options, args...)
 }
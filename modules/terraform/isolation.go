@@ -0,0 +1,134 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// IsolatedBackendOverrideFileName is the name of the override file that IsolatedState writes to TerraformDir to
+// configure the local backend.
+const IsolatedBackendOverrideFileName = "terratest_isolated_backend_override.tf"
+
+// isolatedBackendOverride configures the local backend without a path, so that the file is the same for all tests
+// sharing a folder. The path of the state file of each test is passed to init with -backend-config instead, and is
+// stored in the data dir of the test.
+const isolatedBackendOverride = `# This file was generated by terratest to isolate the state of tests running in parallel. It is removed when the
+# tests finish.
+terraform {
+  backend "local" {}
+}
+`
+
+var unsafePathCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// cleanupT is implemented by testing.T and testing.B, which IsolatedState needs to clean up after the test.
+type cleanupT interface {
+	Cleanup(func())
+}
+
+// isolatedStateKey identifies the isolated state of a test in a folder.
+type isolatedStateKey struct {
+	testName     string
+	terraformDir string
+}
+
+// isolatedStateDirs are the folders holding the data dir and state file of each test using IsolatedState, so that all
+// the commands of a test use the same ones, even if they run with copies of the options.
+var isolatedStateDirs = struct {
+	sync.Mutex
+	dirs map[isolatedStateKey]string
+}{dirs: map[isolatedStateKey]string{}}
+
+// isolatedOptionsE returns the given options if IsolatedState is not enabled, or a copy of them that points terraform
+// at the isolated data dir and state of the test otherwise, setting them up the first time. The given options are
+// left as is, so that they can be reused. If TF_DATA_DIR is already set in EnvVars by the caller, the data dir is used
+// as is.
+func isolatedOptionsE(t testing.TestingT, options *Options) (*Options, error) {
+	if !options.IsolatedState {
+		return options, nil
+	}
+	if _, hasDataDir := options.EnvVars["TF_DATA_DIR"]; hasDataDir {
+		return options, nil
+	}
+	if options.TerraformBinary == "terragrunt" {
+		return nil, IsolatedStateNotSupported("terragrunt manages the data dir and backend of each module itself")
+	}
+	if options.BackendOverride != nil {
+		return nil, IsolatedStateNotSupported("BackendOverride is set, and both override the backend")
+	}
+	if len(options.BackendConfig) > 0 {
+		return nil, IsolatedStateNotSupported("BackendConfig is set, but the isolated state uses the local backend")
+	}
+
+	testDir, err := isolatedStateDirE(t, options)
+	if err != nil {
+		return nil, err
+	}
+
+	isolatedOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+	isolatedOptions.EnvVars["TF_DATA_DIR"] = filepath.Join(testDir, "data")
+	if pluginCacheDir(options) == "" {
+		if err := os.MkdirAll(DefaultPluginCacheDir, 0755); err != nil {
+			return nil, err
+		}
+		addManagedPluginCache(DefaultPluginCacheDir)
+		isolatedOptions.EnvVars["TF_PLUGIN_CACHE_DIR"] = DefaultPluginCacheDir
+	}
+	isolatedOptions.BackendConfig["path"] = filepath.Join(testDir, "terraform.tfstate")
+	return isolatedOptions, nil
+}
+
+// isolatedStateDirE returns the folder holding the data dir and state file of the test in TerraformDir, and creates
+// it along with the backend override file if this is the first command of the test in that folder. Everything is
+// removed when the test finishes.
+func isolatedStateDirE(t testing.TestingT, options *Options) (string, error) {
+	terraformDir, err := filepath.Abs(options.TerraformDir)
+	if err != nil {
+		return "", err
+	}
+	key := isolatedStateKey{testName: t.Name(), terraformDir: terraformDir}
+
+	isolatedStateDirs.Lock()
+	defer isolatedStateDirs.Unlock()
+
+	if testDir, hasDir := isolatedStateDirs.dirs[key]; hasDir {
+		return testDir, nil
+	}
+
+	cleanupTest, supportsCleanup := t.(cleanupT)
+	if !supportsCleanup {
+		return "", IsolatedStateNotSupported("the test does not support Cleanup (use a *testing.T)")
+	}
+
+	testDir, err := ioutil.TempDir("", "terratest-"+unsafePathCharsRegexp.ReplaceAllString(t.Name(), "-")+"-")
+	if err != nil {
+		return "", err
+	}
+	cleanupTest.Cleanup(func() {
+		isolatedStateDirs.Lock()
+		delete(isolatedStateDirs.dirs, key)
+		isolatedStateDirs.Unlock()
+		os.RemoveAll(testDir)
+	})
+
+	// The override file is shared by all the tests that use the folder, and removed when the last of them finishes.
+	overridePath := filepath.Join(terraformDir, IsolatedBackendOverrideFileName)
+	if err := acquireGeneratedFileE(overridePath, isolatedBackendOverride, 0644); err != nil {
+		return "", err
+	}
+	cleanupTest.Cleanup(func() {
+		releaseGeneratedFile(overridePath)
+	})
+
+	isolatedStateDirs.dirs[key] = testDir
+	options.Logger.Logf(t, "Isolating the data dir and state of %s in %s", t.Name(), testDir)
+	return testDir, nil
+}
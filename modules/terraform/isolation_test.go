@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeEnvBinary writes a script that prints the isolation related environment variables it runs with, its
+// arguments and whether the backend override file exists in its working directory.
+func writeFakeEnvBinary(t *testing.T) string {
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "TF_DATA_DIR=$TF_DATA_DIR"
+echo "TF_PLUGIN_CACHE_DIR=$TF_PLUGIN_CACHE_DIR"
+echo "ARGS=$*"
+if [ -f %q ]; then echo "OVERRIDE=true"; else echo "OVERRIDE=false"; fi
+`, IsolatedBackendOverrideFileName)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath
+}
+
+// isolatedStateDirOf returns the folder holding the isolated data dir and state of the given test in the given folder.
+func isolatedStateDirOf(t *testing.T, terraformDir string) string {
+	isolatedStateDirs.Lock()
+	defer isolatedStateDirs.Unlock()
+	return isolatedStateDirs.dirs[isolatedStateKey{testName: t.Name(), terraformDir: terraformDir}]
+}
+
+func TestIsolatedStateSetsUpDataDirAndBackendOverride(t *testing.T) {
+	t.Parallel()
+
+	binary := writeFakeEnvBinary(t)
+	terraformDir := t.TempDir()
+	overridePath := filepath.Join(terraformDir, IsolatedBackendOverrideFileName)
+
+	dataDirs := make([]string, 2)
+	t.Run("group", func(t *testing.T) {
+		for i := range dataDirs {
+			i := i
+			t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+				t.Parallel()
+
+				options := &Options{
+					TerraformBinary: binary,
+					TerraformDir:    terraformDir,
+					IsolatedState:   true,
+					EnvVars:         map[string]string{"TF_PLUGIN_CACHE_DIR": "/tmp/cache"},
+				}
+				out := Init(t, options)
+
+				testDir := isolatedStateDirOf(t, terraformDir)
+				require.NotEmpty(t, testDir)
+				dataDirs[i] = filepath.Join(testDir, "data")
+				statePath := filepath.Join(testDir, "terraform.tfstate")
+
+				// The options of the test are left as is.
+				assert.Equal(t, map[string]string{"TF_PLUGIN_CACHE_DIR": "/tmp/cache"}, options.EnvVars)
+				assert.Empty(t, options.BackendConfig)
+
+				assert.Contains(t, out, "TF_DATA_DIR="+dataDirs[i])
+				assert.Contains(t, out, "TF_PLUGIN_CACHE_DIR=/tmp/cache")
+				assert.Contains(t, out, fmt.Sprintf("-backend-config=path=%s", statePath))
+				assert.Contains(t, out, "OVERRIDE=true")
+				assert.FileExists(t, overridePath)
+
+				// Later commands reuse the data dir set up by init.
+				out = RunTerraformCommand(t, options, "plan")
+				assert.Contains(t, out, "TF_DATA_DIR="+dataDirs[i])
+			})
+		}
+	})
+
+	assert.NotEqual(t, dataDirs[0], dataDirs[1])
+	assert.NoFileExists(t, overridePath)
+	assert.NoDirExists(t, filepath.Dir(dataDirs[0]))
+}
+
+func TestIsolatedStateUsesSharedPluginCache(t *testing.T) {
+	t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+
+	options := &Options{
+		TerraformBinary: writeFakeEnvBinary(t),
+		TerraformDir:    t.TempDir(),
+		IsolatedState:   true,
+	}
+	out := RunTerraformCommand(t, options, "plan")
+	assert.Contains(t, out, "TF_PLUGIN_CACHE_DIR="+DefaultPluginCacheDir)
+	assert.DirExists(t, DefaultPluginCacheDir)
+}
+
+func TestIsolatedStateNotSupportedWithTerragrunt(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformBinary: "terragrunt",
+		TerraformDir:    t.TempDir(),
+		IsolatedState:   true,
+	}
+	_, err := InitE(t, options)
+	assert.IsType(t, IsolatedStateNotSupported(""), err)
+}
+
+func TestIsolatedStateNotSupportedWithBackendConfig(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformBinary: writeFakeEnvBinary(t),
+		TerraformDir:    t.TempDir(),
+		IsolatedState:   true,
+		BackendConfig:   map[string]interface{}{"bucket": "my-state", "key": "test.tfstate"},
+	}
+	_, err := InitE(t, options)
+	assert.IsType(t, IsolatedStateNotSupported(""), err)
+	assert.NoFileExists(t, filepath.Join(options.TerraformDir, IsolatedBackendOverrideFileName))
+}
+
+func TestIsolatedStateInParallel(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-test", t.Name())
+	require.NoError(t, err)
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{
+				TerraformDir:  testFolder,
+				IsolatedState: true,
+				Vars:          map[string]interface{}{"name": name},
+			}
+			InitAndApply(t, options)
+			assert.Equal(t, fmt.Sprintf("Hello, %s!", name), Output(t, options, "greeting"))
+
+			statePath := filepath.Join(isolatedStateDirOf(t, testFolder), "terraform.tfstate")
+			state, err := ioutil.ReadFile(statePath)
+			require.NoError(t, err)
+			assert.Contains(t, string(state), name)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// The types of the events terraform emits when run with -json. See
+// https://www.terraform.io/internals/machine-readable-ui for the full list.
+const (
+	JsonEventVersion           = "version"
+	JsonEventLog               = "log"
+	JsonEventDiagnostic        = "diagnostic"
+	JsonEventPlannedChange     = "planned_change"
+	JsonEventResourceDrift     = "resource_drift"
+	JsonEventChangeSummary     = "change_summary"
+	JsonEventOutputs           = "outputs"
+	JsonEventApplyStart        = "apply_start"
+	JsonEventApplyProgress     = "apply_progress"
+	JsonEventApplyComplete     = "apply_complete"
+	JsonEventApplyErrored      = "apply_errored"
+	JsonEventRefreshStart      = "refresh_start"
+	JsonEventRefreshComplete   = "refresh_complete"
+	JsonEventProvisionStart    = "provision_start"
+	JsonEventProvisionComplete = "provision_complete"
+	JsonEventTestAbstract      = "test_abstract"
+	JsonEventTestFile          = "test_file"
+	JsonEventTestRun           = "test_run"
+	JsonEventTestSummary       = "test_summary"
+)
+
+// JsonEvent is a single event of the machine readable output terraform streams when run with -json. Which of the
+// optional fields are set depends on the Type of the event.
+type JsonEvent struct {
+	Level     string    `json:"@level"`
+	Message   string    `json:"@message"`
+	Module    string    `json:"@module"`
+	Timestamp time.Time `json:"@timestamp"`
+	Type      string    `json:"type"`
+
+	// Set for the apply_*, refresh_* and provision_* events.
+	Hook *JsonHook `json:"hook,omitempty"`
+
+	// Set for the planned_change and resource_drift events.
+	Change *JsonPlannedChange `json:"change,omitempty"`
+
+	// Set for the change_summary event.
+	Changes *JsonChangeSummary `json:"changes,omitempty"`
+
+	// Set for the diagnostic event.
+	Diagnostic *Diagnostic `json:"diagnostic,omitempty"`
+
+	// Set for the outputs event.
+	Outputs map[string]JsonOutputValue `json:"outputs,omitempty"`
+
+	// Set for the events of terraform test. See RunTests.
+	TestFile     string              `json:"@testfile,omitempty"`
+	TestRun      string              `json:"@testrun,omitempty"`
+	TestAbstract map[string][]string `json:"test_abstract,omitempty"`
+	TestFileInfo *JsonTestFile       `json:"test_file,omitempty"`
+	TestRunInfo  *JsonTestRun        `json:"test_run,omitempty"`
+	TestSummary  *JsonTestSummary    `json:"test_summary,omitempty"`
+}
+
+// JsonResourceAddr identifies the resource an event refers to.
+type JsonResourceAddr struct {
+	Addr            string      `json:"addr"`
+	Module          string      `json:"module"`
+	Resource        string      `json:"resource"`
+	ImpliedProvider string      `json:"implied_provider"`
+	ResourceType    string      `json:"resource_type"`
+	ResourceName    string      `json:"resource_name"`
+	ResourceKey     interface{} `json:"resource_key"`
+}
+
+// JsonHook describes the progress of an operation on a single resource.
+type JsonHook struct {
+	Resource       JsonResourceAddr `json:"resource"`
+	Action         string           `json:"action"`
+	IdKey          string           `json:"id_key,omitempty"`
+	IdValue        string           `json:"id_value,omitempty"`
+	ElapsedSeconds float64          `json:"elapsed_seconds,omitempty"`
+}
+
+// JsonPlannedChange describes the change terraform plans to make to a single resource.
+type JsonPlannedChange struct {
+	Resource         JsonResourceAddr  `json:"resource"`
+	PreviousResource *JsonResourceAddr `json:"previous_resource,omitempty"`
+	Action           string            `json:"action"`
+	Reason           string            `json:"reason,omitempty"`
+}
+
+// JsonChangeSummary summarizes the changes of a plan, apply or destroy.
+type JsonChangeSummary struct {
+	Add       int    `json:"add"`
+	Change    int    `json:"change"`
+	Remove    int    `json:"remove"`
+	Operation string `json:"operation"`
+}
+
+// JsonOutputValue is a single output reported by the outputs event. Terraform omits the value of sensitive outputs.
+type JsonOutputValue struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type,omitempty"`
+	Value     interface{}     `json:"value,omitempty"`
+	Action    string          `json:"action,omitempty"`
+}
+
+// ResourceOperation is the outcome of applying (or destroying) a single resource.
+type ResourceOperation struct {
+	Address  string
+	Action   string
+	Duration time.Duration
+	IdKey    string
+	IdValue  string
+	Errored  bool
+
+	started time.Time
+}
+
+// JsonResult is the structured result of a terraform command run with -json.
+type JsonResult struct {
+	// All the events terraform emitted, in order.
+	Events []JsonEvent
+
+	// The changes terraform planned, keyed by resource address.
+	PlannedChanges map[string]*JsonPlannedChange
+
+	// The operations terraform applied, keyed by resource address.
+	Resources map[string]*ResourceOperation
+
+	// All the errors and warnings terraform reported, in order.
+	Diagnostics []Diagnostic
+
+	// The last change summary terraform reported, or nil if there was none.
+	ChangeSummary *JsonChangeSummary
+
+	// The outputs terraform reported after apply.
+	Outputs map[string]JsonOutputValue
+}
+
+// Errors returns the diagnostics with error severity.
+func (result *JsonResult) Errors() []Diagnostic {
+	var out []Diagnostic
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Severity == DiagnosticSeverityError {
+			out = append(out, diagnostic)
+		}
+	}
+	return out
+}
+
+// ResourceCount returns the number of resources added, changed and destroyed according to the change summary, or nil
+// if terraform did not report one.
+func (result *JsonResult) ResourceCount() *ResourceCount {
+	if result.ChangeSummary == nil {
+		return nil
+	}
+	return &ResourceCount{Add: result.ChangeSummary.Add, Change: result.ChangeSummary.Change, Destroy: result.ChangeSummary.Remove}
+}
+
+// ParseJsonOutput parses the output of a terraform command run with -json into a JsonResult. Lines that are not json
+// events (e.g., error messages terraform printed to stderr) are ignored.
+func ParseJsonOutput(output string) *JsonResult {
+	result := &JsonResult{
+		PlannedChanges: map[string]*JsonPlannedChange{},
+		Resources:      map[string]*ResourceOperation{},
+		Outputs:        map[string]JsonOutputValue{},
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if event, isEvent := parseJsonEvent(line); isEvent {
+			result.addEvent(event)
+		}
+	}
+	return result
+}
+
+func (result *JsonResult) addEvent(event JsonEvent) {
+	result.Events = append(result.Events, event)
+
+	switch {
+	case event.Type == JsonEventDiagnostic && event.Diagnostic != nil:
+		result.Diagnostics = append(result.Diagnostics, *event.Diagnostic)
+	case event.Type == JsonEventPlannedChange && event.Change != nil:
+		result.PlannedChanges[event.Change.Resource.Addr] = event.Change
+	case event.Type == JsonEventChangeSummary && event.Changes != nil:
+		result.ChangeSummary = event.Changes
+	case event.Type == JsonEventOutputs:
+		for name, output := range event.Outputs {
+			result.Outputs[name] = output
+		}
+	case event.Type == JsonEventApplyStart && event.Hook != nil:
+		result.Resources[event.Hook.Resource.Addr] = &ResourceOperation{
+			Address: event.Hook.Resource.Addr,
+			Action:  event.Hook.Action,
+			started: event.Timestamp,
+		}
+	case (event.Type == JsonEventApplyComplete || event.Type == JsonEventApplyErrored) && event.Hook != nil:
+		operation, hasStarted := result.Resources[event.Hook.Resource.Addr]
+		if !hasStarted {
+			operation = &ResourceOperation{Address: event.Hook.Resource.Addr, Action: event.Hook.Action}
+			result.Resources[operation.Address] = operation
+		}
+		operation.IdKey = event.Hook.IdKey
+		operation.IdValue = event.Hook.IdValue
+		operation.Errored = event.Type == JsonEventApplyErrored
+		// Terraform only reports the elapsed time in whole seconds, so prefer the timestamps when we have both.
+		if hasStarted && !operation.started.IsZero() && !event.Timestamp.IsZero() {
+			operation.Duration = event.Timestamp.Sub(operation.started)
+		} else {
+			operation.Duration = time.Duration(event.Hook.ElapsedSeconds * float64(time.Second))
+		}
+	}
+}
+
+// parseJsonEvent parses a single line of the output of a terraform command run with -json, returning false if the line
+// is not a json event.
+func parseJsonEvent(line string) (JsonEvent, bool) {
+	var event JsonEvent
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return event, false
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return event, false
+	}
+	return event, event.Type != "" && event.Level != ""
+}
+
+// jsonEventLogger is the logger used for terraform commands run with -json. It passes each json event to the callback
+// as soon as terraform emits it, and logs the human readable message of the event instead of the raw json. All other
+// lines are logged as is.
+type jsonEventLogger struct {
+	underlying *logger.Logger
+	callback   func(JsonEvent)
+}
+
+func (l jsonEventLogger) Logf(t testing.TestingT, format string, args ...interface{}) {
+	// The shell package logs each line of output with the "%s" format.
+	if format == "%s" && len(args) == 1 {
+		if line, isString := args[0].(string); isString {
+			if event, isEvent := parseJsonEvent(line); isEvent {
+				if l.callback != nil {
+					l.callback(event)
+				}
+				message := event.Message
+				if event.Diagnostic != nil && event.Diagnostic.Detail != "" {
+					message += "\n" + event.Diagnostic.Detail
+				}
+				l.underlying.Logf(t, "%s", message)
+				return
+			}
+		}
+	}
+	l.underlying.Logf(t, format, args...)
+}
+
+// ApplyWithJsonOutput runs terraform apply with -json and the given options and returns the parsed events. Note that
+// this method does NOT call destroy and assumes the caller is responsible for cleaning up any resources created by
+// running apply. This will fail the test if there is an error in the command.
+func ApplyWithJsonOutput(t testing.TestingT, options *Options) *JsonResult {
+	result, err := ApplyWithJsonOutputE(t, options)
+	require.NoError(t, err)
+	return result
+}
+
+// ApplyWithJsonOutputE runs terraform apply with -json and the given options and returns the parsed events. Note that
+// this method does NOT call destroy and assumes the caller is responsible for cleaning up any resources created by
+// running apply. If the command fails, the events terraform emitted (including the diagnostics describing the failure)
+// are returned along with the error.
+func ApplyWithJsonOutputE(t testing.TestingT, options *Options) (*JsonResult, error) {
+	return runWithJsonOutputE(t, options, ApplyE)
+}
+
+// PlanWithJsonOutput runs terraform plan with -json and the given options and returns the parsed events. This will
+// fail the test if there is an error in the command.
+func PlanWithJsonOutput(t testing.TestingT, options *Options) *JsonResult {
+	result, err := PlanWithJsonOutputE(t, options)
+	require.NoError(t, err)
+	return result
+}
+
+// PlanWithJsonOutputE runs terraform plan with -json and the given options and returns the parsed events. If the
+// command fails, the events terraform emitted are returned along with the error.
+func PlanWithJsonOutputE(t testing.TestingT, options *Options) (*JsonResult, error) {
+	return runWithJsonOutputE(t, options, PlanE)
+}
+
+// DestroyWithJsonOutput runs terraform destroy with -json and the given options and returns the parsed events. This
+// will fail the test if there is an error in the command.
+func DestroyWithJsonOutput(t testing.TestingT, options *Options) *JsonResult {
+	result, err := DestroyWithJsonOutputE(t, options)
+	require.NoError(t, err)
+	return result
+}
+
+// DestroyWithJsonOutputE runs terraform destroy with -json and the given options and returns the parsed events. If the
+// command fails, the events terraform emitted are returned along with the error.
+func DestroyWithJsonOutputE(t testing.TestingT, options *Options) (*JsonResult, error) {
+	return runWithJsonOutputE(t, options, DestroyE)
+}
+
+// runWithJsonOutputE runs the given command with JsonOutput enabled on a copy of the options and parses its output. This
+// returns an UnsupportedFeature error if the binary is too old to support -json.
+func runWithJsonOutputE(t testing.TestingT, options *Options, command func(testing.TestingT, *Options) (string, error)) (*JsonResult, error) {
+	if err := checkFeatureSupport(t, options, FeatureJsonOutput); err != nil {
+		return nil, err
+	}
+
+	jsonOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+	jsonOptions.JsonOutput = true
+
+	out, err := command(t, jsonOptions)
+	return ParseJsonOutput(out), err
+}
@@ -0,0 +1,164 @@
+package terraform
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const applyJsonOutput = `{"@level":"info","@message":"Terraform 1.3.7","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:00.000000Z","terraform":"1.3.7","type":"version","ui":"1.0"}
+{"@level":"info","@message":"null_resource.ok: Plan to create","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:01.000000Z","change":{"resource":{"addr":"null_resource.ok","module":"","resource":"null_resource.ok","implied_provider":"null","resource_type":"null_resource","resource_name":"ok","resource_key":null},"action":"create"},"type":"planned_change"}
+{"@level":"info","@message":"null_resource.fail: Plan to create","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:01.000000Z","change":{"resource":{"addr":"null_resource.fail","module":"","resource":"null_resource.fail","implied_provider":"null","resource_type":"null_resource","resource_name":"fail","resource_key":null},"action":"create"},"type":"planned_change"}
+{"@level":"info","@message":"Plan: 2 to add, 0 to change, 0 to destroy.","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:01.000000Z","changes":{"add":2,"change":0,"remove":0,"operation":"plan"},"type":"change_summary"}
+{"@level":"info","@message":"null_resource.ok: Creating...","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:02.000000Z","hook":{"resource":{"addr":"null_resource.ok","module":"","resource":"null_resource.ok","implied_provider":"null","resource_type":"null_resource","resource_name":"ok","resource_key":null},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"null_resource.fail: Creating...","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:02.000000Z","hook":{"resource":{"addr":"null_resource.fail","module":"","resource":"null_resource.fail","implied_provider":"null","resource_type":"null_resource","resource_name":"fail","resource_key":null},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"null_resource.ok: Creation complete after 1s [id=123]","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:03.500000Z","hook":{"resource":{"addr":"null_resource.ok","module":"","resource":"null_resource.ok","implied_provider":"null","resource_type":"null_resource","resource_name":"ok","resource_key":null},"action":"create","id_key":"id","id_value":"123","elapsed_seconds":1},"type":"apply_complete"}
+{"@level":"info","@message":"null_resource.fail: Creation errored after 2s","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:04.000000Z","hook":{"resource":{"addr":"null_resource.fail","module":"","resource":"null_resource.fail","implied_provider":"null","resource_type":"null_resource","resource_name":"fail","resource_key":null},"action":"create","elapsed_seconds":2},"type":"apply_errored"}
+{"@level":"error","@message":"Error: local-exec provisioner error","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:04.000000Z","diagnostic":{"severity":"error","summary":"local-exec provisioner error","detail":"Error running command 'exit 1': exit status 1.","address":"null_resource.fail","range":{"filename":"main.tf","start":{"line":8,"column":28,"byte":150},"end":{"line":8,"column":29,"byte":151}}},"type":"diagnostic"}
+{"@level":"info","@message":"Apply complete! Resources: 1 added, 0 changed, 0 destroyed.","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:04.000000Z","changes":{"add":1,"change":0,"remove":0,"operation":"apply"},"type":"change_summary"}
+{"@level":"info","@message":"Outputs: 1","@module":"terraform.ui","@timestamp":"2023-01-10T10:00:04.000000Z","outputs":{"id":{"sensitive":false,"type":"string","value":"123"},"secret":{"sensitive":true,"type":"string"}},"type":"outputs"}
+Some unstructured line terraform printed to stderr`
+
+func TestParseJsonOutput(t *testing.T) {
+	t.Parallel()
+
+	result := ParseJsonOutput(applyJsonOutput)
+
+	assert.Len(t, result.Events, 11)
+	assert.Equal(t, JsonEventVersion, result.Events[0].Type)
+
+	require.Contains(t, result.PlannedChanges, "null_resource.ok")
+	assert.Equal(t, "create", result.PlannedChanges["null_resource.ok"].Action)
+	assert.Equal(t, "null_resource", result.PlannedChanges["null_resource.ok"].Resource.ResourceType)
+
+	require.Len(t, result.Resources, 2)
+	ok := result.Resources["null_resource.ok"]
+	assert.Equal(t, "create", ok.Action)
+	assert.Equal(t, "123", ok.IdValue)
+	assert.False(t, ok.Errored)
+	assert.Equal(t, 1500*time.Millisecond, ok.Duration)
+	fail := result.Resources["null_resource.fail"]
+	assert.True(t, fail.Errored)
+	assert.Equal(t, 2*time.Second, fail.Duration)
+
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, result.Diagnostics, result.Errors())
+	diagnostic := result.Diagnostics[0]
+	assert.Equal(t, "local-exec provisioner error", diagnostic.Summary)
+	assert.Equal(t, "null_resource.fail", diagnostic.Address)
+	require.NotNil(t, diagnostic.Range)
+	assert.Equal(t, "main.tf", diagnostic.Range.Filename)
+	assert.Equal(t, 8, diagnostic.Range.Start.Line)
+
+	assert.Equal(t, &JsonChangeSummary{Add: 1, Operation: "apply"}, result.ChangeSummary)
+	assert.Equal(t, &ResourceCount{Add: 1}, result.ResourceCount())
+
+	assert.Equal(t, "123", result.Outputs["id"].Value)
+	assert.True(t, result.Outputs["secret"].Sensitive)
+	assert.Nil(t, result.Outputs["secret"].Value)
+}
+
+func TestParseJsonOutputWithoutEvents(t *testing.T) {
+	t.Parallel()
+
+	result := ParseJsonOutput("Error: Failed to load plugin schemas\n{\"format_version\":\"1.0\"}")
+	assert.Empty(t, result.Events)
+	assert.Nil(t, result.ResourceCount())
+}
+
+func TestJsonEventLogger(t *testing.T) {
+	t.Parallel()
+
+	logs := &capturingLogger{}
+	var events []JsonEvent
+	eventLogger := logger.New(jsonEventLogger{
+		underlying: logger.New(logs),
+		callback:   func(event JsonEvent) { events = append(events, event) },
+	})
+
+	for _, line := range strings.Split(applyJsonOutput, "\n") {
+		eventLogger.Logf(t, "%s", line)
+	}
+	eventLogger.Logf(t, "Running command %s with args %s", "terraform", []string{"apply"})
+
+	require.Len(t, events, 11)
+	assert.Equal(t, JsonEventApplyErrored, events[7].Type)
+	assert.Equal(t, "null_resource.fail", events[7].Hook.Resource.Addr)
+
+	assert.Contains(t, logs.lines, "null_resource.ok: Creation complete after 1s [id=123]")
+	assert.Contains(t, logs.lines, "Error: local-exec provisioner error\nError running command 'exit 1': exit status 1.")
+	assert.Contains(t, logs.lines, "Some unstructured line terraform printed to stderr")
+	assert.Contains(t, logs.lines, "Running command terraform with args [apply]")
+	for _, line := range logs.lines {
+		assert.False(t, strings.HasPrefix(line, "{"), "raw json event was logged: %s", line)
+	}
+}
+
+func TestFormatArgsWithJsonOutput(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{JsonOutput: true}
+	assert.Equal(t, []string{"apply", "-input=false", "-auto-approve", "-json", "-lock=false"}, FormatArgs(options, "apply", "-input=false", "-auto-approve"))
+	assert.Equal(t, []string{"init", "-lock=false"}, FormatArgs(options, "init"))
+}
+
+func TestApplyWithJsonOutput(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-output-sensitive", t.Name())
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var eventTypes []string
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars:         map[string]interface{}{"password": random.UniqueId()},
+		JsonEventCallback: func(event JsonEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			eventTypes = append(eventTypes, event.Type)
+		},
+	}
+	Init(t, options)
+
+	plan := PlanWithJsonOutput(t, options)
+	assert.Equal(t, "create", plan.PlannedChanges["null_resource.test"].Action)
+	assert.Equal(t, &ResourceCount{Add: 1}, plan.ResourceCount())
+
+	result := ApplyWithJsonOutput(t, options)
+	require.Contains(t, result.Resources, "null_resource.test")
+	assert.False(t, result.Resources["null_resource.test"].Errored)
+	assert.Equal(t, &ResourceCount{Add: 1}, result.ResourceCount())
+	assert.Equal(t, "admin", result.Outputs["username"].Value)
+	assert.Contains(t, eventTypes, JsonEventApplyStart)
+	assert.Contains(t, eventTypes, JsonEventApplyComplete)
+
+	destroy := DestroyWithJsonOutput(t, options)
+	assert.Equal(t, &ResourceCount{Destroy: 1}, destroy.ResourceCount())
+}
+
+func TestApplyWithJsonOutputReportsDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-with-error", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	Init(t, options)
+
+	result, err := ApplyWithJsonOutputE(t, options)
+	require.Error(t, err)
+	require.Contains(t, result.Resources, "null_resource.fail_on_first_run")
+	assert.True(t, result.Resources["null_resource.fail_on_first_run"].Errored)
+	require.NotEmpty(t, result.Errors())
+	assert.Equal(t, "null_resource.fail_on_first_run", result.Errors()[0].Address)
+}
@@ -0,0 +1,462 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// ModuleConfig is the static configuration of a terraform module, as declared in its .tf and .tf.json files. It is
+// read without running terraform, so expressions are not evaluated: values that are not literals (e.g., a default
+// that calls a function) are left empty.
+type ModuleConfig struct {
+	// The folder of the module.
+	Path string
+
+	// The required_version constraints of the terraform blocks of the module.
+	RequiredVersions []string
+
+	// The providers of the required_providers blocks of the module, keyed by their local name.
+	RequiredProviders map[string]*ProviderRequirement
+
+	Variables   map[string]*ModuleVariable
+	Outputs     map[string]*ModuleOutput
+	ModuleCalls map[string]*ModuleCall
+
+	// The resources and data sources of the module, keyed by their address (e.g., aws_instance.web or
+	// data.aws_ami.ubuntu).
+	Resources map[string]*ModuleResource
+}
+
+// ProviderRequirement is an entry of a required_providers block.
+type ProviderRequirement struct {
+	Name               string
+	Source             string
+	VersionConstraints []string
+	Pos                string
+}
+
+// ModuleVariable is a variable block.
+type ModuleVariable struct {
+	Name        string
+	Type        string // The type expression as written, e.g. list(string), or empty if not set
+	Description string
+	HasDefault  bool
+	Default     interface{} // The default value, if it is a literal
+	Sensitive   bool
+	Pos         string // The file and line the variable is declared at, e.g. variables.tf:12
+}
+
+// ModuleOutput is an output block.
+type ModuleOutput struct {
+	Name        string
+	Description string
+	Sensitive   bool
+	Pos         string
+}
+
+// ModuleCall is a module block.
+type ModuleCall struct {
+	Name    string
+	Source  string
+	Version string
+	Pos     string
+}
+
+// ModuleResource is a resource or data block.
+type ModuleResource struct {
+	Mode     string // managed for resource blocks, data for data blocks
+	Type     string
+	Name     string
+	Provider string // The provider configuration of the resource, e.g. aws or aws.west
+	Pos      string
+}
+
+// ProviderName returns the local name of the provider of the resource, which is set with the provider argument or
+// derived from the type of the resource (e.g., aws for aws_instance).
+func (resource *ModuleResource) ProviderName() string {
+	if resource.Provider != "" {
+		return strings.SplitN(resource.Provider, ".", 2)[0]
+	}
+	return strings.SplitN(resource.Type, "_", 2)[0]
+}
+
+var moduleConfigSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "terraform"},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+	},
+}
+
+var terraformBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "required_version"}},
+	Blocks:     []hcl.BlockHeaderSchema{{Type: "required_providers"}},
+}
+
+var variableBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "type"}, {Name: "description"}, {Name: "default"}, {Name: "sensitive"}},
+}
+
+var outputBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "description"}, {Name: "sensitive"}},
+}
+
+var moduleBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "source"}, {Name: "version"}},
+}
+
+var resourceBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "provider"}},
+}
+
+// InspectModule reads the configuration of the terraform module in the given folder without running terraform (so
+// without init or network access). This will fail the test if the configuration can't be parsed.
+func InspectModule(t testing.TestingT, moduleDir string) *ModuleConfig {
+	module, err := InspectModuleE(t, moduleDir)
+	require.NoError(t, err)
+	return module
+}
+
+// InspectModuleE reads the configuration of the terraform module in the given folder without running terraform (so
+// without init or network access). Override files (override.tf and *_override.tf) are skipped, as they are not part
+// of the interface of the module.
+func InspectModuleE(t testing.TestingT, moduleDir string) (module *ModuleConfig, err error) {
+	logger.Default.Logf(t, "Inspecting the configuration of the terraform module in %s", moduleDir)
+
+	// The HCL parser may panic on unexpected input, so convert those panics to errors, as parseAndDecodeVarFile does.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = PanicWhileInspectingModule{ModuleDir: moduleDir, RecoveredValue: recovered}
+		}
+	}()
+
+	paths, err := moduleConfigFiles(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	module = &ModuleConfig{
+		Path:              moduleDir,
+		RequiredProviders: map[string]*ProviderRequirement{},
+		Variables:         map[string]*ModuleVariable{},
+		Outputs:           map[string]*ModuleOutput{},
+		ModuleCalls:       map[string]*ModuleCall{},
+		Resources:         map[string]*ModuleResource{},
+	}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(path, ".json") {
+			file, diags = parser.ParseJSON(src, filepath.Base(path))
+		} else {
+			file, diags = parser.ParseHCL(src, filepath.Base(path))
+		}
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if err := module.addFile(file, src); err != nil {
+			return nil, err
+		}
+	}
+	return module, nil
+}
+
+// moduleConfigFiles returns the paths of the configuration files of the module in the given folder, without the
+// override files.
+func moduleConfigFiles(moduleDir string) ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{"*.tf", "*.tf.json"} {
+		matches, err := filepath.Glob(filepath.Join(moduleDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(match), ".json"), ".tf")
+			if name == "override" || strings.HasSuffix(name, "_override") {
+				continue
+			}
+			paths = append(paths, match)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// addFile adds the blocks of the given configuration file, whose source is src, to the module.
+func (module *ModuleConfig) addFile(file *hcl.File, src []byte) error {
+	content, _, diags := file.Body.PartialContent(moduleConfigSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, block := range content.Blocks {
+		pos := fmt.Sprintf("%s:%d", block.DefRange.Filename, block.DefRange.Start.Line)
+		switch block.Type {
+		case "terraform":
+			if err := module.addTerraformBlock(block, src); err != nil {
+				return err
+			}
+		case "variable":
+			attrs, err := blockAttributes(block, variableBlockSchema)
+			if err != nil {
+				return err
+			}
+			variable := &ModuleVariable{Name: block.Labels[0], Pos: pos}
+			variable.Type = exprText(attrs["type"], src)
+			variable.Description = exprText(attrs["description"], src)
+			variable.Sensitive = exprBool(attrs["sensitive"])
+			if attr, hasDefault := attrs["default"]; hasDefault {
+				variable.HasDefault = true
+				variable.Default = exprLiteral(attr)
+			}
+			module.Variables[variable.Name] = variable
+		case "output":
+			attrs, err := blockAttributes(block, outputBlockSchema)
+			if err != nil {
+				return err
+			}
+			module.Outputs[block.Labels[0]] = &ModuleOutput{
+				Name:        block.Labels[0],
+				Description: exprText(attrs["description"], src),
+				Sensitive:   exprBool(attrs["sensitive"]),
+				Pos:         pos,
+			}
+		case "module":
+			attrs, err := blockAttributes(block, moduleBlockSchema)
+			if err != nil {
+				return err
+			}
+			module.ModuleCalls[block.Labels[0]] = &ModuleCall{
+				Name:    block.Labels[0],
+				Source:  exprText(attrs["source"], src),
+				Version: exprText(attrs["version"], src),
+				Pos:     pos,
+			}
+		case "resource", "data":
+			attrs, err := blockAttributes(block, resourceBlockSchema)
+			if err != nil {
+				return err
+			}
+			resource := &ModuleResource{
+				Mode:     "managed",
+				Type:     block.Labels[0],
+				Name:     block.Labels[1],
+				Provider: exprText(attrs["provider"], src),
+				Pos:      pos,
+			}
+			address := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+			if block.Type == "data" {
+				resource.Mode = "data"
+				address = "data." + address
+			}
+			module.Resources[address] = resource
+		}
+	}
+	return nil
+}
+
+// addTerraformBlock adds the required_version and required_providers of the given terraform block to the module.
+func (module *ModuleConfig) addTerraformBlock(block *hcl.Block, src []byte) error {
+	content, _, diags := block.Body.PartialContent(terraformBlockSchema)
+	if diags.HasErrors() {
+		return diags
+	}
+	if requiredVersion := exprText(content.Attributes["required_version"], src); requiredVersion != "" {
+		module.RequiredVersions = append(module.RequiredVersions, requiredVersion)
+	}
+
+	for _, requiredProvidersBlock := range content.Blocks {
+		attrs, diags := requiredProvidersBlock.Body.JustAttributes()
+		if diags.HasErrors() {
+			return diags
+		}
+		for name, attr := range attrs {
+			requirement := &ProviderRequirement{
+				Name: name,
+				Pos:  fmt.Sprintf("%s:%d", attr.Range.Filename, attr.Range.Start.Line),
+			}
+			// Before terraform 0.13, the requirement was just a version constraint.
+			if value, diags := attr.Expr.Value(nil); !diags.HasErrors() && value.Type() == cty.String {
+				requirement.VersionConstraints = []string{value.AsString()}
+				module.RequiredProviders[name] = requirement
+				continue
+			}
+			// The requirement is an object, whose configuration_aliases can't be evaluated without a context, so each
+			// of its attributes is read separately.
+			pairs, _ := hcl.ExprMap(attr.Expr)
+			for _, pair := range pairs {
+				key := hcl.ExprAsKeyword(pair.Key)
+				if key == "" {
+					if value, diags := pair.Key.Value(nil); !diags.HasErrors() && value.Type() == cty.String {
+						key = value.AsString()
+					}
+				}
+				switch key {
+				case "source":
+					requirement.Source = exprString(pair.Value)
+				case "version":
+					if version := exprString(pair.Value); version != "" {
+						requirement.VersionConstraints = append(requirement.VersionConstraints, version)
+					}
+				}
+			}
+			module.RequiredProviders[name] = requirement
+		}
+	}
+	return nil
+}
+
+// blockAttributes returns the attributes of the given block that are in the given schema.
+func blockAttributes(block *hcl.Block, schema *hcl.BodySchema) (hcl.Attributes, error) {
+	content, _, diags := block.Body.PartialContent(schema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return content.Attributes, nil
+}
+
+// exprString returns the value of the given expression if it is a literal string, and an empty string otherwise.
+func exprString(expr hcl.Expression) string {
+	value, _ := exprStringLiteral(expr)
+	return value
+}
+
+// exprStringLiteral returns the value of the given expression and true if it is a literal string (which may be
+// empty), and false otherwise.
+func exprStringLiteral(expr hcl.Expression) (string, bool) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return "", false
+	}
+	return value.AsString(), true
+}
+
+// exprText returns the value of the given attribute if it is a literal string, and its source text otherwise (e.g.,
+// list(string) for a type, or aws.west for a provider). This returns an empty string if the attribute is nil.
+func exprText(attr *hcl.Attribute, src []byte) string {
+	if attr == nil {
+		return ""
+	}
+	if value, isLiteral := exprStringLiteral(attr.Expr); isLiteral {
+		return value
+	}
+	return strings.TrimSpace(string(attr.Expr.Range().SliceBytes(src)))
+}
+
+// exprBool returns the value of the given attribute if it is a literal bool, and false otherwise.
+func exprBool(attr *hcl.Attribute) bool {
+	if attr == nil {
+		return false
+	}
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.IsKnown() || value.Type() != cty.Bool {
+		return false
+	}
+	return value.True()
+}
+
+// exprLiteral returns the value of the given attribute as a Go value if it is a literal, and nil otherwise.
+func exprLiteral(attr *hcl.Attribute) interface{} {
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !value.IsWhollyKnown() {
+		return nil
+	}
+	jsonBytes, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	if err := json.Unmarshal(jsonBytes, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// UndocumentedVariables returns the names of the variables that don't have a description or a type, sorted.
+func (module *ModuleConfig) UndocumentedVariables() []string {
+	var names []string
+	for name, variable := range module.Variables {
+		if strings.TrimSpace(variable.Description) == "" || variable.Type == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UndocumentedOutputs returns the names of the outputs that don't have a description, sorted.
+func (module *ModuleConfig) UndocumentedOutputs() []string {
+	var names []string
+	for name, output := range module.Outputs {
+		if strings.TrimSpace(output.Description) == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnpinnedProviders returns the local names of the providers that don't have a version constraint in
+// required_providers, sorted. This includes the providers that the resources of the module use without declaring
+// them in required_providers. The built-in terraform provider is not versioned, and is never returned.
+func (module *ModuleConfig) UnpinnedProviders() []string {
+	unpinned := map[string]bool{}
+	for name, requirement := range module.RequiredProviders {
+		if len(requirement.VersionConstraints) == 0 {
+			unpinned[name] = true
+		}
+	}
+	for _, resource := range module.Resources {
+		name := resource.ProviderName()
+		if _, isRequired := module.RequiredProviders[name]; !isRequired && name != "terraform" {
+			unpinned[name] = true
+		}
+	}
+	return sortedKeys(unpinned)
+}
+
+// AssertModuleDocumented checks that every variable of the module has a description and a type, and that every
+// output has a description, failing the test if not.
+func AssertModuleDocumented(t testing.TestingT, module *ModuleConfig) {
+	assert.Empty(t, module.UndocumentedVariables(), "Variables of %s without a description or type", module.Path)
+	assert.Empty(t, module.UndocumentedOutputs(), "Outputs of %s without a description", module.Path)
+}
+
+// RequireModuleDocumented checks that every variable of the module has a description and a type, and that every
+// output has a description, failing and halting the test if not.
+func RequireModuleDocumented(t testing.TestingT, module *ModuleConfig) {
+	require.Empty(t, module.UndocumentedVariables(), "Variables of %s without a description or type", module.Path)
+	require.Empty(t, module.UndocumentedOutputs(), "Outputs of %s without a description", module.Path)
+}
+
+// AssertProvidersPinned checks that every provider the module uses has a version constraint, failing the test if not.
+func AssertProvidersPinned(t testing.TestingT, module *ModuleConfig) {
+	assert.Empty(t, module.UnpinnedProviders(), "Providers of %s without a version constraint", module.Path)
+}
+
+// RequireProvidersPinned checks that every provider the module uses has a version constraint, failing and halting the
+// test if not.
+func RequireProvidersPinned(t testing.TestingT, module *ModuleConfig) {
+	require.Empty(t, module.UnpinnedProviders(), "Providers of %s without a version constraint", module.Path)
+}
@@ -0,0 +1,207 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModuleFiles = map[string]string{
+	"versions.tf": `
+terraform {
+  required_version = ">= 1.0"
+
+  required_providers {
+    aws = {
+      source                = "hashicorp/aws"
+      version               = "~> 5.0"
+      configuration_aliases = [aws.west]
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+    null = "~> 3.0"
+  }
+}
+`,
+	"variables.tf": `
+variable "name" {
+  description = "The name of the bucket"
+  type        = string
+}
+
+variable "tags" {
+  description = "The tags of the bucket"
+  type        = map(string)
+  default     = { Team = "platform" }
+}
+
+variable "replicas" {
+  default = 2
+}
+
+variable "password" {
+  type      = string
+  sensitive = true
+}
+`,
+	"main.tf": `
+resource "aws_s3_bucket" "bucket" {
+  bucket = var.name
+  tags   = var.tags
+}
+
+resource "aws_s3_bucket" "replica" {
+  provider = aws.west
+  bucket   = "${var.name}-replica"
+}
+
+data "aws_caller_identity" "current" {}
+
+resource "random_id" "suffix" {
+  byte_length = 4
+}
+
+resource "tls_private_key" "key" {
+  algorithm = "RSA"
+}
+
+resource "terraform_data" "marker" {}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+}
+`,
+	"outputs.tf.json": `{
+  "output": {
+    "bucket_arn": {"description": "The ARN of the bucket", "value": "${aws_s3_bucket.bucket.arn}"},
+    "bucket_id": {"value": "${aws_s3_bucket.bucket.id}"}
+  }
+}`,
+	"main_override.tf": `
+variable "undocumented_override" {}
+`,
+}
+
+func writeTestModule(t *testing.T, moduleFiles map[string]string) string {
+	moduleDir := t.TempDir()
+	for name, content := range moduleFiles {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, name), []byte(content), 0644))
+	}
+	return moduleDir
+}
+
+func TestInspectModule(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := writeTestModule(t, testModuleFiles)
+	module := InspectModule(t, moduleDir)
+
+	assert.Equal(t, []string{">= 1.0"}, module.RequiredVersions)
+	assert.Equal(t, &ProviderRequirement{Name: "aws", Source: "hashicorp/aws", VersionConstraints: []string{"~> 5.0"}, Pos: "versions.tf:6"}, module.RequiredProviders["aws"])
+	assert.Equal(t, []string{"~> 3.0"}, module.RequiredProviders["null"].VersionConstraints)
+
+	assert.Len(t, module.Variables, 4)
+	assert.Equal(t, &ModuleVariable{
+		Name:        "tags",
+		Type:        "map(string)",
+		Description: "The tags of the bucket",
+		HasDefault:  true,
+		Default:     map[string]interface{}{"Team": "platform"},
+		Pos:         "variables.tf:7",
+	}, module.Variables["tags"])
+	assert.Equal(t, float64(2), module.Variables["replicas"].Default)
+	assert.False(t, module.Variables["name"].HasDefault)
+	assert.True(t, module.Variables["password"].Sensitive)
+
+	assert.Equal(t, "The ARN of the bucket", module.Outputs["bucket_arn"].Description)
+	assert.Equal(t, &ModuleCall{Name: "vpc", Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0", Pos: "main.tf:24"}, module.ModuleCalls["vpc"])
+
+	assert.Len(t, module.Resources, 6)
+	assert.Equal(t, "aws.west", module.Resources["aws_s3_bucket.replica"].Provider)
+	assert.Equal(t, "aws", module.Resources["aws_s3_bucket.replica"].ProviderName())
+	assert.Equal(t, "data", module.Resources["data.aws_caller_identity.current"].Mode)
+
+	assert.Equal(t, []string{"password", "replicas"}, module.UndocumentedVariables())
+	assert.Equal(t, []string{"bucket_id"}, module.UndocumentedOutputs())
+	assert.Equal(t, []string{"random", "tls"}, module.UnpinnedProviders())
+}
+
+func TestInspectModuleDocumented(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := writeTestModule(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    null = {
+      source  = "hashicorp/null"
+      version = "3.2.1"
+    }
+  }
+}
+
+variable "triggers" {
+  description = "The triggers of the resource"
+  type        = map(string)
+}
+
+resource "null_resource" "test" {
+  triggers = var.triggers
+}
+
+output "id" {
+  description = "The ID of the resource"
+  value       = null_resource.test.id
+}
+`,
+	})
+	module := InspectModule(t, moduleDir)
+	RequireModuleDocumented(t, module)
+	RequireProvidersPinned(t, module)
+}
+
+func TestInspectModuleWithEmptyDescriptions(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := writeTestModule(t, map[string]string{
+		"main.tf": `
+variable "name" {
+  description = ""
+  type        = string
+}
+
+output "name" {
+  description = ""
+  value       = var.name
+}
+`,
+	})
+	module := InspectModule(t, moduleDir)
+
+	assert.Equal(t, "", module.Variables["name"].Description)
+	assert.Equal(t, "", module.Outputs["name"].Description)
+	assert.Equal(t, []string{"name"}, module.UndocumentedVariables())
+	assert.Equal(t, []string{"name"}, module.UndocumentedOutputs())
+}
+
+func TestInspectModuleWithInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := writeTestModule(t, map[string]string{"main.tf": `variable "name" {`})
+	_, err := InspectModuleE(t, moduleDir)
+	require.Error(t, err)
+}
+
+func TestInspectModuleFixture(t *testing.T) {
+	t.Parallel()
+
+	module := InspectModule(t, "../../test/fixtures/terraform-graph")
+	assert.Contains(t, module.ModuleCalls, "storage")
+	assert.Contains(t, module.Resources, "terraform_data.kms_key")
+	assert.Empty(t, module.UnpinnedProviders())
+}
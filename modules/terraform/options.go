@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/retry"
 	"github.com/gruntwork-io/terratest/modules/ssh"
 	"github.com/gruntwork-io/terratest/modules/testing"
 	"github.com/jinzhu/copier"
@@ -56,12 +57,13 @@ type Options struct {
 	LockTimeout              string                 // The lock timeout option to pass to the terraform command with -lock-timeout
 	EnvVars                  map[string]string      // Environment variables to set when running Terraform
 	BackendConfig            map[string]interface{} // The vars to pass to the terraform init command for extra configuration for the backend
-	RetryableTerraformErrors map[string]string      // If Terraform apply fails with one of these (transient) errors, retry. The keys are a regexp to match against the error and the message is what to display to a user if that error is matched.
+	RetryableTerraformErrors map[string]string      // If Terraform apply fails with one of these (transient) errors, retry. The keys are a regexp to match against the error (which includes the summary and detail of each error diagnostic, see TerraformError) and the message is what to display to a user if that error is matched.
 	MaxRetries               int                    // Maximum number of times to retry errors matching RetryableTerraformErrors
 	TimeBetweenRetries       time.Duration          // The amount of time to wait between retries
+	RetryPolicy              *retry.Policy          // How to retry errors matching RetryableTerraformErrors (e.g., with exponential backoff). If set, MaxRetries and TimeBetweenRetries are ignored. See retry.Policy.
 	Upgrade                  bool                   // Whether the -upgrade flag of the terraform init command should be set to true or not
 	Reconfigure              bool                   // Set the -reconfigure flag to the terraform init command
-	MigrateState             bool                   // Set the -migrate-state and -force-copy (suppress 'yes' answer prompt) flag to the terraform init command
+	MigrateState             bool                   // Set the -migrate-state and -force-copy (suppress 'yes' answer prompt) flag to the terraform init command. Only -force-copy is set for binaries that predate -migrate-state.
 	NoColor                  bool                   // Whether the -no-color flag will be set for any Terraform command or not
 	SshAgent                 *ssh.SshAgent          // Overrides local SSH agent with the given in-process agent
 	NoStderr                 bool                   // Disable stderr redirection
@@ -71,6 +73,16 @@ type Options struct {
 	PlanFilePath             string                 // The path to output a plan file to (for the plan command) or read one from (for the apply command)
 	PluginDir                string                 // The path of downloaded plugins to pass to the terraform init command (-plugin-dir)
 	SetVarsAfterVarFiles     bool                   // Pass -var options after -var-file options to Terraform commands
+	VarsAsFile               bool                   // Write Vars to a generated .auto.tfvars.json file passed with -var-file instead of passing each var with -var. This preserves the type of each var (including null), and keeps the values out of the command line. The file is removed once the command finishes.
+	JsonOutput               bool                   // Set the -json flag on the plan, apply, destroy and refresh commands to stream machine readable events. Requires Terraform 0.15.3 or newer.
+	JsonEventCallback        func(JsonEvent)        `json:"-"` // If JsonOutput is set, called with each event terraform emits, as it is emitted. See JsonEvent. This is not serialized (e.g., by test_structure.SaveTerraformOptions).
+	TestFilters              []string               // The test files to run with the terraform test command (-filter). All the test files are run if empty.
+	TestDirectory            string                 // The directory the terraform test command looks for test files in (-test-directory). Defaults to tests.
+	IsolatedState            bool                   // Give the test its own data dir (TF_DATA_DIR) and local state file, so that tests can run in parallel against the same folder. This writes a local backend override file to TerraformDir (so BackendConfig must be empty), and shares a plugin cache between tests unless TF_PLUGIN_CACHE_DIR is set. Everything is cleaned up when the test finishes, which requires a *testing.T. See IsolatedBackendOverrideFileName.
+	BackendOverride          *BackendOverride       // Replace the backend of the configuration with this one, using an override file written to TerraformDir before init and removed when the test finishes. See OverrideFileName.
+	ProviderOverrides        []ProviderOverride     // Override the configuration or version of providers, using the same override file as BackendOverride.
+	PlanGuard                *PlanGuard             // A policy the plan must satisfy before Apply (and the functions that call it, such as InitAndApply) applies it (e.g., a maximum number of resources created). If set, Apply saves the plan to PlanFilePath (or a temporary file), checks it, and only applies it if there are no violations. See PlanGuard.
+	IdempotencyExceptions    []IdempotencyException // Changes that ApplyAndIdempotent allows in the plan after apply, for resources or attributes known to change on every plan. See IdempotencyException.
 }
 
 // Clone makes a deep copy of most fields on the Options object and returns it.
@@ -99,10 +111,24 @@ func (options *Options) Clone() (*Options, error) {
 	for key, val := range options.RetryableTerraformErrors {
 		newOptions.RetryableTerraformErrors[key] = val
 	}
+	// Nor does it copy what pointers point to, so that changing the policy of the copy would change the original.
+	if options.RetryPolicy != nil {
+		retryPolicy := *options.RetryPolicy
+		newOptions.RetryPolicy = &retryPolicy
+	}
 
 	return newOptions, nil
 }
 
+// doWithRetryableErrorsE runs the given action, retrying the errors matching RetryableTerraformErrors with RetryPolicy
+// if set, or with MaxRetries and TimeBetweenRetries, as retry.DoWithRetryableErrorsE does, otherwise.
+func (options *Options) doWithRetryableErrorsE(t testing.TestingT, description string, action func() (string, error)) (string, error) {
+	if options.RetryPolicy != nil {
+		return retry.DoWithRetryableErrorsPolicyE(t, description, options.RetryableTerraformErrors, *options.RetryPolicy, action)
+	}
+	return retry.DoWithRetryableErrorsE(t, description, options.RetryableTerraformErrors, options.MaxRetries, options.TimeBetweenRetries, action)
+}
+
 // WithDefaultRetryableErrors makes a copy of the Options object and returns an updated object with sensible defaults
 // for retryable errors. The included retryable errors are typical errors that most terraform modules encounter during
 // testing, and are known to self resolve upon retrying.
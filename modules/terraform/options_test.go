@@ -1,9 +1,12 @@
 package terraform
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,3 +44,36 @@ func TestOptionsCloneDeepClonesVars(t *testing.T) {
 	assert.Equal(t, unique, original.Vars["unique"])
 	assert.Equal(t, unique, copied.Vars["original"])
 }
+
+func TestOptionsRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Without a RetryPolicy, the legacy retries ignore the test deadline.
+	attempts := 0
+	options := &Options{MaxRetries: 2, RetryableTerraformErrors: map[string]string{"flaky": "flaky"}}
+	_, err := options.doWithRetryableErrorsE(deadlineT{T: t, deadline: time.Now().Add(-time.Second)}, "legacy", func() (string, error) {
+		attempts++
+		return "", fmt.Errorf("flaky")
+	})
+	assert.Equal(t, retry.MaxRetriesExceeded{Description: "legacy", MaxRetries: 2}, err)
+	assert.Equal(t, 3, attempts)
+
+	policy := retry.ExponentialBackoff(5, time.Second, time.Minute)
+	options.RetryPolicy = &policy
+	copied, err := options.Clone()
+	require.NoError(t, err)
+	assert.Equal(t, policy, *copied.RetryPolicy)
+
+	copied.RetryPolicy.MaxRetries = 1
+	assert.Equal(t, 5, options.RetryPolicy.MaxRetries)
+}
+
+// deadlineT is a testing.T with the given deadline.
+type deadlineT struct {
+	*testing.T
+	deadline time.Time
+}
+
+func (t deadlineT) Deadline() (time.Time, bool) {
+	return t.deadline, true
+}
@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -264,6 +265,7 @@ func OutputForKeys(t testing.TestingT, options *Options, keys []string) map[stri
 // OutputJson calls terraform output for the given variable and returns the
 // result as the json string.
 // If key is an empty string, it will return all the output variables.
+// The values of sensitive outputs are redacted in the logs, but not in the returned json.
 func OutputJson(t testing.TestingT, options *Options, key string) string {
 	str, err := OutputJsonE(t, options, key)
 	require.NoError(t, err)
@@ -273,13 +275,34 @@ func OutputJson(t testing.TestingT, options *Options, key string) string {
 // OutputJsonE calls terraform output for the given variable and returns the
 // result as the json string.
 // If key is an empty string, it will return all the output variables.
+// The values of sensitive outputs are redacted in the logs, but not in the returned json.
 func OutputJsonE(t testing.TestingT, options *Options, key string) (string, error) {
-	args := []string{"output", "-no-color", "-json"}
-	if key != "" {
-		args = append(args, key)
+	// We always fetch all the outputs, as that is the only format in which terraform tells us which outputs are
+	// sensitive, and we need that information to redact them before logging.
+	out, err := runTerraformCommandAndGetRedactedStdoutE(t, options, redactOutputsJson, "output", "-no-color", "-json")
+	if err != nil || key == "" {
+		return out, err
 	}
 
-	return RunTerraformCommandAndGetStdoutE(t, options, args...)
+	outputs := map[string]struct {
+		Value json.RawMessage `json:"value"`
+	}{}
+	if err := json.Unmarshal([]byte(out), &outputs); err != nil {
+		return "", err
+	}
+	output, hasKey := outputs[key]
+	if !hasKey {
+		// Let terraform report the missing output, as it did before we fetched all the outputs.
+		return RunTerraformCommandAndGetStdoutE(t, options, "output", "-no-color", "-json", key)
+	}
+
+	// Terraform prints the value of a single output as compact json, whereas the value is indented in the document
+	// of all the outputs.
+	var value bytes.Buffer
+	if err := json.Compact(&value, output.Value); err != nil {
+		return "", err
+	}
+	return value.String(), nil
 }
 
 // OutputStruct calls terraform output for the given variable and stores the
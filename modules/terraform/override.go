@@ -0,0 +1,219 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// OverrideFileName is the name of the override file that BackendOverride and ProviderOverrides are rendered to in
+// TerraformDir.
+const OverrideFileName = "terratest_override.tf"
+
+// BackendOverride replaces the backend of the terraform configuration, e.g. to swap in a local backend:
+//
+//	BackendOverride: &terraform.BackendOverride{Type: "local", Config: map[string]interface{}{"path": statePath}}
+type BackendOverride struct {
+	Type   string                 // The type of the backend, e.g. local or s3
+	Config map[string]interface{} // The arguments of the backend block
+}
+
+// ProviderOverride overrides the arguments of a provider configuration, and optionally pins the version of the
+// provider. E.g., to point the aws provider at localstack:
+//
+//	ProviderOverrides: []terraform.ProviderOverride{{
+//		Name:       "aws",
+//		Attributes: map[string]interface{}{"skip_credentials_validation": true},
+//		Blocks: []terraform.OverrideBlock{{
+//			Type:       "endpoints",
+//			Attributes: map[string]interface{}{"s3": "http://localhost:4566"},
+//		}},
+//	}}
+//
+// As with any override file, the arguments are merged into the provider block with the same name and alias, which
+// must exist in the configuration, and each nested block replaces the nested blocks of the same type.
+type ProviderOverride struct {
+	Name       string                 // The local name of the provider, e.g. aws
+	Alias      string                 // The alias of the provider configuration to override, if any
+	Attributes map[string]interface{} // The arguments to set in the provider block
+	Blocks     []OverrideBlock        // The nested blocks to set in the provider block, e.g. endpoints
+
+	// If set, the version constraint to pin the provider to in required_providers. As override files replace the
+	// whole required_providers entry of the provider, Source should be set too unless the provider is a hashicorp one.
+	Version string
+	Source  string // The source of the provider in required_providers, e.g. hashicorp/aws
+}
+
+// OverrideBlock is a nested block of an override, such as the endpoints block of the aws provider.
+type OverrideBlock struct {
+	Type       string
+	Labels     []string
+	Attributes map[string]interface{}
+	Blocks     []OverrideBlock
+
+	rawLines []string // Lines that are written as is after the attributes
+}
+
+// generatedFiles counts the tests that use each file generated by terratest (e.g., override files), so that parallel
+// tests sharing a folder can use the same file, which is only removed when the last of them finishes.
+var generatedFiles = struct {
+	sync.Mutex
+	users map[string]int
+}{users: map[string]int{}}
+
+// prepareOverrideFileE renders BackendOverride and ProviderOverrides to an override file in TerraformDir, which is
+// removed when the test finishes. This requires a test that supports Cleanup.
+func prepareOverrideFileE(t testing.TestingT, options *Options) error {
+	if options.BackendOverride == nil && len(options.ProviderOverrides) == 0 {
+		return nil
+	}
+	cleanupTest, supportsCleanup := t.(cleanupT)
+	if !supportsCleanup {
+		return OverrideNotSupported("the test does not support Cleanup (use a *testing.T)")
+	}
+
+	content, err := renderOverrides(options.BackendOverride, options.ProviderOverrides)
+	if err != nil {
+		return err
+	}
+	overridePath := filepath.Join(options.TerraformDir, OverrideFileName)
+	if err := acquireGeneratedFileE(overridePath, content, 0644); err != nil {
+		return err
+	}
+	cleanupTest.Cleanup(func() {
+		releaseGeneratedFile(overridePath)
+	})
+	return nil
+}
+
+// renderOverrides renders the given backend and provider overrides to the content of an override file. Values are
+// formatted the same way as the -var options of terraform commands.
+func renderOverrides(backend *BackendOverride, providers []ProviderOverride) (string, error) {
+	var out strings.Builder
+	out.WriteString("# This file was generated by terratest from the BackendOverride and ProviderOverrides options. It is\n")
+	out.WriteString("# removed when the tests finish.\n")
+
+	// The entries of required_providers are objects whose keys must be keywords, which toHclString does not render,
+	// so they are rendered as raw lines.
+	var requiredProviders []string
+	for _, provider := range providers {
+		if provider.Name == "" {
+			return "", OverrideNotSupported("the Name of a ProviderOverride is not set")
+		}
+		if provider.Version == "" {
+			continue
+		}
+		requirement := fmt.Sprintf("%s = { version = %q", provider.Name, provider.Version)
+		if provider.Source != "" {
+			requirement += fmt.Sprintf(", source = %q", provider.Source)
+		}
+		requiredProviders = append(requiredProviders, requirement+" }")
+	}
+
+	if backend != nil || len(requiredProviders) > 0 {
+		terraformBlock := OverrideBlock{Type: "terraform"}
+		if backend != nil {
+			if backend.Type == "" {
+				return "", OverrideNotSupported("the Type of BackendOverride is not set")
+			}
+			terraformBlock.Blocks = append(terraformBlock.Blocks, OverrideBlock{Type: "backend", Labels: []string{backend.Type}, Attributes: backend.Config})
+		}
+		if len(requiredProviders) > 0 {
+			terraformBlock.Blocks = append(terraformBlock.Blocks, OverrideBlock{Type: "required_providers", rawLines: requiredProviders})
+		}
+		out.WriteString("\n")
+		writeOverrideBlock(&out, terraformBlock, "")
+	}
+
+	for _, provider := range providers {
+		if provider.Alias == "" && len(provider.Attributes) == 0 && len(provider.Blocks) == 0 {
+			continue
+		}
+		providerBlock := OverrideBlock{Type: "provider", Labels: []string{provider.Name}, Attributes: map[string]interface{}{}, Blocks: provider.Blocks}
+		for key, value := range provider.Attributes {
+			providerBlock.Attributes[key] = value
+		}
+		if provider.Alias != "" {
+			providerBlock.Attributes["alias"] = provider.Alias
+		}
+		out.WriteString("\n")
+		writeOverrideBlock(&out, providerBlock, "")
+	}
+	return out.String(), nil
+}
+
+// writeOverrideBlock writes the given block with the given indentation. Attributes are sorted by name, so that the
+// same overrides always render to the same file.
+func writeOverrideBlock(out *strings.Builder, block OverrideBlock, indent string) {
+	out.WriteString(indent + block.Type)
+	for _, label := range block.Labels {
+		out.WriteString(fmt.Sprintf(" %q", label))
+	}
+	out.WriteString(" {\n")
+
+	keys := make([]string, 0, len(block.Attributes))
+	for key := range block.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, key, toHclString(block.Attributes[key], true)))
+	}
+	for _, line := range block.rawLines {
+		out.WriteString(fmt.Sprintf("%s  %s\n", indent, line))
+	}
+	for _, nested := range block.Blocks {
+		writeOverrideBlock(out, nested, indent+"  ")
+	}
+	out.WriteString(indent + "}\n")
+}
+
+// acquireGeneratedFileE writes the given content to the generated file at the given path with the given permissions,
+// unless another test already did. This returns an error if another test uses the file with a different content, as
+// the tests would then override each other.
+func acquireGeneratedFileE(path string, content string, perm os.FileMode) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	generatedFiles.Lock()
+	defer generatedFiles.Unlock()
+
+	if generatedFiles.users[absPath] > 0 {
+		existing, err := ioutil.ReadFile(absPath)
+		if err != nil {
+			return err
+		}
+		if string(existing) != content {
+			return OverrideFileConflict(absPath)
+		}
+	} else if err := ioutil.WriteFile(absPath, []byte(content), perm); err != nil {
+		return err
+	}
+	generatedFiles.users[absPath]++
+	return nil
+}
+
+// releaseGeneratedFile removes the generated file at the given path if no other test uses it.
+func releaseGeneratedFile(path string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	generatedFiles.Lock()
+	defer generatedFiles.Unlock()
+
+	generatedFiles.users[absPath]--
+	if generatedFiles.users[absPath] <= 0 {
+		delete(generatedFiles.users, absPath)
+		os.Remove(absPath)
+	}
+}
@@ -0,0 +1,135 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const expectedOverrideFile = `# This file was generated by terratest from the BackendOverride and ProviderOverrides options. It is
+# removed when the tests finish.
+
+terraform {
+  backend "s3" {
+    bucket = "terratest"
+    force_path_style = true
+  }
+  required_providers {
+    aws = { version = "~> 5.0", source = "hashicorp/aws" }
+  }
+}
+
+provider "aws" {
+  default_tags = {"Owner" = "terratest", "Test" = "true"}
+  region = "us-east-1"
+  skip_credentials_validation = true
+  endpoints {
+    s3 = "http://localhost:4566"
+    sts = "http://localhost:4566"
+  }
+}
+
+provider "aws" {
+  alias = "replica"
+  region = "us-west-2"
+}
+`
+
+func TestRenderOverrides(t *testing.T) {
+	t.Parallel()
+
+	content, err := renderOverrides(
+		&BackendOverride{Type: "s3", Config: map[string]interface{}{"bucket": "terratest", "force_path_style": true}},
+		[]ProviderOverride{
+			{
+				Name:    "aws",
+				Version: "~> 5.0",
+				Source:  "hashicorp/aws",
+				Attributes: map[string]interface{}{
+					"region":                      "us-east-1",
+					"skip_credentials_validation": true,
+					"default_tags":                map[string]string{"Test": "true", "Owner": "terratest"},
+				},
+				Blocks: []OverrideBlock{{
+					Type:       "endpoints",
+					Attributes: map[string]interface{}{"s3": "http://localhost:4566", "sts": "http://localhost:4566"},
+				}},
+			},
+			{
+				Name:       "aws",
+				Alias:      "replica",
+				Attributes: map[string]interface{}{"region": "us-west-2"},
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, expectedOverrideFile, content)
+}
+
+func TestRenderOverridesWithoutName(t *testing.T) {
+	t.Parallel()
+
+	_, err := renderOverrides(nil, []ProviderOverride{{Version: "1.0.0"}})
+	assert.IsType(t, OverrideNotSupported(""), err)
+}
+
+func TestInitWritesAndRemovesOverrideFile(t *testing.T) {
+	t.Parallel()
+
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	script := fmt.Sprintf("#!/bin/sh\ncat %q\n", OverrideFileName)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	terraformDir := t.TempDir()
+	overridePath := filepath.Join(terraformDir, OverrideFileName)
+
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"first", "second"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				options := &Options{
+					TerraformBinary:   binaryPath,
+					TerraformDir:      terraformDir,
+					ProviderOverrides: []ProviderOverride{{Name: "null", Version: "3.2.1"}},
+				}
+				out := Init(t, options)
+				assert.Contains(t, out, `null = { version = "3.2.1" }`)
+			})
+		}
+	})
+	assert.NoFileExists(t, overridePath)
+}
+
+func TestOverrideFileConflict(t *testing.T) {
+	t.Parallel()
+
+	overridePath := filepath.Join(t.TempDir(), OverrideFileName)
+	require.NoError(t, acquireGeneratedFileE(overridePath, "# first", 0644))
+	defer releaseGeneratedFile(overridePath)
+
+	assert.Equal(t, OverrideFileConflict(overridePath), acquireGeneratedFileE(overridePath, "# second", 0644))
+	require.NoError(t, acquireGeneratedFileE(overridePath, "# first", 0644))
+	releaseGeneratedFile(overridePath)
+	assert.FileExists(t, overridePath)
+}
+
+func TestBackendOverride(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-test", t.Name())
+	require.NoError(t, err)
+	statePath := filepath.Join(t.TempDir(), "override.tfstate")
+
+	options := &Options{
+		TerraformDir:    testFolder,
+		BackendOverride: &BackendOverride{Type: "local", Config: map[string]interface{}{"path": statePath}},
+	}
+	InitAndApply(t, options)
+	assert.FileExists(t, statePath)
+	assert.FileExists(t, filepath.Join(testFolder, OverrideFileName))
+}
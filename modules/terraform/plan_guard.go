@@ -0,0 +1,188 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// PlanGuard is a policy a plan must satisfy before it is applied, to catch changes that would be expensive or exceed
+// quotas before they are made. When set on the Options, InitAndApply checks the plan against the policy and only
+// applies it if there are no violations. The zero value of each field disables its check.
+type PlanGuard struct {
+	// The maximum number of resources the plan may create, counting replaced resources (as in the summary line of
+	// terraform plan).
+	MaxResourcesCreated int
+
+	// The types of resources the plan may not create (e.g., aws_nat_gateway). Each entry may be a pattern, as
+	// supported by path.Match (e.g., aws_nat_*).
+	DeniedResourceTypes []string
+
+	// The sizes that the resources the plan creates or updates are allowed to have.
+	SizeLimits []PlanGuardSizeLimit
+}
+
+// PlanGuardSizeLimit restricts the values an attribute that sets the size of a resource (e.g., the instance_type of
+// aws_instance) may be planned to have.
+type PlanGuardSizeLimit struct {
+	// The type of the resources to check (e.g., aws_instance). This may be a pattern, as supported by path.Match.
+	ResourceType string
+
+	// The path of the attribute to check (e.g., instance_type), in the syntax of GetPlannedAttribute.
+	Attribute string
+
+	// The values the attribute may have. Each entry may be a pattern, as supported by path.Match (e.g., t3.*).
+	Allowed []string
+}
+
+// PlanGuardViolation describes a change in a plan that violates a PlanGuard.
+type PlanGuardViolation struct {
+	// The address of the offending resource, or empty if the violation is about the plan as a whole.
+	Address string
+
+	Message string
+}
+
+// String renders the violation as a line of a violation report.
+func (violation PlanGuardViolation) String() string {
+	if violation.Address == "" {
+		return violation.Message
+	}
+	return fmt.Sprintf("%s: %s", violation.Address, violation.Message)
+}
+
+// Check returns the changes in the given plan that violate the guard, sorted by resource address, or an empty list if
+// the plan satisfies the guard. Values that are only known after apply can't be checked, so they never violate the
+// guard.
+func (guard *PlanGuard) Check(plan *PlanStruct) []PlanGuardViolation {
+	violations := []PlanGuardViolation{}
+
+	if guard.MaxResourcesCreated > 0 {
+		if created := GetResourceCountFromPlan(plan).Add; created > guard.MaxResourcesCreated {
+			violations = append(violations, PlanGuardViolation{
+				Message: fmt.Sprintf("the plan creates %d resources, but at most %d are allowed", created, guard.MaxResourcesCreated),
+			})
+		}
+	}
+
+	for _, address := range sortedResourceChangeAddresses(plan) {
+		resourceChange := plan.ResourceChangesMap[address]
+		if resourceChange.Mode == tfjson.DataResourceMode || resourceChange.Change == nil {
+			continue
+		}
+		actions := resourceChange.Change.Actions
+		creates := actions.Create() || actions.Replace()
+
+		if creates {
+			for _, deniedType := range guard.DeniedResourceTypes {
+				if matchesPattern(deniedType, resourceChange.Type) {
+					violations = append(violations, PlanGuardViolation{
+						Address: address,
+						Message: fmt.Sprintf("resources of type %s may not be created", resourceChange.Type),
+					})
+					break
+				}
+			}
+		}
+
+		if !creates && !actions.Update() {
+			continue
+		}
+		after, isMap := resourceChange.Change.After.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+		for _, limit := range guard.SizeLimits {
+			if !matchesPattern(limit.ResourceType, resourceChange.Type) {
+				continue
+			}
+			value, err := lookupAttributePath(after, address, limit.Attribute)
+			if err != nil || value == nil {
+				continue
+			}
+			if !matchesAnyPattern(limit.Allowed, fmt.Sprint(value)) {
+				violations = append(violations, PlanGuardViolation{
+					Address: address,
+					Message: fmt.Sprintf("%s is %v, but only %v are allowed", limit.Attribute, value, limit.Allowed),
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+// matchesPattern returns true if the given value matches the given path.Match pattern. An invalid pattern only matches
+// itself.
+func matchesPattern(pattern string, value string) bool {
+	matches, err := path.Match(pattern, value)
+	if err != nil {
+		return pattern == value
+	}
+	return matches
+}
+
+// matchesAnyPattern returns true if the given value matches any of the given path.Match patterns.
+func matchesAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertPlanGuard checks that the given plan satisfies the given guard, failing the test if it does not.
+func AssertPlanGuard(t testing.TestingT, plan *PlanStruct, guard *PlanGuard) {
+	violations := guard.Check(plan)
+	assert.Empty(t, violations, "%v", PlanGuardViolations(violations))
+}
+
+// RequirePlanGuard checks that the given plan satisfies the given guard, failing and halting the test if it does not.
+func RequirePlanGuard(t testing.TestingT, plan *PlanStruct, guard *PlanGuard) {
+	violations := guard.Check(plan)
+	require.Empty(t, violations, "%v", PlanGuardViolations(violations))
+}
+
+// applyWithPlanGuardE runs terraform plan, checks the plan against the PlanGuard of the given options, and applies the
+// plan if it satisfies the guard. The plan is saved to PlanFilePath, or to a temporary file if that is not set, so that
+// the changes applied are exactly the changes that were checked.
+func applyWithPlanGuardE(t testing.TestingT, options *Options) (string, error) {
+	planOptions, err := options.Clone()
+	if err != nil {
+		return "", err
+	}
+	if planOptions.PlanFilePath == "" {
+		tmpFile, err := ioutil.TempFile("", "terratest-plan-file-")
+		if err != nil {
+			return "", err
+		}
+		if err := tmpFile.Close(); err != nil {
+			return "", err
+		}
+		defer os.Remove(tmpFile.Name())
+		planOptions.PlanFilePath = tmpFile.Name()
+	}
+	// The plan is checked here, so ApplyE must not check it again.
+	planOptions.PlanGuard = nil
+
+	if _, err := PlanE(t, planOptions); err != nil {
+		return "", err
+	}
+	plan, err := ShowWithStructE(t, planOptions)
+	if err != nil {
+		return "", err
+	}
+	if violations := options.PlanGuard.Check(plan); len(violations) > 0 {
+		return "", PlanGuardViolations(violations)
+	}
+	options.Logger.Logf(t, "Plan for %s satisfies the PlanGuard, applying it", options.TerraformDir)
+
+	return ApplyE(t, planOptions)
+}
@@ -0,0 +1,173 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planJsonForPlanGuard is a hand written plan that creates a NAT gateway and instances of several sizes.
+const planJsonForPlanGuard = `
+{
+  "format_version": "1.0",
+  "terraform_version": "1.5.7",
+  "resource_changes": [
+    {
+      "address": "aws_instance.web",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "change": {"actions": ["create"], "before": null, "after": {"instance_type": "m5.4xlarge"}}
+    },
+    {
+      "address": "aws_instance.bastion",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "bastion",
+      "change": {"actions": ["create"], "before": null, "after": {"instance_type": "t3.micro"}}
+    },
+    {
+      "address": "aws_instance.computed",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "computed",
+      "change": {"actions": ["create"], "before": null, "after": {}, "after_unknown": {"instance_type": true}}
+    },
+    {
+      "address": "aws_nat_gateway.main",
+      "mode": "managed",
+      "type": "aws_nat_gateway",
+      "name": "main",
+      "change": {"actions": ["create"], "before": null, "after": {"connectivity_type": "public"}}
+    },
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "change": {"actions": ["update"], "before": {"instance_class": "db.t3.small"}, "after": {"instance_class": "db.r5.4xlarge"}}
+    },
+    {
+      "address": "data.aws_nat_gateway.existing",
+      "mode": "data",
+      "type": "aws_nat_gateway",
+      "name": "existing",
+      "change": {"actions": ["read"], "before": null, "after": {}}
+    }
+  ]
+}
+`
+
+func TestPlanGuardCheck(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonForPlanGuard)
+	require.NoError(t, err)
+
+	guard := &PlanGuard{
+		MaxResourcesCreated: 3,
+		DeniedResourceTypes: []string{"aws_nat_*"},
+		SizeLimits: []PlanGuardSizeLimit{
+			{ResourceType: "aws_instance", Attribute: "instance_type", Allowed: []string{"t3.*"}},
+			{ResourceType: "aws_db_instance", Attribute: "instance_class", Allowed: []string{"db.t3.small", "db.t3.medium"}},
+		},
+	}
+	assert.Equal(t, []PlanGuardViolation{
+		{Message: "the plan creates 4 resources, but at most 3 are allowed"},
+		{Address: "aws_db_instance.main", Message: "instance_class is db.r5.4xlarge, but only [db.t3.small db.t3.medium] are allowed"},
+		{Address: "aws_instance.web", Message: "instance_type is m5.4xlarge, but only [t3.*] are allowed"},
+		{Address: "aws_nat_gateway.main", Message: "resources of type aws_nat_gateway may not be created"},
+	}, guard.Check(plan))
+
+	AssertPlanGuard(t, plan, &PlanGuard{MaxResourcesCreated: 4, DeniedResourceTypes: []string{"aws_eip"}})
+	AssertPlanGuard(t, plan, &PlanGuard{})
+}
+
+func TestPlanGuardViolationsError(t *testing.T) {
+	t.Parallel()
+
+	err := PlanGuardViolations{
+		{Message: "the plan creates 4 resources, but at most 3 are allowed"},
+		{Address: "aws_nat_gateway.main", Message: "resources of type aws_nat_gateway may not be created"},
+	}
+	assert.Equal(t, "The plan violates the PlanGuard in 2 place(s), so it was not applied:\n"+
+		"  - the plan creates 4 resources, but at most 3 are allowed\n"+
+		"  - aws_nat_gateway.main: resources of type aws_nat_gateway may not be created", err.Error())
+}
+
+// writeFakePlanBinary writes a fake terraform binary that prints the given plan for show, and records the other
+// commands it runs in the returned log file.
+func writeFakePlanBinary(t *testing.T, planJson string) (string, string) {
+	dir := t.TempDir()
+	planPath := filepath.Join(dir, "plan.json")
+	logPath := filepath.Join(dir, "invocations.log")
+	require.NoError(t, ioutil.WriteFile(planPath, []byte(planJson), 0644))
+	script := fmt.Sprintf("#!/bin/sh\nif [ \"$1\" = \"show\" ]; then cat %q; exit 0; fi\necho \"$1\" >> %q\n", planPath, logPath)
+	binaryPath := filepath.Join(dir, "terraform")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath, logPath
+}
+
+func TestInitAndApplyWithPlanGuardViolations(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakePlanBinary(t, planJsonForPlanGuard)
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: binaryPath,
+		PlanGuard:       &PlanGuard{DeniedResourceTypes: []string{"aws_nat_gateway"}},
+	}
+	_, err := InitAndApplyE(t, options)
+	require.Error(t, err)
+	assert.Equal(t, PlanGuardViolations{
+		{Address: "aws_nat_gateway.main", Message: "resources of type aws_nat_gateway may not be created"},
+	}, err)
+	assert.Equal(t, []string{"init", "plan"}, readInvocations(t, logPath))
+	assert.Empty(t, options.PlanFilePath)
+}
+
+func TestInitAndApplyWithPlanGuardAppliesCheckedPlan(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakePlanBinary(t, planJsonForPlanGuard)
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: binaryPath,
+		PlanGuard:       &PlanGuard{MaxResourcesCreated: 10},
+	}
+	InitAndApply(t, options)
+	assert.Equal(t, []string{"init", "plan", "apply"}, readInvocations(t, logPath))
+}
+
+func TestApplyAndIdempotentWithPlanGuardViolations(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakePlanBinary(t, planJsonForPlanGuard)
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: binaryPath,
+		PlanGuard:       &PlanGuard{MaxResourcesCreated: 3},
+	}
+	_, err := ApplyAndIdempotentE(t, options)
+	assert.IsType(t, PlanGuardViolations{}, err)
+	assert.Equal(t, []string{"plan"}, readInvocations(t, logPath))
+}
+
+func TestTgApplyAllWithPlanGuard(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: "terragrunt",
+		PlanGuard:       &PlanGuard{MaxResourcesCreated: 3},
+	}
+	_, err := TgApplyAllE(t, options)
+	assert.Equal(t, PlanGuardNotSupported("TgApplyAllE"), err)
+
+	_, err = TgApplyAllByUnitE(t, options)
+	assert.Equal(t, PlanGuardNotSupported("TgApplyAllByUnitE"), err)
+}
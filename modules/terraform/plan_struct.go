@@ -25,6 +25,11 @@ type PlanStruct struct {
 	// A map that maps full resource addresses (e.g., module.foo.null_resource.test) to the planned actions terraform
 	// will take on that resource.
 	ResourceChangesMap map[string]*tfjson.ResourceChange
+
+	// A map that maps full resource addresses (e.g., module.foo.null_resource.test) to the changes terraform detected
+	// outside of terraform when refreshing that resource (the resource_drift section of the plan). Only resources that
+	// drifted are included.
+	ResourceDriftMap map[string]*tfjson.ResourceChange
 }
 
 // parsePlanJson takes in the json string representation of the terraform plan and returns a go struct representation
@@ -38,6 +43,12 @@ func parsePlanJson(jsonStr string) (*PlanStruct, error) {
 
 	plan.ResourcePlannedValuesMap = parsePlannedValues(plan)
 	plan.ResourceChangesMap = parseResourceChanges(plan)
+
+	resourceDrift, err := parseResourceDrift(jsonStr)
+	if err != nil {
+		return nil, err
+	}
+	plan.ResourceDriftMap = resourceDrift
 	return plan, nil
 }
 
@@ -51,6 +62,25 @@ func parseResourceChanges(plan *PlanStruct) map[string]*tfjson.ResourceChange {
 	return out
 }
 
+// parseResourceDrift takes in the json string representation of the terraform plan and returns a map that maps
+// resource addresses to the changes terraform detected outside of terraform for that resource. The version of
+// terraform-json we use does not expose the resource_drift section of the plan, so we parse it directly from the json.
+// If there is no drift, this returns an empty map instead of erroring.
+func parseResourceDrift(jsonStr string) (map[string]*tfjson.ResourceChange, error) {
+	var rawDrift struct {
+		ResourceDrift []*tfjson.ResourceChange `json:"resource_drift"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &rawDrift); err != nil {
+		return nil, err
+	}
+
+	out := map[string]*tfjson.ResourceChange{}
+	for _, change := range rawDrift.ResourceDrift {
+		out[change.Address] = change
+	}
+	return out, nil
+}
+
 // parsePlannedValues takes a plan and walks through the planned values to return a map that maps the full resource
 // addresses to the planned resources. If there are no planned values, this returns an empty map instead of erroring.
 func parsePlannedValues(plan *PlanStruct) map[string]*tfjson.StateResource {
@@ -0,0 +1,350 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AssertResourceCreated checks that the plan will create the resource at the given address, failing the test if it
+// will not. The address may also be a pattern (see MatchResourceAddress) to check every matching resource at once,
+// e.g., `aws_s3_bucket.this[*]`. The same holds for the other resource action assertions in this file.
+func AssertResourceCreated(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "created", tfjson.Actions.Create)
+	assert.True(t, ok, msg)
+}
+
+// RequireResourceCreated checks that the plan will create the resource at the given address, failing and halting the
+// test if it will not.
+func RequireResourceCreated(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "created", tfjson.Actions.Create)
+	require.True(t, ok, msg)
+}
+
+// AssertResourceUpdated checks that the plan will update the resource at the given address in-place, failing the test
+// if it will not.
+func AssertResourceUpdated(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "updated", tfjson.Actions.Update)
+	assert.True(t, ok, msg)
+}
+
+// RequireResourceUpdated checks that the plan will update the resource at the given address in-place, failing and
+// halting the test if it will not.
+func RequireResourceUpdated(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "updated", tfjson.Actions.Update)
+	require.True(t, ok, msg)
+}
+
+// AssertResourceReplaced checks that the plan will replace (either destroy before create, or create before destroy)
+// the resource at the given address, failing the test if it will not.
+func AssertResourceReplaced(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "replaced", tfjson.Actions.Replace)
+	assert.True(t, ok, msg)
+}
+
+// RequireResourceReplaced checks that the plan will replace (either destroy before create, or create before destroy)
+// the resource at the given address, failing and halting the test if it will not.
+func RequireResourceReplaced(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "replaced", tfjson.Actions.Replace)
+	require.True(t, ok, msg)
+}
+
+// AssertResourceDeleted checks that the plan will delete the resource at the given address, failing the test if it
+// will not. Note that this does not match resources that will be replaced; use AssertResourceReplaced for those.
+func AssertResourceDeleted(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "deleted", tfjson.Actions.Delete)
+	assert.True(t, ok, msg)
+}
+
+// RequireResourceDeleted checks that the plan will delete the resource at the given address, failing and halting the
+// test if it will not. Note that this does not match resources that will be replaced; use RequireResourceReplaced for
+// those.
+func RequireResourceDeleted(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "deleted", tfjson.Actions.Delete)
+	require.True(t, ok, msg)
+}
+
+// AssertResourceNoOp checks that the plan will not change the resource at the given address, failing the test if it
+// will.
+func AssertResourceNoOp(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "unchanged", tfjson.Actions.NoOp)
+	assert.True(t, ok, msg)
+}
+
+// RequireResourceNoOp checks that the plan will not change the resource at the given address, failing and halting the
+// test if it will.
+func RequireResourceNoOp(t testing.TestingT, plan *PlanStruct, address string) {
+	ok, msg := checkResourceAction(plan, address, "unchanged", tfjson.Actions.NoOp)
+	require.True(t, ok, msg)
+}
+
+// checkResourceAction looks up the planned change for the given address and checks the planned actions using the
+// given function. The address may be a pattern (see MatchResourceAddress), in which case at least one resource must
+// match and every matching resource must pass the check. When the check fails, this returns a message describing what
+// the plan will actually do, including a diff of the planned changes.
+func checkResourceAction(plan *PlanStruct, address string, expected string, check func(tfjson.Actions) bool) (bool, string) {
+	var failures []string
+	matched := 0
+	for _, matchedAddress := range resolveAddresses(sortedResourceChangeAddresses(plan), address) {
+		resourceChange := plan.ResourceChangesMap[matchedAddress]
+		if resourceChange.Change == nil {
+			continue
+		}
+		matched++
+		if !check(resourceChange.Change.Actions) {
+			failures = append(failures, fmt.Sprintf(
+				"Expected resource %s to be %s, but the planned actions are %v. Planned changes:\n%s",
+				matchedAddress, expected, resourceChange.Change.Actions, FormatResourceChangeDiff(resourceChange),
+			))
+		}
+	}
+	if matched == 0 {
+		return false, fmt.Sprintf("Expected resource %s to be %s, but the plan does not contain any changes for it", address, expected)
+	}
+	if len(failures) > 0 {
+		return false, strings.Join(failures, "\n")
+	}
+	return true, ""
+}
+
+// AssertNoResourcesOfTypeDestroyed checks that the plan will not destroy any resources of the given type (e.g.,
+// aws_db_instance), either by deleting or by replacing them, failing the test if it will.
+func AssertNoResourcesOfTypeDestroyed(t testing.TestingT, plan *PlanStruct, resourceType string) {
+	ok, msg := checkNoResourcesOfTypeDestroyed(plan, resourceType)
+	assert.True(t, ok, msg)
+}
+
+// RequireNoResourcesOfTypeDestroyed checks that the plan will not destroy any resources of the given type (e.g.,
+// aws_db_instance), either by deleting or by replacing them, failing and halting the test if it will.
+func RequireNoResourcesOfTypeDestroyed(t testing.TestingT, plan *PlanStruct, resourceType string) {
+	ok, msg := checkNoResourcesOfTypeDestroyed(plan, resourceType)
+	require.True(t, ok, msg)
+}
+
+// checkNoResourcesOfTypeDestroyed returns false, along with a message describing the offending changes, if any
+// resource of the given type will be deleted or replaced by the plan.
+func checkNoResourcesOfTypeDestroyed(plan *PlanStruct, resourceType string) (bool, string) {
+	var destroyed []string
+	for _, address := range sortedResourceChangeAddresses(plan) {
+		resourceChange := plan.ResourceChangesMap[address]
+		if resourceChange.Type != resourceType || resourceChange.Change == nil {
+			continue
+		}
+		if actionsDestroy(resourceChange.Change.Actions) {
+			destroyed = append(destroyed, fmt.Sprintf("%s (%v):\n%s", address, resourceChange.Change.Actions, FormatResourceChangeDiff(resourceChange)))
+		}
+	}
+	if len(destroyed) == 0 {
+		return true, ""
+	}
+	return false, fmt.Sprintf("Expected no resources of type %s to be destroyed, but the plan destroys %d:\n%s", resourceType, len(destroyed), strings.Join(destroyed, "\n"))
+}
+
+// actionsDestroy returns true if the given actions will destroy the existing resource, either by deleting or by
+// replacing it.
+func actionsDestroy(actions tfjson.Actions) bool {
+	return actions.Delete() || actions.Replace()
+}
+
+// GetResourceCountFromPlan counts the resources the plan will add, change, and destroy, using the same rules as the
+// summary line Terraform prints at the end of plan (a replaced resource counts as both an add and a destroy, and data
+// sources are not counted).
+func GetResourceCountFromPlan(plan *PlanStruct) *ResourceCount {
+	cnt := ResourceCount{}
+	for _, resourceChange := range plan.ResourceChangesMap {
+		if resourceChange.Mode == tfjson.DataResourceMode || resourceChange.Change == nil {
+			continue
+		}
+		actions := resourceChange.Change.Actions
+		switch {
+		case actions.Create():
+			cnt.Add++
+		case actions.Update():
+			cnt.Change++
+		case actions.Delete():
+			cnt.Destroy++
+		case actions.Replace():
+			cnt.Add++
+			cnt.Destroy++
+		}
+	}
+	return &cnt
+}
+
+// AssertPlanResourceCount checks that the plan will add, change, and destroy exactly the given number of resources,
+// failing the test if it will not.
+func AssertPlanResourceCount(t testing.TestingT, plan *PlanStruct, expected ResourceCount) {
+	assert.Equal(t, expected, *GetResourceCountFromPlan(plan), "Unexpected number of planned resource changes. Planned changes:\n%s", formatPlanDiff(plan))
+}
+
+// RequirePlanResourceCount checks that the plan will add, change, and destroy exactly the given number of resources,
+// failing and halting the test if it will not.
+func RequirePlanResourceCount(t testing.TestingT, plan *PlanStruct, expected ResourceCount) {
+	require.Equal(t, expected, *GetResourceCountFromPlan(plan), "Unexpected number of planned resource changes. Planned changes:\n%s", formatPlanDiff(plan))
+}
+
+// GetPlannedAttribute returns the planned value of the attribute at the given path of the resource at the given
+// address. The path uses Terraform's own syntax for referencing nested attributes, e.g., `tags["Name"]` or
+// `ebs_block_device[0].volume_size`. This will fail the test if the resource or the attribute is not in the plan.
+func GetPlannedAttribute(t testing.TestingT, plan *PlanStruct, address string, path string) interface{} {
+	value, err := GetPlannedAttributeE(plan, address, path)
+	require.NoError(t, err)
+	return value
+}
+
+// GetPlannedAttributeE returns the planned value of the attribute at the given path of the resource at the given
+// address. The path uses Terraform's own syntax for referencing nested attributes, e.g., `tags["Name"]` or
+// `ebs_block_device[0].volume_size`.
+func GetPlannedAttributeE(plan *PlanStruct, address string, path string) (interface{}, error) {
+	resource, hasKey := plan.ResourcePlannedValuesMap[address]
+	if !hasKey {
+		return nil, ResourceNotFoundInPlan(address)
+	}
+	return lookupAttributePath(resource.AttributeValues, address, path)
+}
+
+// AssertPlannedAttributeEquals checks that the planned value of the attribute at the given path of the resource at the
+// given address equals the expected value, failing the test if it does not. Values are compared with
+// assert.EqualValues, so an expected int matches the float64 that numbers are decoded to from the plan json. The
+// address may be a pattern (see MatchResourceAddress), in which case every matching resource is checked. See
+// GetPlannedAttributeE for the path syntax.
+func AssertPlannedAttributeEquals(t testing.TestingT, plan *PlanStruct, address string, path string, expected interface{}) {
+	for _, matchedAddress := range resolvePlannedValuesAddresses(plan, address) {
+		actual, msg, err := checkPlannedAttribute(plan, matchedAddress, path)
+		if assert.NoError(t, err, msg) {
+			assert.EqualValues(t, expected, actual, msg)
+		}
+	}
+}
+
+// RequirePlannedAttributeEquals checks that the planned value of the attribute at the given path of the resource at the
+// given address equals the expected value, failing and halting the test if it does not. See
+// AssertPlannedAttributeEquals for details.
+func RequirePlannedAttributeEquals(t testing.TestingT, plan *PlanStruct, address string, path string, expected interface{}) {
+	for _, matchedAddress := range resolvePlannedValuesAddresses(plan, address) {
+		actual, msg, err := checkPlannedAttribute(plan, matchedAddress, path)
+		require.NoError(t, err, msg)
+		require.EqualValues(t, expected, actual, msg)
+	}
+}
+
+// resolvePlannedValuesAddresses returns the addresses in the planned values that the given address or pattern refers
+// to. If nothing matches, the address is returned as is so that the lookup fails with a ResourceNotFoundInPlan error.
+func resolvePlannedValuesAddresses(plan *PlanStruct, address string) []string {
+	addresses := make([]string, 0, len(plan.ResourcePlannedValuesMap))
+	for plannedAddress := range plan.ResourcePlannedValuesMap {
+		addresses = append(addresses, plannedAddress)
+	}
+	matched := resolveAddresses(addresses, address)
+	if len(matched) == 0 {
+		return []string{address}
+	}
+	return matched
+}
+
+// checkPlannedAttribute looks up the planned attribute and returns it along with a message containing the planned
+// changes for the resource, to be used if the assertion fails.
+func checkPlannedAttribute(plan *PlanStruct, address string, path string) (interface{}, string, error) {
+	msg := fmt.Sprintf("Unexpected planned value for attribute %s of resource %s.", path, address)
+	if resourceChange, hasKey := plan.ResourceChangesMap[address]; hasKey {
+		msg = fmt.Sprintf("%s Planned changes:\n%s", msg, FormatResourceChangeDiff(resourceChange))
+	}
+	actual, err := GetPlannedAttributeE(plan, address, path)
+	return actual, msg, err
+}
+
+// lookupAttributePath walks the given attribute values following the given path, returning the value at the end of
+// the path.
+func lookupAttributePath(attributes map[string]interface{}, address string, path string) (interface{}, error) {
+	steps, err := parseAttributePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var current interface{} = attributes
+	for _, step := range steps {
+		switch typedStep := step.(type) {
+		case string:
+			asMap, isMap := current.(map[string]interface{})
+			if !isMap {
+				return nil, AttributeNotFoundInPlan{Address: address, Path: path}
+			}
+			value, hasKey := asMap[typedStep]
+			if !hasKey {
+				return nil, AttributeNotFoundInPlan{Address: address, Path: path}
+			}
+			current = value
+		case int:
+			asList, isList := current.([]interface{})
+			if !isList || typedStep < 0 || typedStep >= len(asList) {
+				return nil, AttributeNotFoundInPlan{Address: address, Path: path}
+			}
+			current = asList[typedStep]
+		}
+	}
+	return current, nil
+}
+
+// parseAttributePath splits an attribute path such as `foo.bar[0]["baz.qux"]` into its steps, where each step is either
+// a string (a map key or object attribute) or an int (a list index).
+func parseAttributePath(path string) ([]interface{}, error) {
+	var steps []interface{}
+	remaining := path
+	expectAttr := true
+	for remaining != "" {
+		switch {
+		case remaining[0] == '[':
+			end := strings.Index(remaining, "]")
+			if end < 0 {
+				return nil, InvalidAttributePath(path)
+			}
+			key := remaining[1:end]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				steps = append(steps, unquoted)
+			} else if index, err := strconv.Atoi(key); err == nil {
+				steps = append(steps, index)
+			} else {
+				return nil, InvalidAttributePath(path)
+			}
+			remaining = remaining[end+1:]
+			expectAttr = false
+		case remaining[0] == '.' && !expectAttr:
+			remaining = remaining[1:]
+			expectAttr = true
+		case expectAttr:
+			end := strings.IndexAny(remaining, ".[")
+			if end < 0 {
+				end = len(remaining)
+			}
+			if end == 0 {
+				return nil, InvalidAttributePath(path)
+			}
+			steps = append(steps, remaining[:end])
+			remaining = remaining[end:]
+			expectAttr = false
+		default:
+			return nil, InvalidAttributePath(path)
+		}
+	}
+	if len(steps) == 0 || expectAttr {
+		return nil, InvalidAttributePath(path)
+	}
+	return steps, nil
+}
+
+// sortedResourceChangeAddresses returns the addresses in the resource changes map of the plan in sorted order, so that
+// messages that iterate over the map are stable.
+func sortedResourceChangeAddresses(plan *PlanStruct) []string {
+	addresses := make([]string, 0, len(plan.ResourceChangesMap))
+	for address := range plan.ResourceChangesMap {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
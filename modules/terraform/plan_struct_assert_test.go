@@ -0,0 +1,236 @@
+package terraform
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planJsonWithAllActions is a hand written plan that exercises every kind of planned action, so that the assertion
+// helpers can be tested without running terraform.
+const planJsonWithAllActions = `
+{
+  "format_version": "1.0",
+  "terraform_version": "1.3.7",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_instance.web",
+          "mode": "managed",
+          "type": "aws_instance",
+          "name": "web",
+          "schema_version": 1,
+          "values": {
+            "instance_type": "t3.micro",
+            "ebs_block_device": [{"volume_size": 20}],
+            "tags": {"Name": "web", "kubernetes.io/cluster": "owned"}
+          }
+        },
+        {
+          "address": "aws_s3_bucket.logs",
+          "mode": "managed",
+          "type": "aws_s3_bucket",
+          "name": "logs",
+          "schema_version": 0,
+          "values": {"bucket": "logs", "tags": {"Env": "prod"}}
+        },
+        {
+          "address": "aws_db_instance.main",
+          "mode": "managed",
+          "type": "aws_db_instance",
+          "name": "main",
+          "schema_version": 0,
+          "values": {"engine": "postgres", "engine_version": "14"}
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.x[\"a\"]",
+          "resources": [
+            {
+              "address": "module.x[\"a\"].aws_instance.y[0]",
+              "mode": "managed",
+              "type": "aws_instance",
+              "name": "y",
+              "index": 0,
+              "schema_version": 1,
+              "values": {"instance_type": "t3.small"}
+            }
+          ]
+        }
+      ]
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "aws_instance.web",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {
+          "instance_type": "t3.micro",
+          "ebs_block_device": [{"volume_size": 20}],
+          "tags": {"Name": "web", "kubernetes.io/cluster": "owned"}
+        },
+        "after_unknown": {"id": true, "ebs_block_device": [{}], "tags": {}}
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "logs", "bucket": "logs", "tags": {"Env": "dev"}},
+        "after": {"id": "logs", "bucket": "logs", "tags": {"Env": "prod"}},
+        "after_unknown": {"tags": {}}
+      }
+    },
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "change": {
+        "actions": ["delete", "create"],
+        "before": {"id": "db-1", "engine": "postgres", "engine_version": "13"},
+        "after": {"engine": "postgres", "engine_version": "14"},
+        "after_unknown": {"id": true}
+      }
+    },
+    {
+      "address": "aws_security_group.old",
+      "mode": "managed",
+      "type": "aws_security_group",
+      "name": "old",
+      "change": {
+        "actions": ["delete"],
+        "before": {"id": "sg-1", "name": "old"},
+        "after": null,
+        "after_unknown": {}
+      }
+    },
+    {
+      "address": "module.x[\"a\"].aws_instance.y[0]",
+      "module_address": "module.x[\"a\"]",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "y",
+      "index": 0,
+      "change": {
+        "actions": ["no-op"],
+        "before": {"id": "i-1", "instance_type": "t3.small"},
+        "after": {"id": "i-1", "instance_type": "t3.small"},
+        "after_unknown": {}
+      }
+    },
+    {
+      "address": "data.aws_ami.ubuntu",
+      "mode": "data",
+      "type": "aws_ami",
+      "name": "ubuntu",
+      "change": {
+        "actions": ["read"],
+        "before": null,
+        "after": {"most_recent": true},
+        "after_unknown": {"id": true}
+      }
+    }
+  ]
+}
+`
+
+func TestPlanResourceActionAssertions(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	RequireResourceCreated(t, plan, "aws_instance.web")
+	RequireResourceUpdated(t, plan, "aws_s3_bucket.logs")
+	RequireResourceReplaced(t, plan, "aws_db_instance.main")
+	RequireResourceDeleted(t, plan, "aws_security_group.old")
+	RequireResourceNoOp(t, plan, `module.x["a"].aws_instance.y[0]`)
+
+	ok, msg := checkResourceAction(plan, "aws_db_instance.main", "updated", tfjson.Actions.Update)
+	assert.False(t, ok)
+	assert.Contains(t, msg, `~ engine_version: "13" -> "14"`)
+	assert.Contains(t, msg, `~ id: "db-1" -> (known after apply)`)
+
+	ok, msg = checkResourceAction(plan, "aws_instance.missing", "created", tfjson.Actions.Create)
+	assert.False(t, ok)
+	assert.Contains(t, msg, "does not contain any changes")
+}
+
+func TestNoResourcesOfTypeDestroyed(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	RequireNoResourcesOfTypeDestroyed(t, plan, "aws_instance")
+	RequireNoResourcesOfTypeDestroyed(t, plan, "aws_s3_bucket")
+
+	for _, resourceType := range []string{"aws_db_instance", "aws_security_group"} {
+		ok, msg := checkNoResourcesOfTypeDestroyed(plan, resourceType)
+		assert.False(t, ok)
+		assert.Contains(t, msg, resourceType)
+	}
+}
+
+func TestGetResourceCountFromPlan(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	RequirePlanResourceCount(t, plan, ResourceCount{Add: 2, Change: 1, Destroy: 2})
+}
+
+func TestPlannedAttributeAssertions(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	RequirePlannedAttributeEquals(t, plan, "aws_instance.web", "instance_type", "t3.micro")
+	RequirePlannedAttributeEquals(t, plan, "aws_instance.web", "ebs_block_device[0].volume_size", 20)
+	RequirePlannedAttributeEquals(t, plan, "aws_instance.web", `tags["kubernetes.io/cluster"]`, "owned")
+	RequirePlannedAttributeEquals(t, plan, "aws_instance.web", "tags.Name", "web")
+
+	_, err = GetPlannedAttributeE(plan, "aws_instance.web", "ebs_block_device[1].volume_size")
+	assert.IsType(t, AttributeNotFoundInPlan{}, err)
+	_, err = GetPlannedAttributeE(plan, "aws_instance.missing", "id")
+	assert.IsType(t, ResourceNotFoundInPlan(""), err)
+}
+
+func TestParseAttributePath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path     string
+		expected []interface{}
+	}{
+		{"foo", []interface{}{"foo"}},
+		{"foo.bar", []interface{}{"foo", "bar"}},
+		{"foo[0].bar", []interface{}{"foo", 0, "bar"}},
+		{`foo["a.b"][2]`, []interface{}{"foo", "a.b", 2}},
+	}
+	for _, testCase := range testCases {
+		steps, err := parseAttributePath(testCase.path)
+		require.NoError(t, err, testCase.path)
+		assert.Equal(t, testCase.expected, steps, testCase.path)
+	}
+
+	for _, invalidPath := range []string{"", "foo.", ".foo", "foo..bar", "foo[bar]", "foo[0", "foo[0]bar"} {
+		_, err := parseAttributePath(invalidPath)
+		assert.Error(t, err, invalidPath)
+	}
+}
@@ -0,0 +1,202 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// AttributeChange represents a change to a single (leaf) attribute of a resource between the before and after values
+// of a change in a plan.
+type AttributeChange struct {
+	// The path of the attribute, in the same syntax accepted by GetPlannedAttributeE (e.g., tags["Name"]).
+	Path string
+
+	// The value of the attribute before the change. This is nil if the attribute did not exist before the change.
+	Before interface{}
+
+	// The value of the attribute after the change. This is nil if the attribute will not exist after the change, or if
+	// AfterUnknown is true.
+	After interface{}
+
+	// Whether the attribute existed before and will exist after the change, respectively.
+	InBefore bool
+	InAfter  bool
+
+	// Whether the value after the change will only be known after apply.
+	AfterUnknown bool
+
+	// Whether the value before or after the change is marked as sensitive. Sensitive values are replaced by
+	// SensitiveValuePlaceholder in Before and After.
+	Sensitive bool
+}
+
+// GetAttributeChanges returns the changes to the attributes of the given change, sorted by path. Only attributes that
+// actually differ between before and after (or that will be known after apply) are returned. Values that terraform
+// marks as sensitive are redacted.
+func GetAttributeChanges(change *tfjson.Change) []AttributeChange {
+	if change == nil {
+		return nil
+	}
+
+	// We compare the raw values to find what changed, but only ever return the redacted values, so that changes to
+	// sensitive attributes are still reported without revealing them.
+	rawBefore := flattenAttributes(change.Before)
+	rawAfter := flattenAttributes(change.After)
+	before := flattenAttributes(redactValue(change.Before, change.BeforeSensitive))
+	after := flattenAttributes(redactValue(change.After, change.AfterSensitive))
+	afterUnknown := flattenAttributes(change.AfterUnknown)
+
+	keySet := map[string]bool{}
+	for _, flattened := range []map[string]interface{}{rawBefore, rawAfter, afterUnknown} {
+		for key := range flattened {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out []AttributeChange
+	for _, key := range keys {
+		rawBeforeVal, inBefore := rawBefore[key]
+		rawAfterVal, inAfter := rawAfter[key]
+		isUnknown := afterUnknown[key] == true
+		if !isUnknown && inBefore == inAfter && formatDiffValue(rawBeforeVal) == formatDiffValue(rawAfterVal) {
+			continue
+		}
+		beforeVal, afterVal := redactedLeaf(before, key, rawBeforeVal), redactedLeaf(after, key, rawAfterVal)
+		out = append(out, AttributeChange{
+			Path:         key,
+			Before:       beforeVal,
+			After:        afterVal,
+			InBefore:     inBefore,
+			InAfter:      inAfter || isUnknown,
+			AfterUnknown: isUnknown,
+			Sensitive:    beforeVal == SensitiveValuePlaceholder || afterVal == SensitiveValuePlaceholder,
+		})
+	}
+	return out
+}
+
+// redactedLeaf returns the value at the given path of the flattened redacted attributes. As a whole object or list can
+// be marked as sensitive, the path may not exist in the redacted attributes even though it exists in the raw ones, in
+// which case the placeholder is returned.
+func redactedLeaf(redacted map[string]interface{}, key string, rawValue interface{}) interface{} {
+	if value, hasKey := redacted[key]; hasKey {
+		return value
+	}
+	if rawValue == nil {
+		return nil
+	}
+	return SensitiveValuePlaceholder
+}
+
+// String renders the attribute change as a single line of a diff, in a format similar to `terraform plan`.
+func (change AttributeChange) String() string {
+	afterStr := formatDiffValue(change.After)
+	if change.AfterUnknown {
+		afterStr = "(known after apply)"
+	}
+
+	switch {
+	case change.InBefore && change.InAfter:
+		return fmt.Sprintf("~ %s: %s -> %s", change.Path, formatDiffValue(change.Before), afterStr)
+	case change.InBefore:
+		return fmt.Sprintf("- %s: %s", change.Path, formatDiffValue(change.Before))
+	default:
+		return fmt.Sprintf("+ %s: %s", change.Path, afterStr)
+	}
+}
+
+// FormatResourceChangeDiff renders the planned change to a resource as a human readable diff of its attributes, with
+// one line per attribute that differs between the before and after values, in a format similar to `terraform plan`.
+// Values that terraform marks as sensitive are redacted.
+func FormatResourceChangeDiff(resourceChange *tfjson.ResourceChange) string {
+	if resourceChange == nil || resourceChange.Change == nil {
+		return ""
+	}
+	return formatAttributeChanges(GetAttributeChanges(resourceChange.Change))
+}
+
+// formatAttributeChanges renders the given attribute changes as an indented diff, one line per attribute.
+func formatAttributeChanges(changes []AttributeChange) string {
+	if len(changes) == 0 {
+		return "  (no attribute changes)"
+	}
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, "  "+change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPlanDiff renders the diff of every resource the plan will change, sorted by address.
+func formatPlanDiff(plan *PlanStruct) string {
+	var sections []string
+	for _, address := range sortedResourceChangeAddresses(plan) {
+		resourceChange := plan.ResourceChangesMap[address]
+		if resourceChange.Change == nil || resourceChange.Change.Actions.NoOp() || resourceChange.Change.Actions.Read() {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("%s (%v):\n%s", address, resourceChange.Change.Actions, FormatResourceChangeDiff(resourceChange)))
+	}
+	return strings.Join(sections, "\n")
+}
+
+// flattenAttributes flattens the given nested attribute values into a map that maps the path of each leaf value (using
+// the same syntax that parseAttributePath accepts) to the value. Empty maps and lists are treated as leaf values.
+func flattenAttributes(value interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	flattenAttributesInto(out, "", value)
+	return out
+}
+
+func flattenAttributesInto(out map[string]interface{}, prefix string, value interface{}) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if len(typedValue) == 0 && prefix != "" {
+			out[prefix] = typedValue
+		}
+		for key, nested := range typedValue {
+			flattenAttributesInto(out, joinAttributePath(prefix, key), nested)
+		}
+	case []interface{}:
+		if len(typedValue) == 0 && prefix != "" {
+			out[prefix] = typedValue
+		}
+		for i, nested := range typedValue {
+			flattenAttributesInto(out, fmt.Sprintf("%s[%d]", prefix, i), nested)
+		}
+	default:
+		if prefix != "" {
+			out[prefix] = typedValue
+		}
+	}
+}
+
+// joinAttributePath appends the given key to the attribute path, quoting it if it can't be expressed as a bare
+// attribute name.
+func joinAttributePath(prefix string, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\" ") {
+		return fmt.Sprintf("%s[%q]", prefix, key)
+	}
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// formatDiffValue renders a single value in a diff as json, so strings are quoted and nulls are explicit.
+func formatDiffValue(value interface{}) string {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(out)
+}
@@ -0,0 +1,142 @@
+package terraform
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// FindResourcePlannedValues returns all the planned values in the plan whose address matches the given pattern,
+// keyed by full resource address. See MatchResourceAddress for the pattern syntax. If nothing matches, this returns an
+// empty map.
+func FindResourcePlannedValues(plan *PlanStruct, pattern string) map[string]*tfjson.StateResource {
+	out := map[string]*tfjson.StateResource{}
+	for address, resource := range plan.ResourcePlannedValuesMap {
+		if MatchResourceAddress(pattern, address) {
+			out[address] = resource
+		}
+	}
+	return out
+}
+
+// FindResourceChanges returns all the resource changes in the plan whose address matches the given pattern, keyed by
+// full resource address. See MatchResourceAddress for the pattern syntax. If nothing matches, this returns an empty
+// map.
+func FindResourceChanges(plan *PlanStruct, pattern string) map[string]*tfjson.ResourceChange {
+	out := map[string]*tfjson.ResourceChange{}
+	for address, change := range plan.ResourceChangesMap {
+		if MatchResourceAddress(pattern, address) {
+			out[address] = change
+		}
+	}
+	return out
+}
+
+// MatchResourceAddress returns true if the given resource address matches the given pattern. The pattern is a resource
+// address where:
+//
+//   - `*` matches any sequence of characters within a single step of the address (the parts separated by `.`), so
+//     `module.*.aws_instance.*` matches `module.x["a"].aws_instance.y[3]` and `aws_s3_bucket.this[*]` matches every
+//     instance of a resource created with count or for_each.
+//   - `**` as a whole step matches any number of steps, so `**.aws_instance.*` matches instances at any module depth.
+//
+// Dots and wildcards within the index of an address (e.g., `["a.b"]`) are treated literally when they appear in the
+// address, so module keys containing dots are handled correctly.
+func MatchResourceAddress(pattern string, address string) bool {
+	if pattern == address {
+		return true
+	}
+	return matchAddressSteps(splitAddressSteps(pattern), splitAddressSteps(address))
+}
+
+// isAddressPattern returns true if the given address contains wildcards.
+func isAddressPattern(address string) bool {
+	return strings.Contains(address, "*")
+}
+
+// matchAddressSteps recursively matches the steps of a pattern against the steps of an address.
+func matchAddressSteps(patternSteps []string, addressSteps []string) bool {
+	if len(patternSteps) == 0 {
+		return len(addressSteps) == 0
+	}
+
+	if patternSteps[0] == "**" {
+		// Try to match the rest of the pattern against every possible suffix of the address, including the full
+		// address (i.e., ** matching zero steps).
+		for i := 0; i <= len(addressSteps); i++ {
+			if matchAddressSteps(patternSteps[1:], addressSteps[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(addressSteps) == 0 || !matchAddressStep(patternSteps[0], addressSteps[0]) {
+		return false
+	}
+	return matchAddressSteps(patternSteps[1:], addressSteps[1:])
+}
+
+// matchAddressStep matches a single step of a pattern, in which `*` matches any sequence of characters, against a
+// single step of an address.
+func matchAddressStep(patternStep string, addressStep string) bool {
+	if !strings.Contains(patternStep, "*") {
+		return patternStep == addressStep
+	}
+
+	parts := strings.Split(patternStep, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+	return re.MatchString(addressStep)
+}
+
+// splitAddressSteps splits a resource address into its steps on `.`, ignoring dots that are within the brackets of an
+// index (e.g., `module.x["a.b"].null_resource.y` splits into `module`, `x["a.b"]`, `null_resource`, and `y`).
+func splitAddressSteps(address string) []string {
+	var steps []string
+	var current strings.Builder
+	inBrackets := false
+	inQuotes := false
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(address):
+			current.WriteByte(c)
+			i++
+			c = address[i]
+		case c == '"' && inBrackets:
+			inQuotes = !inQuotes
+		case c == '[' && !inQuotes:
+			inBrackets = true
+		case c == ']' && !inQuotes:
+			inBrackets = false
+		case c == '.' && !inBrackets:
+			steps = append(steps, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	return append(steps, current.String())
+}
+
+// resolveAddresses returns the addresses that the given address or pattern refers to, in sorted order. If the address
+// exists in the given set of addresses as is, it is returned as the only entry even if it contains wildcards, so that
+// for_each keys containing `*` can still be referenced directly.
+func resolveAddresses(addresses []string, addressOrPattern string) []string {
+	var matched []string
+	for _, address := range addresses {
+		if address == addressOrPattern {
+			return []string{address}
+		}
+		if isAddressPattern(addressOrPattern) && MatchResourceAddress(addressOrPattern, address) {
+			matched = append(matched, address)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
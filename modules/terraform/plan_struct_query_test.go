@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchResourceAddress(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		pattern  string
+		address  string
+		expected bool
+	}{
+		{"aws_instance.web", "aws_instance.web", true},
+		{"aws_instance.web", "aws_instance.web[0]", false},
+		{"aws_s3_bucket.this[*]", "aws_s3_bucket.this[0]", true},
+		{"aws_s3_bucket.this[*]", `aws_s3_bucket.this["logs"]`, true},
+		{"aws_s3_bucket.this[*]", "aws_s3_bucket.this", false},
+		{"module.*.aws_instance.*", `module.x["a"].aws_instance.y[3]`, true},
+		{"module.*.aws_instance.*", `module.x["a.b"].aws_instance.y[3]`, true},
+		{"module.*.aws_instance.*", "aws_instance.y", false},
+		{"module.*.aws_instance.*", "module.x.module.z.aws_instance.y", false},
+		{"**.aws_instance.*", "module.x.module.z.aws_instance.y", true},
+		{"**.aws_instance.*", "aws_instance.y", true},
+		{"aws_*.web", "aws_instance.web", true},
+		{"aws_*.web", "google_compute_instance.web", false},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, MatchResourceAddress(testCase.pattern, testCase.address), "%s ~ %s", testCase.pattern, testCase.address)
+	}
+}
+
+func TestFindResourcesWithPattern(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	plannedValues := FindResourcePlannedValues(plan, "**.aws_instance.*")
+	assert.Len(t, plannedValues, 2)
+	assert.Contains(t, plannedValues, "aws_instance.web")
+	assert.Contains(t, plannedValues, `module.x["a"].aws_instance.y[0]`)
+
+	changes := FindResourceChanges(plan, "module.*.aws_instance.y[*]")
+	assert.Len(t, changes, 1)
+	assert.Contains(t, changes, `module.x["a"].aws_instance.y[0]`)
+
+	assert.Empty(t, FindResourceChanges(plan, "aws_lambda_function.*"))
+}
+
+func TestResourceAssertionsWithPattern(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithAllActions)
+	require.NoError(t, err)
+
+	RequireResourceNoOp(t, plan, "module.*.aws_instance.*")
+	RequirePlannedAttributeEquals(t, plan, "module.*.aws_instance.y[*]", "instance_type", "t3.small")
+
+	ok, msg := checkResourceAction(plan, "**.aws_instance.*", "created", tfjson.Actions.Create)
+	assert.False(t, ok)
+	assert.Contains(t, msg, `module.x["a"].aws_instance.y[0]`)
+	assert.NotContains(t, msg, "Expected resource aws_instance.web")
+
+	ok, _ = checkResourceAction(plan, "aws_lambda_function.*", "created", tfjson.Actions.Create)
+	assert.False(t, ok)
+}
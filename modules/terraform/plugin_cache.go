@@ -0,0 +1,257 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultPluginCacheDir is the plugin cache that UsePluginCache and IsolatedState share between tests, unless
+// TF_PLUGIN_CACHE_DIR is already set.
+var DefaultPluginCacheDir = filepath.Join(os.TempDir(), "terratest-plugin-cache")
+
+// PluginCacheLockTimeout is how long to wait for another init to release a plugin cache or provider mirror. The
+// holder of a lock refreshes it while it runs, so a lock that has not been refreshed for this long is considered stale
+// (e.g., left behind by a test binary that was killed) and is removed.
+var PluginCacheLockTimeout = 10 * time.Minute
+
+// pluginCacheLockPollInterval is how often to check whether a lock was released.
+const pluginCacheLockPollInterval = 100 * time.Millisecond
+
+// pluginCacheLockFileName is the name of the lock file created in a plugin cache or provider mirror while terraform
+// writes to it.
+const pluginCacheLockFileName = ".terratest.lock"
+
+// managedPluginCaches records the plugin caches set up by UsePluginCache and IsolatedState. Init only locks these, as
+// other caches (e.g., one set in TF_PLUGIN_CACHE_DIR by the user) are not meant to be shared by parallel tests.
+var managedPluginCaches = struct {
+	sync.Mutex
+	dirs map[string]bool
+}{dirs: map[string]bool{}}
+
+// providerMirrors records the provider mirrors built by this process, keyed by the path of the mirror and the hash of
+// the lock file it was built from.
+var providerMirrors = struct {
+	sync.Mutex
+	built map[string]bool
+}{built: map[string]bool{}}
+
+// UsePluginCache sets up a plugin cache at the given path (DefaultPluginCacheDir if empty) and sets
+// TF_PLUGIN_CACHE_DIR for the whole process, so that all the terraform commands of all the tests share the providers
+// they download. Call this once, e.g. in TestMain. Init holds a lock on the cache while it runs, as terraform does
+// not support concurrent writes to the cache. This will fail the test if the cache can't be created.
+func UsePluginCache(t testing.TestingT, path string) string {
+	cacheDir, err := UsePluginCacheE(path)
+	require.NoError(t, err)
+	return cacheDir
+}
+
+// UsePluginCacheE sets up a plugin cache at the given path (DefaultPluginCacheDir if empty) and sets
+// TF_PLUGIN_CACHE_DIR for the whole process. The absolute path of the cache is returned.
+func UsePluginCacheE(path string) (string, error) {
+	if path == "" {
+		path = DefaultPluginCacheDir
+	}
+	cacheDir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", err
+	}
+	addManagedPluginCache(cacheDir)
+	return cacheDir, os.Setenv("TF_PLUGIN_CACHE_DIR", cacheDir)
+}
+
+// addManagedPluginCache records that the given plugin cache was set up by terratest, so that Init locks it.
+func addManagedPluginCache(cacheDir string) {
+	managedPluginCaches.Lock()
+	defer managedPluginCaches.Unlock()
+	managedPluginCaches.dirs[cacheDir] = true
+}
+
+// managedPluginCacheDir returns the plugin cache the terraform commands run with the given options use, if it was set
+// up by UsePluginCache or IsolatedState, or an empty string otherwise.
+func managedPluginCacheDir(options *Options) string {
+	dir := pluginCacheDir(options)
+	if dir == "" {
+		return ""
+	}
+	cacheDir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	managedPluginCaches.Lock()
+	defer managedPluginCaches.Unlock()
+	if managedPluginCaches.dirs[cacheDir] {
+		return cacheDir
+	}
+	return ""
+}
+
+// pluginCacheDir returns the plugin cache the terraform commands run with the given options use, or an empty string
+// if they don't use one.
+func pluginCacheDir(options *Options) string {
+	if cacheDir, hasCacheDir := options.EnvVars["TF_PLUGIN_CACHE_DIR"]; hasCacheDir {
+		return cacheDir
+	}
+	return os.Getenv("TF_PLUGIN_CACHE_DIR")
+}
+
+// BuildProviderMirror builds a filesystem mirror of the providers in the lock file (.terraform.lock.hcl) of
+// TerraformDir at the given path, for the given platforms (the current platform if none are given), and returns the
+// path of the mirror. The mirror is only built once per lock file, even across parallel tests and test binaries, as
+// long as they use the same path. This will fail the test if the mirror can't be built.
+func BuildProviderMirror(t testing.TestingT, options *Options, mirrorDir string, platforms ...string) string {
+	out, err := BuildProviderMirrorE(t, options, mirrorDir, platforms...)
+	require.NoError(t, err)
+	return out
+}
+
+// BuildProviderMirrorE builds a filesystem mirror of the providers in the lock file (.terraform.lock.hcl) of
+// TerraformDir at the given path, for the given platforms (the current platform if none are given), and returns the
+// absolute path of the mirror. The mirror is only built once per lock file, even across parallel tests and test
+// binaries, as long as they use the same path.
+func BuildProviderMirrorE(t testing.TestingT, options *Options, mirrorDir string, platforms ...string) (string, error) {
+	absMirrorDir, err := filepath.Abs(mirrorDir)
+	if err != nil {
+		return "", err
+	}
+	lockFile, err := ioutil.ReadFile(filepath.Join(options.TerraformDir, ".terraform.lock.hcl"))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write(lockFile)
+	for _, platform := range platforms {
+		hash.Write([]byte("\n" + platform))
+	}
+	// Mirrors are recorded with a marker file in the mirror, so that other test binaries don't build them again.
+	markerPath := filepath.Join(absMirrorDir, fmt.Sprintf(".terratest-%s", hex.EncodeToString(hash.Sum(nil))[:16]))
+
+	providerMirrors.Lock()
+	defer providerMirrors.Unlock()
+
+	if providerMirrors.built[markerPath] {
+		return absMirrorDir, nil
+	}
+	if err := os.MkdirAll(absMirrorDir, 0755); err != nil {
+		return "", err
+	}
+	unlock, err := lockPluginDirE(t, options, absMirrorDir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(markerPath); err != nil {
+		args := []string{"providers", "mirror"}
+		for _, platform := range platforms {
+			args = append(args, fmt.Sprintf("-platform=%s", platform))
+		}
+		if _, err := RunTerraformCommandE(t, options, append(args, absMirrorDir)...); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(markerPath, lockFile, 0644); err != nil {
+			return "", err
+		}
+	}
+	providerMirrors.built[markerPath] = true
+	return absMirrorDir, nil
+}
+
+// InitWithProviderMirror builds a filesystem mirror of the providers in the lock file of TerraformDir at the given
+// path (see BuildProviderMirror), and runs terraform init with the providers of that mirror (-plugin-dir), so that
+// init does not reach the registry. This will fail the test if there is an error.
+func InitWithProviderMirror(t testing.TestingT, options *Options, mirrorDir string) string {
+	out, err := InitWithProviderMirrorE(t, options, mirrorDir)
+	require.NoError(t, err)
+	return out
+}
+
+// InitWithProviderMirrorE builds a filesystem mirror of the providers in the lock file of TerraformDir at the given
+// path (see BuildProviderMirror), and runs terraform init with the providers of that mirror (-plugin-dir), so that
+// init does not reach the registry.
+func InitWithProviderMirrorE(t testing.TestingT, options *Options, mirrorDir string) (string, error) {
+	pluginDir, err := BuildProviderMirrorE(t, options, mirrorDir)
+	if err != nil {
+		return "", err
+	}
+	mirrorOptions, err := options.Clone()
+	if err != nil {
+		return "", err
+	}
+	mirrorOptions.PluginDir = pluginDir
+	return InitE(t, mirrorOptions)
+}
+
+// lockPluginDirE takes a lock on the given plugin cache or provider mirror, waiting for other tests (in this process
+// or another one) to release it, and returns a function that releases the lock. The lock is a file created in the
+// directory, as terraform does not lock the directory itself. The modification time of the lock file is refreshed while
+// the lock is held, so that other tests don't consider it stale however long terraform runs.
+func lockPluginDirE(t testing.TestingT, options *Options, dir string) (func(), error) {
+	lockPath := filepath.Join(dir, pluginCacheLockFileName)
+	deadline := time.Now().Add(PluginCacheLockTimeout)
+	loggedWait := false
+
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+			lockFile.Close()
+			return refreshLockFile(lockPath, PluginCacheLockTimeout/4), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > PluginCacheLockTimeout {
+			options.Logger.Logf(t, "Removing stale lock %s", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, PluginDirLocked(lockPath)
+		}
+		if !loggedWait {
+			options.Logger.Logf(t, "Waiting for another test to release the lock on %s", dir)
+			loggedWait = true
+		}
+		time.Sleep(pluginCacheLockPollInterval)
+	}
+}
+
+// refreshLockFile updates the modification time of the given lock file at the given interval until the returned
+// function is called, which removes the lock file.
+func refreshLockFile(lockPath string, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				os.Chtimes(lockPath, now, now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		os.Remove(lockPath)
+	}
+}
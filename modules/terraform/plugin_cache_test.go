@@ -0,0 +1,171 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const providersLockFile = `provider "registry.terraform.io/hashicorp/null" {
+  version     = "3.2.1"
+  constraints = "~> 3.0"
+}
+`
+
+// writeFakeLoggingBinary writes a script that echoes its arguments and records each invocation in a log file, whose
+// path is returned along with the path of the script.
+func writeFakeLoggingBinary(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "invocations.log")
+	binaryPath := filepath.Join(dir, "terraform")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %q\necho \"$@\"\n", logPath)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath, logPath
+}
+
+func TestUsePluginCache(t *testing.T) {
+	t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+
+	cacheDir := UsePluginCache(t, filepath.Join(t.TempDir(), "cache"))
+	assert.DirExists(t, cacheDir)
+	assert.Equal(t, cacheDir, os.Getenv("TF_PLUGIN_CACHE_DIR"))
+	assert.Equal(t, cacheDir, pluginCacheDir(&Options{}))
+	assert.Equal(t, "/other", pluginCacheDir(&Options{EnvVars: map[string]string{"TF_PLUGIN_CACHE_DIR": "/other"}}))
+}
+
+func TestLockPluginDirWaitsForRelease(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	options := &Options{}
+	unlock, err := lockPluginDirE(t, options, dir)
+	require.NoError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		unlockSecond, err := lockPluginDirE(t, options, dir)
+		if err == nil {
+			unlockSecond()
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("Expected the second lock to wait for the first one to be released")
+	case <-time.After(3 * pluginCacheLockPollInterval):
+	}
+	unlock()
+	<-locked
+	assert.NoFileExists(t, filepath.Join(dir, pluginCacheLockFileName))
+}
+
+func TestLockPluginDirRemovesStaleLock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, pluginCacheLockFileName)
+	require.NoError(t, ioutil.WriteFile(lockPath, []byte("12345\n"), 0644))
+	staleTime := time.Now().Add(-2 * PluginCacheLockTimeout)
+	require.NoError(t, os.Chtimes(lockPath, staleTime, staleTime))
+
+	unlock, err := lockPluginDirE(t, &Options{}, dir)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestRefreshLockFile(t *testing.T) {
+	t.Parallel()
+
+	lockPath := filepath.Join(t.TempDir(), pluginCacheLockFileName)
+	require.NoError(t, ioutil.WriteFile(lockPath, []byte("12345\n"), 0644))
+	oldTime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(lockPath, oldTime, oldTime))
+
+	release := refreshLockFile(lockPath, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	info, err := os.Stat(lockPath)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+
+	release()
+	assert.NoFileExists(t, lockPath)
+}
+
+func TestInitOnlyLocksManagedPluginCaches(t *testing.T) {
+	t.Parallel()
+
+	// The script reports whether the cache it runs with is locked.
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	script := fmt.Sprintf("#!/bin/sh\nif [ -f \"$TF_PLUGIN_CACHE_DIR/%s\" ]; then echo LOCKED; else echo UNLOCKED; fi\n", pluginCacheLockFileName)
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+
+	userCacheDir := t.TempDir()
+	options := &Options{TerraformBinary: binaryPath, TerraformDir: t.TempDir(), EnvVars: map[string]string{"TF_PLUGIN_CACHE_DIR": userCacheDir}}
+	assert.Contains(t, Init(t, options), "UNLOCKED")
+
+	managedCacheDir := t.TempDir()
+	addManagedPluginCache(managedCacheDir)
+	options.EnvVars["TF_PLUGIN_CACHE_DIR"] = managedCacheDir
+	assert.Contains(t, Init(t, options), "LOCKED")
+	assert.NotContains(t, Init(t, options), "UNLOCKED")
+	assert.NoFileExists(t, filepath.Join(managedCacheDir, pluginCacheLockFileName))
+}
+
+func TestInitWithProviderMirrorBuildsMirrorOnce(t *testing.T) {
+	t.Parallel()
+
+	binary, logPath := writeFakeLoggingBinary(t)
+	terraformDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(terraformDir, ".terraform.lock.hcl"), []byte(providersLockFile), 0644))
+	mirrorDir := filepath.Join(t.TempDir(), "mirror")
+
+	options := &Options{TerraformBinary: binary, TerraformDir: terraformDir}
+	out := InitWithProviderMirror(t, options, mirrorDir)
+	assert.Empty(t, options.PluginDir)
+	assert.Contains(t, out, "-plugin-dir="+mirrorDir)
+
+	otherOptions := &Options{TerraformBinary: binary, TerraformDir: terraformDir}
+	InitWithProviderMirror(t, otherOptions, mirrorDir)
+
+	assert.Equal(t, []string{
+		"providers mirror " + mirrorDir,
+		"init -upgrade=false -plugin-dir=" + mirrorDir,
+		"init -upgrade=false -plugin-dir=" + mirrorDir,
+	}, readInvocations(t, logPath))
+}
+
+func TestBuildProviderMirrorWithPlatforms(t *testing.T) {
+	t.Parallel()
+
+	binary, logPath := writeFakeLoggingBinary(t)
+	terraformDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(terraformDir, ".terraform.lock.hcl"), []byte(providersLockFile), 0644))
+	mirrorDir := filepath.Join(t.TempDir(), "mirror")
+
+	options := &Options{TerraformBinary: binary, TerraformDir: terraformDir}
+	BuildProviderMirror(t, options, mirrorDir, "linux_amd64", "darwin_arm64")
+
+	// Forget the mirrors built by this process, to check that the mirror is not built again by another test binary.
+	providerMirrors.Lock()
+	providerMirrors.built = map[string]bool{}
+	providerMirrors.Unlock()
+	BuildProviderMirror(t, options, mirrorDir, "linux_amd64", "darwin_arm64")
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("providers mirror -platform=linux_amd64 -platform=darwin_arm64 %s", mirrorDir),
+	}, readInvocations(t, logPath))
+}
+
+func TestBuildProviderMirrorWithoutLockFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := BuildProviderMirrorE(t, &Options{TerraformDir: t.TempDir()}, t.TempDir())
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,386 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SensitiveValuePlaceholder is what sensitive values are replaced with when terraform json output is logged. It
+// matches what terraform itself prints in its human readable output.
+const SensitiveValuePlaceholder = "(sensitive value)"
+
+// redactionFailedMessage is logged in place of json output that could not be parsed, as we can't tell which parts of
+// it are sensitive.
+const redactionFailedMessage = "(output not logged as it could not be parsed to redact sensitive values)"
+
+// IsOutputSensitive returns true if the given terraform output is marked as sensitive. This will fail the test if the
+// output does not exist or there is an error running terraform output.
+func IsOutputSensitive(t testing.TestingT, options *Options, key string) bool {
+	sensitive, err := IsOutputSensitiveE(t, options, key)
+	require.NoError(t, err)
+	return sensitive
+}
+
+// IsOutputSensitiveE returns true if the given terraform output is marked as sensitive.
+func IsOutputSensitiveE(t testing.TestingT, options *Options, key string) (bool, error) {
+	out, err := OutputJsonE(t, options, "")
+	if err != nil {
+		return false, err
+	}
+
+	outputs := map[string]*tfjson.StateOutput{}
+	if err := json.Unmarshal([]byte(out), &outputs); err != nil {
+		return false, err
+	}
+
+	output, hasKey := outputs[key]
+	if !hasKey {
+		return false, OutputKeyNotFound(key)
+	}
+	return output.Sensitive, nil
+}
+
+// AssertOutputSensitive checks that the given terraform output is marked as sensitive, failing the test if it is not.
+func AssertOutputSensitive(t testing.TestingT, options *Options, key string) {
+	sensitive, err := IsOutputSensitiveE(t, options, key)
+	if assert.NoError(t, err) {
+		assert.Truef(t, sensitive, "Expected output %s to be marked as sensitive", key)
+	}
+}
+
+// RequireOutputSensitive checks that the given terraform output is marked as sensitive, failing and halting the test
+// if it is not.
+func RequireOutputSensitive(t testing.TestingT, options *Options, key string) {
+	require.Truef(t, IsOutputSensitive(t, options, key), "Expected output %s to be marked as sensitive", key)
+}
+
+// IsPlannedAttributeSensitiveE returns true if the planned value of the attribute at the given path of the resource at
+// the given address is marked as sensitive, either directly or because a parent attribute is sensitive. See
+// GetPlannedAttributeE for the path syntax.
+func IsPlannedAttributeSensitiveE(plan *PlanStruct, address string, path string) (bool, error) {
+	resourceChange, hasKey := plan.ResourceChangesMap[address]
+	if !hasKey || resourceChange.Change == nil {
+		return false, ResourceNotFoundInPlan(address)
+	}
+
+	steps, err := parseAttributePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	// after_sensitive mirrors the structure of the after value, but only contains the sensitive parts, which are set
+	// to true.
+	mask := resourceChange.Change.AfterSensitive
+	for _, step := range steps {
+		if mask == true {
+			return true, nil
+		}
+		switch typedStep := step.(type) {
+		case string:
+			asMap, isMap := mask.(map[string]interface{})
+			if !isMap {
+				return false, nil
+			}
+			mask = asMap[typedStep]
+		case int:
+			asList, isList := mask.([]interface{})
+			if !isList || typedStep < 0 || typedStep >= len(asList) {
+				return false, nil
+			}
+			mask = asList[typedStep]
+		}
+	}
+	return mask == true, nil
+}
+
+// AssertPlannedAttributeSensitive checks that the planned value of the attribute at the given path of the resource at
+// the given address is marked as sensitive, failing the test if it is not. The address may be a pattern (see
+// MatchResourceAddress), in which case every matching resource is checked.
+func AssertPlannedAttributeSensitive(t testing.TestingT, plan *PlanStruct, address string, path string) {
+	for _, matchedAddress := range resolveAddresses(sortedResourceChangeAddresses(plan), address) {
+		sensitive, err := IsPlannedAttributeSensitiveE(plan, matchedAddress, path)
+		if assert.NoError(t, err) {
+			assert.Truef(t, sensitive, "Expected attribute %s of resource %s to be marked as sensitive", path, matchedAddress)
+		}
+	}
+}
+
+// RequirePlannedAttributeSensitive checks that the planned value of the attribute at the given path of the resource at
+// the given address is marked as sensitive, failing and halting the test if it is not. The address may be a pattern
+// (see MatchResourceAddress), in which case every matching resource is checked.
+func RequirePlannedAttributeSensitive(t testing.TestingT, plan *PlanStruct, address string, path string) {
+	for _, matchedAddress := range resolveAddresses(sortedResourceChangeAddresses(plan), address) {
+		sensitive, err := IsPlannedAttributeSensitiveE(plan, matchedAddress, path)
+		require.NoError(t, err)
+		require.Truef(t, sensitive, "Expected attribute %s of resource %s to be marked as sensitive", path, matchedAddress)
+	}
+}
+
+// IsPlannedOutputSensitiveE returns true if the planned value of the given output is marked as sensitive.
+func IsPlannedOutputSensitiveE(plan *PlanStruct, key string) (bool, error) {
+	change, hasKey := plan.RawPlan.OutputChanges[key]
+	if !hasKey || change == nil {
+		return false, OutputKeyNotFound(key)
+	}
+	return change.AfterSensitive == true, nil
+}
+
+// AssertPlannedOutputSensitive checks that the planned value of the given output is marked as sensitive, failing the
+// test if it is not.
+func AssertPlannedOutputSensitive(t testing.TestingT, plan *PlanStruct, key string) {
+	sensitive, err := IsPlannedOutputSensitiveE(plan, key)
+	if assert.NoError(t, err) {
+		assert.Truef(t, sensitive, "Expected planned output %s to be marked as sensitive", key)
+	}
+}
+
+// RequirePlannedOutputSensitive checks that the planned value of the given output is marked as sensitive, failing and
+// halting the test if it is not.
+func RequirePlannedOutputSensitive(t testing.TestingT, plan *PlanStruct, key string) {
+	sensitive, err := IsPlannedOutputSensitiveE(plan, key)
+	require.NoError(t, err)
+	require.Truef(t, sensitive, "Expected planned output %s to be marked as sensitive", key)
+}
+
+// redactOutputsJson takes the json output of `terraform output -json` and returns it with the values of all the
+// sensitive outputs replaced by SensitiveValuePlaceholder, so that it can be logged.
+func redactOutputsJson(jsonStr string) string {
+	outputs := map[string]map[string]interface{}{}
+	if err := json.Unmarshal([]byte(jsonStr), &outputs); err != nil {
+		return redactionFailedMessage
+	}
+	for _, output := range outputs {
+		if output["sensitive"] == true {
+			output["value"] = SensitiveValuePlaceholder
+		}
+	}
+	return marshalRedactedJson(outputs, true)
+}
+
+// redactShowJson takes the json output of `terraform show -json`, for either a plan or a state, and returns it with all
+// the values that terraform marked as sensitive replaced by SensitiveValuePlaceholder, so that it can be logged.
+func redactShowJson(jsonStr string) string {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(jsonStr), &doc); err != nil {
+		return redactionFailedMessage
+	}
+
+	// State representation
+	redactStateValues(doc["values"])
+
+	// Plan representation
+	redactStateValues(doc["planned_values"])
+	if priorState, isMap := doc["prior_state"].(map[string]interface{}); isMap {
+		redactStateValues(priorState["values"])
+	}
+	for _, key := range []string{"resource_changes", "resource_drift"} {
+		resourceChanges, _ := doc[key].([]interface{})
+		for _, resourceChange := range resourceChanges {
+			if asMap, isMap := resourceChange.(map[string]interface{}); isMap {
+				redactChange(asMap["change"])
+			}
+		}
+	}
+	outputChanges, _ := doc["output_changes"].(map[string]interface{})
+	for _, change := range outputChanges {
+		redactChange(change)
+	}
+	redactPlanVariables(doc)
+
+	return marshalRedactedJson(doc, false)
+}
+
+// redactStateValues redacts the sensitive outputs and resource attributes in the given state values representation,
+// which is used for the values of a state, and the planned values and prior state of a plan.
+func redactStateValues(values interface{}) {
+	asMap, isMap := values.(map[string]interface{})
+	if !isMap {
+		return
+	}
+
+	outputs, _ := asMap["outputs"].(map[string]interface{})
+	for _, output := range outputs {
+		if outputMap, isMap := output.(map[string]interface{}); isMap && outputMap["sensitive"] == true {
+			outputMap["value"] = SensitiveValuePlaceholder
+		}
+	}
+	redactStateModule(asMap["root_module"])
+}
+
+// redactStateModule recursively redacts the sensitive attributes of the resources in the given module and its child
+// modules.
+func redactStateModule(module interface{}) {
+	asMap, isMap := module.(map[string]interface{})
+	if !isMap {
+		return
+	}
+
+	resources, _ := asMap["resources"].([]interface{})
+	for _, resource := range resources {
+		if resourceMap, isMap := resource.(map[string]interface{}); isMap {
+			resourceMap["values"] = redactValue(resourceMap["values"], resourceMap["sensitive_values"])
+		}
+	}
+
+	childModules, _ := asMap["child_modules"].([]interface{})
+	for _, child := range childModules {
+		redactStateModule(child)
+	}
+}
+
+// redactChange redacts the sensitive parts of the before and after values of the given change representation.
+func redactChange(change interface{}) {
+	asMap, isMap := change.(map[string]interface{})
+	if !isMap {
+		return
+	}
+	asMap["before"] = redactValue(asMap["before"], asMap["before_sensitive"])
+	asMap["after"] = redactValue(asMap["after"], asMap["after_sensitive"])
+}
+
+// redactPlanVariables redacts the values of the input variables of a plan that are declared as sensitive in the root
+// module configuration.
+func redactPlanVariables(doc map[string]interface{}) {
+	variables, _ := doc["variables"].(map[string]interface{})
+	configuration, _ := doc["configuration"].(map[string]interface{})
+	rootModule, _ := configuration["root_module"].(map[string]interface{})
+	variableConfigs, _ := rootModule["variables"].(map[string]interface{})
+	for name, variableConfig := range variableConfigs {
+		configMap, isMap := variableConfig.(map[string]interface{})
+		if !isMap || configMap["sensitive"] != true {
+			continue
+		}
+		if variable, isMap := variables[name].(map[string]interface{}); isMap {
+			variable["value"] = SensitiveValuePlaceholder
+		}
+	}
+}
+
+// redactValue returns the given value with all the parts that are set to true in the given sensitivity mask replaced
+// by SensitiveValuePlaceholder. The mask has the same structure as the value (this is how terraform represents
+// sensitivity in its json output, e.g., in after_sensitive), but only contains the sensitive parts.
+func redactValue(value interface{}, mask interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if mask == true {
+		return SensitiveValuePlaceholder
+	}
+
+	switch typedMask := mask.(type) {
+	case map[string]interface{}:
+		asMap, isMap := value.(map[string]interface{})
+		if !isMap {
+			return value
+		}
+		out := make(map[string]interface{}, len(asMap))
+		for key, nested := range asMap {
+			out[key] = redactValue(nested, typedMask[key])
+		}
+		return out
+	case []interface{}:
+		asList, isList := value.([]interface{})
+		if !isList {
+			return value
+		}
+		out := make([]interface{}, len(asList))
+		for i, nested := range asList {
+			if i < len(typedMask) {
+				out[i] = redactValue(nested, typedMask[i])
+			} else {
+				out[i] = nested
+			}
+		}
+		return out
+	}
+	return value
+}
+
+// marshalRedactedJson renders redacted json output for logging, indenting it if requested to match the formatting of
+// the original terraform output.
+func marshalRedactedJson(value interface{}, indent bool) string {
+	var out []byte
+	var err error
+	if indent {
+		out, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		out, err = json.Marshal(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s: %v", redactionFailedMessage, err)
+	}
+	return string(out)
+}
+
+// redactStateFileJson takes the raw state file returned by `terraform state pull` and returns it with the values of
+// all the sensitive outputs and resource attributes replaced by SensitiveValuePlaceholder, so that it can be logged.
+func redactStateFileJson(jsonStr string) string {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(jsonStr), &doc); err != nil {
+		return redactionFailedMessage
+	}
+
+	outputs, _ := doc["outputs"].(map[string]interface{})
+	for _, output := range outputs {
+		if outputMap, isMap := output.(map[string]interface{}); isMap && outputMap["sensitive"] == true {
+			outputMap["value"] = SensitiveValuePlaceholder
+		}
+	}
+
+	resources, _ := doc["resources"].([]interface{})
+	for _, resource := range resources {
+		resourceMap, _ := resource.(map[string]interface{})
+		instances, _ := resourceMap["instances"].([]interface{})
+		for _, instance := range instances {
+			instanceMap, isMap := instance.(map[string]interface{})
+			if !isMap {
+				continue
+			}
+			paths, _ := instanceMap["sensitive_attributes"].([]interface{})
+			for _, path := range paths {
+				steps, _ := path.([]interface{})
+				instanceMap["attributes"] = redactStatePath(instanceMap["attributes"], steps)
+			}
+		}
+	}
+
+	return marshalRedactedJson(doc, true)
+}
+
+// redactStatePath returns the given value with the part at the given path replaced by SensitiveValuePlaceholder. The
+// path is in the format terraform uses for sensitive_attributes in the state file: a list of steps, each of which is
+// either {"type": "get_attr", "value": "name"} or {"type": "index", "value": {"value": key, "type": "number"}}.
+func redactStatePath(value interface{}, steps []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if len(steps) == 0 {
+		return SensitiveValuePlaceholder
+	}
+
+	step, _ := steps[0].(map[string]interface{})
+	key := step["value"]
+	if step["type"] == "index" {
+		indexMap, _ := key.(map[string]interface{})
+		key = indexMap["value"]
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if name, isString := key.(string); isString {
+			if nested, hasKey := typedValue[name]; hasKey {
+				typedValue[name] = redactStatePath(nested, steps[1:])
+			}
+		}
+	case []interface{}:
+		if index, isNumber := key.(float64); isNumber && int(index) >= 0 && int(index) < len(typedValue) {
+			typedValue[int(index)] = redactStatePath(typedValue[int(index)], steps[1:])
+		}
+	}
+	return value
+}
@@ -0,0 +1,214 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	tt "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const planJsonWithSensitiveValues = `
+{
+  "format_version": "1.0",
+  "variables": {"password": {"value": "hunter2"}, "username": {"value": "admin"}},
+  "planned_values": {
+    "outputs": {"password": {"sensitive": true, "value": "hunter2"}},
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_db_instance.main",
+          "mode": "managed",
+          "type": "aws_db_instance",
+          "name": "main",
+          "schema_version": 0,
+          "values": {"username": "admin", "password": "hunter2", "tags": {"Name": "db", "Secret": "s3cr3t"}},
+          "sensitive_values": {"password": true, "tags": {"Secret": true}}
+        }
+      ]
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "change": {
+        "actions": ["update"],
+        "before": {"username": "admin", "password": "hunter1", "tags": {"Name": "db", "Secret": "s3cr3t"}},
+        "after": {"username": "admin", "password": "hunter2", "tags": {"Name": "db", "Secret": "s3cr3t"}},
+        "after_unknown": {},
+        "before_sensitive": {"password": true, "tags": {"Secret": true}},
+        "after_sensitive": {"password": true, "tags": {"Secret": true}}
+      }
+    }
+  ],
+  "output_changes": {
+    "password": {"actions": ["create"], "before": null, "after": "hunter2", "after_unknown": false, "before_sensitive": false, "after_sensitive": true},
+    "username": {"actions": ["create"], "before": null, "after": "admin", "after_unknown": false, "before_sensitive": false, "after_sensitive": false}
+  },
+  "configuration": {
+    "root_module": {
+      "variables": {"password": {"sensitive": true}, "username": {}}
+    }
+  }
+}
+`
+
+func TestRedactShowJson(t *testing.T) {
+	t.Parallel()
+
+	redacted := redactShowJson(planJsonWithSensitiveValues)
+	for _, secret := range []string{"hunter1", "hunter2", "s3cr3t"} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Contains(t, redacted, SensitiveValuePlaceholder)
+	assert.Contains(t, redacted, `"username":"admin"`)
+	assert.Contains(t, redacted, `"Name":"db"`)
+
+	assert.Equal(t, redactionFailedMessage, redactShowJson("not json"))
+}
+
+func TestRedactOutputsJson(t *testing.T) {
+	t.Parallel()
+
+	redacted := redactOutputsJson(`{"password": {"sensitive": true, "type": "string", "value": "hunter2"}, "username": {"sensitive": false, "type": "string", "value": "admin"}}`)
+	assert.NotContains(t, redacted, "hunter2")
+	assert.Contains(t, redacted, "admin")
+}
+
+func TestPlannedSensitivity(t *testing.T) {
+	t.Parallel()
+
+	plan, err := parsePlanJson(planJsonWithSensitiveValues)
+	require.NoError(t, err)
+
+	RequirePlannedAttributeSensitive(t, plan, "aws_db_instance.main", "password")
+	RequirePlannedAttributeSensitive(t, plan, "aws_db_instance.main", `tags["Secret"]`)
+	RequirePlannedOutputSensitive(t, plan, "password")
+
+	for _, path := range []string{"username", "tags.Name"} {
+		sensitive, err := IsPlannedAttributeSensitiveE(plan, "aws_db_instance.main", path)
+		require.NoError(t, err)
+		assert.False(t, sensitive, path)
+	}
+
+	sensitive, err := IsPlannedOutputSensitiveE(plan, "username")
+	require.NoError(t, err)
+	assert.False(t, sensitive)
+
+	_, err = IsPlannedOutputSensitiveE(plan, "missing")
+	assert.IsType(t, OutputKeyNotFound(""), err)
+}
+
+// capturingLogger records every line that is logged, so that tests can check what would have been printed.
+type capturingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) Logf(_ tt.TestingT, format string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSensitiveValuesRedactedFromLogs(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-output-sensitive", t.Name())
+	require.NoError(t, err)
+
+	password := random.UniqueId()
+	logs := &capturingLogger{}
+	options := &Options{
+		TerraformDir: testFolder,
+		PlanFilePath: filepath.Join(testFolder, "plan.out"),
+		Vars:         map[string]interface{}{"password": password},
+		Logger:       logger.New(logs),
+	}
+	defer Destroy(t, options)
+
+	plan := InitAndPlanAndShowWithStruct(t, options)
+	RequirePlannedAttributeSensitive(t, plan, "null_resource.test", "triggers.password")
+	RequirePlannedOutputSensitive(t, plan, "password")
+
+	options.PlanFilePath = ""
+	Apply(t, options)
+	RequireOutputSensitive(t, options, "password")
+	assert.False(t, IsOutputSensitive(t, options, "username"))
+	assert.Equal(t, password, Output(t, options, "password"))
+
+	// The password is passed in as a -var flag, so it shows up in the logged command line. Everything past that must
+	// not contain it.
+	for _, line := range logs.lines {
+		if strings.HasPrefix(line, "Running command") {
+			continue
+		}
+		assert.NotContains(t, line, password)
+	}
+}
+
+// fakeOutputScript is a fake terraform binary that prints the given outputs for `output -json`, and fails like
+// terraform does for `output -json <missing key>`, or with the error in FAKE_STATE_ERROR if it is set.
+const fakeOutputScript = `#!/bin/sh
+if [ -n "$4" ]; then echo "The output variable requested could not be found" >&2; exit 1; fi
+if [ -n "$FAKE_STATE_ERROR" ]; then echo "$FAKE_STATE_ERROR" >&2; exit 1; fi
+cat <<'JSON'
+{
+  "password": {
+    "sensitive": true,
+    "type": "string",
+    "value": "hunter2"
+  },
+  "tags": {
+    "sensitive": false,
+    "type": ["object", {"Name": "string"}],
+    "value": {
+      "Name": "test"
+    }
+  }
+}
+JSON
+`
+
+func TestOutputJsonWithKeyFromFakeBinary(t *testing.T) {
+	t.Parallel()
+
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(fakeOutputScript), 0755))
+	logs := &capturingLogger{}
+	options := &Options{TerraformDir: t.TempDir(), TerraformBinary: binaryPath, Logger: logger.New(logs)}
+
+	out, err := OutputJsonE(t, options, "tags")
+	require.NoError(t, err)
+	assert.Equal(t, `{"Name":"test"}`, out)
+
+	out, err = OutputJsonE(t, options, "password")
+	require.NoError(t, err)
+	assert.Equal(t, `"hunter2"`, out)
+
+	_, err = OutputJsonE(t, options, "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "The output variable requested could not be found")
+	assert.Contains(t, logs.lines, "The output variable requested could not be found")
+
+	for _, line := range logs.lines {
+		assert.NotContains(t, line, "hunter2")
+	}
+
+	// The stderr of a failed command is logged.
+	options.EnvVars = map[string]string{"FAKE_STATE_ERROR": "Failed to load state"}
+	_, err = OutputJsonE(t, options, "")
+	require.Error(t, err)
+	assert.Contains(t, logs.lines, "Failed to load state")
+}
@@ -16,7 +16,8 @@ func Show(t testing.TestingT, options *Options) string {
 
 // ShowE calls terraform show in json mode with the given options and returns stdout from the command. If
 // PlanFilePath is set on the options, this will show the plan file. Otherwise, this will show the current state of the
-// terraform module at options.TerraformDir.
+// terraform module at options.TerraformDir. Values that terraform marks as sensitive are redacted in the logs, but not
+// in the returned json.
 func ShowE(t testing.TestingT, options *Options) (string, error) {
 	// We manually construct the args here instead of using `FormatArgs`, because show only accepts a limited set of
 	// args.
@@ -26,7 +27,7 @@ func ShowE(t testing.TestingT, options *Options) (string, error) {
 	if options.PlanFilePath != "" {
 		args = append(args, options.PlanFilePath)
 	}
-	return RunTerraformCommandAndGetStdoutE(t, options, args...)
+	return runTerraformCommandAndGetRedactedStdoutE(t, options, redactShowJson, args...)
 }
 
 func ShowWithStruct(t testing.TestingT, options *Options) *PlanStruct {
@@ -46,3 +47,37 @@ func ShowWithStructE(t testing.TestingT, options *Options) (*PlanStruct, error)
 	}
 	return planStruct, nil
 }
+
+// ShowState calls terraform show in json mode against the current state of the terraform module at
+// options.TerraformDir and returns stdout from the command. Unlike Show, this ignores PlanFilePath on the options. This
+// will fail the test if there is an error in the command.
+func ShowState(t testing.TestingT, options *Options) string {
+	out, err := ShowStateE(t, options)
+	require.NoError(t, err)
+	return out
+}
+
+// ShowStateE calls terraform show in json mode against the current state of the terraform module at
+// options.TerraformDir and returns stdout from the command. Unlike ShowE, this ignores PlanFilePath on the options.
+func ShowStateE(t testing.TestingT, options *Options) (string, error) {
+	return runTerraformCommandAndGetRedactedStdoutE(t, options, redactShowJson, "show", "-no-color", "-json")
+}
+
+// ShowStateWithStruct calls terraform show in json mode against the current state of the terraform module at
+// options.TerraformDir, and parses the json result into a go struct. This will fail the test if there is an error in
+// the command.
+func ShowStateWithStruct(t testing.TestingT, options *Options) *StateStruct {
+	state, err := ShowStateWithStructE(t, options)
+	require.NoError(t, err)
+	return state
+}
+
+// ShowStateWithStructE calls terraform show in json mode against the current state of the terraform module at
+// options.TerraformDir, and parses the json result into a go struct.
+func ShowStateWithStructE(t testing.TestingT, options *Options) (*StateStruct, error) {
+	json, err := ShowStateE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return parseStateJson(json)
+}
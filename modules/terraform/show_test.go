@@ -68,3 +68,24 @@ func TestShowWithStructInlinePlan(t *testing.T) {
 	plan := ShowWithStruct(t, showOptions)
 	require.Contains(t, plan.ResourcePlannedValuesMap, "null_resource.test[0]")
 }
+
+func TestShowStateWithStruct(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-basic-configuration", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars: map[string]interface{}{
+			"cnt": 2,
+		},
+	}
+	defer Destroy(t, options)
+	InitAndApply(t, options)
+
+	state := ShowStateWithStruct(t, options)
+	RequireResourceStateMapKeyExists(t, state, "null_resource.test[0]")
+	RequireResourceStateMapKeyExists(t, state, "null_resource.test[1]")
+	require.NotEmpty(t, state.ResourceStateMap["null_resource.test[0]"].AttributeValues["id"])
+}
@@ -0,0 +1,144 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// StateFile is a Go Struct representation of the raw terraform state file, as returned by `terraform state pull`.
+// Unlike StateStruct, which is based on the output of `terraform show`, this exposes the internal bookkeeping of the
+// state (serial, lineage, instance status, dependencies, etc), which is useful when testing state migrations.
+type StateFile struct {
+	Version          int                        `json:"version"`
+	TerraformVersion string                     `json:"terraform_version"`
+	Serial           int64                      `json:"serial"`
+	Lineage          string                     `json:"lineage"`
+	Outputs          map[string]StateFileOutput `json:"outputs"`
+	Resources        []StateFileResource        `json:"resources"`
+}
+
+// StateFileOutput is the representation of an output in the raw terraform state file.
+type StateFileOutput struct {
+	Value     interface{}     `json:"value"`
+	Type      json.RawMessage `json:"type"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+}
+
+// StateFileResource is the representation of a resource, with all its instances, in the raw terraform state file.
+type StateFileResource struct {
+	Module    string                      `json:"module,omitempty"`
+	Mode      string                      `json:"mode"`
+	Type      string                      `json:"type"`
+	Name      string                      `json:"name"`
+	Each      string                      `json:"each,omitempty"`
+	Provider  string                      `json:"provider"`
+	Instances []StateFileResourceInstance `json:"instances"`
+}
+
+// StateFileResourceInstance is the representation of a single instance of a resource in the raw terraform state file.
+type StateFileResourceInstance struct {
+	// The count index (a number) or for_each key (a string) of the instance, or nil if the resource uses neither.
+	IndexKey            interface{}            `json:"index_key,omitempty"`
+	Status              string                 `json:"status,omitempty"`
+	Deposed             string                 `json:"deposed,omitempty"`
+	SchemaVersion       uint64                 `json:"schema_version"`
+	Attributes          map[string]interface{} `json:"attributes,omitempty"`
+	SensitiveAttributes json.RawMessage        `json:"sensitive_attributes,omitempty"`
+	Private             string                 `json:"private,omitempty"`
+	Dependencies        []string               `json:"dependencies,omitempty"`
+	CreateBeforeDestroy bool                   `json:"create_before_destroy,omitempty"`
+}
+
+// StateList runs terraform state list with the given options and returns the addresses of the resources in the state.
+// If addresses are provided, only the resources matching those addresses are returned. This will fail the test if
+// there is an error in the command.
+func StateList(t testing.TestingT, options *Options, addresses ...string) []string {
+	out, err := StateListE(t, options, addresses...)
+	require.NoError(t, err)
+	return out
+}
+
+// StateListE runs terraform state list with the given options and returns the addresses of the resources in the
+// state. If addresses are provided, only the resources matching those addresses are returned.
+func StateListE(t testing.TestingT, options *Options, addresses ...string) ([]string, error) {
+	args := append([]string{"state", "list"}, addresses...)
+	out, err := RunTerraformCommandAndGetStdoutE(t, options, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			resources = append(resources, line)
+		}
+	}
+	return resources, nil
+}
+
+// StateMv runs terraform state mv with the given options to move the resource (or module) at the source address to the
+// destination address, and returns stdout/stderr. This will fail the test if there is an error in the command.
+func StateMv(t testing.TestingT, options *Options, source string, destination string) string {
+	out, err := StateMvE(t, options, source, destination)
+	require.NoError(t, err)
+	return out
+}
+
+// StateMvE runs terraform state mv with the given options to move the resource (or module) at the source address to
+// the destination address, and returns stdout/stderr.
+func StateMvE(t testing.TestingT, options *Options, source string, destination string) (string, error) {
+	return RunTerraformCommandE(t, options, formatStateArgs(options, []string{"state", "mv"}, source, destination)...)
+}
+
+// StateRm runs terraform state rm with the given options to remove the resources at the given addresses from the
+// state (without destroying them), and returns stdout/stderr. This will fail the test if there is an error in the
+// command.
+func StateRm(t testing.TestingT, options *Options, addresses ...string) string {
+	out, err := StateRmE(t, options, addresses...)
+	require.NoError(t, err)
+	return out
+}
+
+// StateRmE runs terraform state rm with the given options to remove the resources at the given addresses from the
+// state (without destroying them), and returns stdout/stderr.
+func StateRmE(t testing.TestingT, options *Options, addresses ...string) (string, error) {
+	return RunTerraformCommandE(t, options, formatStateArgs(options, []string{"state", "rm"}, addresses...)...)
+}
+
+// StatePull runs terraform state pull with the given options and parses the raw state into a go struct. This will
+// fail the test if there is an error in the command.
+func StatePull(t testing.TestingT, options *Options) *StateFile {
+	state, err := StatePullE(t, options)
+	require.NoError(t, err)
+	return state
+}
+
+// StatePullE runs terraform state pull with the given options and parses the raw state into a go struct. Sensitive
+// values are redacted in the logs, but not in the returned struct.
+func StatePullE(t testing.TestingT, options *Options) (*StateFile, error) {
+	out, err := runTerraformCommandAndGetRedactedStdoutE(t, options, redactStateFileJson, "state", "pull")
+	if err != nil {
+		return nil, err
+	}
+
+	state := &StateFile{}
+	if err := json.Unmarshal([]byte(out), state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// formatStateArgs formats the args for commands that operate directly on the state (e.g., state mv, taint). Unlike
+// FormatArgs, this does not include vars or targets, which these commands don't accept. The given positional args
+// (e.g., resource addresses) are added last.
+func formatStateArgs(options *Options, command []string, positional ...string) []string {
+	args := append([]string{}, command...)
+	if options.NoColor {
+		args = append(args, "-no-color")
+	}
+	args = append(args, FormatTerraformLockAsArgs(options.Lock, options.LockTimeout)...)
+	return append(args, positional...)
+}
@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"encoding/json"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StateStruct is a Go Struct representation of the state object returned from Terraform (after running `terraform
+// show` without a plan file). Unlike the raw state representation returned by terraform-json, this struct provides a
+// map that maps the resource addresses to the resources in state to make it easier to navigate the raw state struct.
+type StateStruct struct {
+	// The raw representation of the state. See
+	// https://www.terraform.io/docs/internals/json-format.html#state-representation for details on the structure of the
+	// state output.
+	RawState tfjson.State
+
+	// A map that maps full resource addresses (e.g., module.foo.null_resource.test) to the values of that resource as
+	// recorded in the state.
+	ResourceStateMap map[string]*tfjson.StateResource
+}
+
+// parseStateJson takes in the json string representation of the terraform state and returns a go struct
+// representation for easy introspection.
+func parseStateJson(jsonStr string) (*StateStruct, error) {
+	state := &StateStruct{}
+
+	if err := json.Unmarshal([]byte(jsonStr), &state.RawState); err != nil {
+		return nil, err
+	}
+
+	state.ResourceStateMap = parseStateValues(state)
+	return state, nil
+}
+
+// parseStateValues takes a state and walks through the values to return a map that maps the full resource addresses
+// to the resources in state. If the state is empty (e.g., nothing has been applied yet), this returns an empty map
+// instead of erroring.
+func parseStateValues(state *StateStruct) map[string]*tfjson.StateResource {
+	values := state.RawState.Values
+	if values == nil {
+		// No values, so return empty map.
+		return map[string]*tfjson.StateResource{}
+	}
+
+	rootModule := values.RootModule
+	if rootModule == nil {
+		// No module resources, so return empty map.
+		return map[string]*tfjson.StateResource{}
+	}
+	// The state uses the same module representation as the planned values, so we can reuse the same walking logic.
+	return parseModulePlannedValues(rootModule)
+}
+
+// AssertResourceStateMapKeyExists checks if the given key exists in the map, failing the test if it does not.
+func AssertResourceStateMapKeyExists(t testing.TestingT, state *StateStruct, keyQuery string) {
+	_, hasKey := state.ResourceStateMap[keyQuery]
+	assert.Truef(t, hasKey, "Given resource state map does not have key %s", keyQuery)
+}
+
+// RequireResourceStateMapKeyExists checks if the given key exists in the map, failing and halting the test if it does
+// not.
+func RequireResourceStateMapKeyExists(t testing.TestingT, state *StateStruct, keyQuery string) {
+	_, hasKey := state.ResourceStateMap[keyQuery]
+	require.Truef(t, hasKey, "Given resource state map does not have key %s", keyQuery)
+}
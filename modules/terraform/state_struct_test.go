@@ -0,0 +1,91 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stateJsonWithModules = `
+{
+  "format_version": "1.0",
+  "terraform_version": "1.3.7",
+  "values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "null_resource.foo[0]",
+          "mode": "managed",
+          "type": "null_resource",
+          "name": "foo",
+          "index": 0,
+          "provider_name": "registry.terraform.io/hashicorp/null",
+          "schema_version": 0,
+          "values": {"id": "1234", "triggers": {"name": "foo"}}
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.bar",
+          "resources": [
+            {
+              "address": "module.bar.null_resource.bar",
+              "mode": "managed",
+              "type": "null_resource",
+              "name": "bar",
+              "provider_name": "registry.terraform.io/hashicorp/null",
+              "schema_version": 0,
+              "values": {"id": "5678", "triggers": null}
+            }
+          ],
+          "child_modules": [
+            {
+              "address": "module.bar.module.baz",
+              "resources": [
+                {
+                  "address": "module.bar.module.baz.null_resource.baz",
+                  "mode": "managed",
+                  "type": "null_resource",
+                  "name": "baz",
+                  "provider_name": "registry.terraform.io/hashicorp/null",
+                  "schema_version": 0,
+                  "values": {"id": "9012"}
+                }
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  }
+}
+`
+
+func TestResourceStateMapWithModules(t *testing.T) {
+	t.Parallel()
+
+	state, err := parseStateJson(stateJsonWithModules)
+	require.NoError(t, err)
+
+	query := []string{
+		"null_resource.foo[0]",
+		"module.bar.null_resource.bar",
+		"module.bar.module.baz.null_resource.baz",
+	}
+	for _, key := range query {
+		RequireResourceStateMapKeyExists(t, state, key)
+		resource := state.ResourceStateMap[key]
+		assert.Equal(t, resource.Address, key)
+	}
+	assert.Len(t, state.ResourceStateMap, len(query))
+	assert.Equal(t, "1234", state.ResourceStateMap["null_resource.foo[0]"].AttributeValues["id"])
+}
+
+func TestResourceStateMapWithEmptyState(t *testing.T) {
+	t.Parallel()
+
+	state, err := parseStateJson(`{"format_version": "1.0"}`)
+	require.NoError(t, err)
+	assert.Empty(t, state.ResourceStateMap)
+}
@@ -0,0 +1,112 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatStateArgs(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{NoColor: true, Lock: true, LockTimeout: "5s", Vars: map[string]interface{}{"foo": "bar"}, Targets: []string{"null_resource.foo"}}
+	args := formatStateArgs(options, []string{"state", "mv"}, "null_resource.a", "null_resource.b")
+	assert.Equal(t, []string{"state", "mv", "-no-color", "-lock=true", "-lock-timeout=5s", "null_resource.a", "null_resource.b"}, args)
+}
+
+func TestRedactStateFileJson(t *testing.T) {
+	t.Parallel()
+
+	stateJson := `{
+  "version": 4,
+  "serial": 3,
+  "lineage": "abc",
+  "outputs": {"password": {"value": "hunter2", "type": "string", "sensitive": true}},
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {
+          "schema_version": 0,
+          "attributes": {"username": "admin", "password": "hunter1", "tags": {"Secret": "s3cr3t"}, "list": ["a", "b"]},
+          "sensitive_attributes": [
+            [{"type": "get_attr", "value": "password"}],
+            [{"type": "get_attr", "value": "tags"}, {"type": "index", "value": {"value": "Secret", "type": "string"}}],
+            [{"type": "get_attr", "value": "list"}, {"type": "index", "value": {"value": 1, "type": "number"}}]
+          ]
+        }
+      ]
+    }
+  ]
+}`
+	redacted := redactStateFileJson(stateJson)
+	for _, secret := range []string{"hunter1", "hunter2", "s3cr3t", `"b"`} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Contains(t, redacted, "admin")
+	assert.Contains(t, redacted, `"a"`)
+}
+
+func TestStateCommands(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-basic-configuration", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars: map[string]interface{}{
+			"cnt": 3,
+		},
+	}
+	defer Destroy(t, options)
+	InitAndApply(t, options)
+
+	assert.Equal(t, []string{"null_resource.test[0]", "null_resource.test[1]", "null_resource.test[2]"}, StateList(t, options))
+	assert.Equal(t, []string{"null_resource.test[1]"}, StateList(t, options, "null_resource.test[1]"))
+
+	state := StatePull(t, options)
+	require.Len(t, state.Resources, 1)
+	assert.Equal(t, "null_resource", state.Resources[0].Type)
+	assert.Len(t, state.Resources[0].Instances, 3)
+	serial := state.Serial
+
+	Taint(t, options, "null_resource.test[0]")
+	assert.Equal(t, "tainted", StatePull(t, options).Resources[0].Instances[0].Status)
+	Untaint(t, options, "null_resource.test[0]")
+	assert.Empty(t, StatePull(t, options).Resources[0].Instances[0].Status)
+
+	StateMv(t, options, "null_resource.test[2]", "null_resource.moved")
+	StateRm(t, options, "null_resource.test[1]")
+	assert.Equal(t, []string{"null_resource.moved", "null_resource.test[0]"}, StateList(t, options))
+	assert.Greater(t, StatePull(t, options).Serial, serial)
+
+	// Moving the resource back should make the plan a no-op for it again.
+	StateMv(t, options, "null_resource.moved", "null_resource.test[2]")
+	plan := InitAndPlanAndShowWithStructNoLogTempPlanFile(t, options)
+	RequireResourceNoOp(t, plan, "null_resource.test[2]")
+	RequireResourceCreated(t, plan, "null_resource.test[1]")
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-import", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	defer Destroy(t, options)
+
+	InitAndImport(t, options, "random_string.test", "importedvalue")
+	assert.Equal(t, []string{"random_string.test"}, StateList(t, options))
+
+	state := ShowStateWithStruct(t, options)
+	assert.Equal(t, "importedvalue", state.ResourceStateMap["random_string.test"].AttributeValues["result"])
+}
@@ -0,0 +1,35 @@
+package terraform
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// Taint runs terraform taint with the given options to mark the resource at the given address as tainted, so that it
+// is replaced on the next apply, and returns stdout/stderr. This will fail the test if there is an error in the
+// command.
+func Taint(t testing.TestingT, options *Options, address string) string {
+	out, err := TaintE(t, options, address)
+	require.NoError(t, err)
+	return out
+}
+
+// TaintE runs terraform taint with the given options to mark the resource at the given address as tainted, so that it
+// is replaced on the next apply, and returns stdout/stderr.
+func TaintE(t testing.TestingT, options *Options, address string) (string, error) {
+	return RunTerraformCommandE(t, options, formatStateArgs(options, []string{"taint"}, address)...)
+}
+
+// Untaint runs terraform untaint with the given options to remove the tainted mark from the resource at the given
+// address, and returns stdout/stderr. This will fail the test if there is an error in the command.
+func Untaint(t testing.TestingT, options *Options, address string) string {
+	out, err := UntaintE(t, options, address)
+	require.NoError(t, err)
+	return out
+}
+
+// UntaintE runs terraform untaint with the given options to remove the tainted mark from the resource at the given
+// address, and returns stdout/stderr.
+func UntaintE(t testing.TestingT, options *Options, address string) (string, error) {
+	return RunTerraformCommandE(t, options, formatStateArgs(options, []string{"untaint"}, address)...)
+}
@@ -0,0 +1,414 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// tgModulePrefixFlag makes terragrunt prefix each line the units of a run-all command print with the path of the
+	// unit, so that the output of the units, which run in parallel, can be told apart.
+	tgModulePrefixFlag = "--terragrunt-include-module-prefix"
+
+	// tgJsonOutDirFlag makes terragrunt save the plan of each unit of a run-all plan as json, in a folder per unit
+	// under the given folder.
+	tgJsonOutDirFlag = "--terragrunt-json-out-dir"
+
+	// tgPlanJsonFileName is the name of the file terragrunt saves the json plan of each unit in.
+	tgPlanJsonFileName = "tfplan.json"
+)
+
+// tgModulePrefixRegexp matches a line of output that terragrunt prefixed with the path of the unit that printed it.
+var tgModulePrefixRegexp = regexp.MustCompile(`^\[([^\]]+)\] ?(.*)$`)
+
+// TgUnitResult is the result of running a terragrunt command in a single unit of a stack.
+type TgUnitResult struct {
+	// The path of the unit, relative to the TerraformDir of the stack.
+	Path string
+
+	// The exit code of the command in the unit. For plans, this is the detailed exit code (2 if there are changes).
+	ExitCode int
+
+	// The output the unit printed, without the prefix terragrunt adds to each line.
+	Output string
+
+	// The events terraform emitted, if the command supports json output (plan, apply and destroy).
+	Json *JsonResult
+
+	// The plan of the unit, for plan.
+	Plan *PlanStruct
+
+	// The outputs of the unit after the command, for apply.
+	Outputs map[string]interface{}
+
+	// True if terragrunt did not run the command in the unit, because a unit this one depends on (or, for destroy,
+	// that depends on this one) failed.
+	Skipped bool
+
+	// The error of the command, if it failed in the unit or was skipped.
+	Err error
+}
+
+// TgStackResults maps the path of each unit of a stack, relative to the TerraformDir of the stack, to the result of
+// running a command in it.
+type TgStackResults map[string]*TgUnitResult
+
+// Failed returns the paths of the units whose command failed or was skipped, sorted.
+func (results TgStackResults) Failed() []string {
+	var failed []string
+	for path, result := range results {
+		if result.Err != nil {
+			failed = append(failed, path)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// TgStackUnits returns the paths of the units of the terragrunt stack in TerraformDir, relative to TerraformDir and
+// in the order terragrunt would apply them (dependencies first). This will fail the test if the stack can't be read.
+func TgStackUnits(t testing.TestingT, options *Options) []string {
+	units, err := TgStackUnitsE(t, options)
+	require.NoError(t, err)
+	return units
+}
+
+// TgStackUnitsE returns the paths of the units of the terragrunt stack in TerraformDir, relative to TerraformDir and
+// in the order terragrunt would apply them (dependencies first). The units and their order come from terragrunt's
+// output-module-groups command: units in the same group are sorted alphabetically.
+func TgStackUnitsE(t testing.TestingT, options *Options) ([]string, error) {
+	if options.TerraformBinary != "terragrunt" {
+		return nil, TgInvalidBinary(options.TerraformBinary)
+	}
+	out, err := RunTerraformCommandAndGetStdoutE(t, options, "output-module-groups")
+	if err != nil {
+		return nil, err
+	}
+	var groups map[string][]string
+	if err := json.Unmarshal([]byte(out), &groups); err != nil {
+		return nil, err
+	}
+
+	stackDir, err := filepath.Abs(options.TerraformDir)
+	if err != nil {
+		return nil, err
+	}
+	var units []string
+	for i := 1; i <= len(groups); i++ {
+		var group []string
+		for _, path := range groups[fmt.Sprintf("Group %d", i)] {
+			group = append(group, tgUnitRelPath(stackDir, path))
+		}
+		sort.Strings(group)
+		units = append(units, group...)
+	}
+	return units, nil
+}
+
+// TgApplyAllByUnit runs terragrunt run-all apply in the stack in TerraformDir and returns the result of each unit.
+// This will fail the test if the apply of any unit fails.
+func TgApplyAllByUnit(t testing.TestingT, options *Options) TgStackResults {
+	results, err := TgApplyAllByUnitE(t, options)
+	require.NoError(t, err)
+	return results
+}
+
+// TgApplyAllByUnitE runs terragrunt run-all apply in the stack in TerraformDir and returns the result of each unit,
+// including its outputs. Unlike TgApplyAllE, which returns the interleaved output of all the units, the output
+// terragrunt prints is split by unit, so that each result can be told apart. Units that terragrunt skipped because a
+// unit they depend on failed are marked as such. If any unit fails, the results are returned along with a
+// TgStackFailed error. As with TgApplyAllE, a PlanGuard is not supported.
+func TgApplyAllByUnitE(t testing.TestingT, options *Options) (TgStackResults, error) {
+	if options.PlanGuard != nil {
+		return nil, PlanGuardNotSupported("TgApplyAllByUnitE")
+	}
+	results, applyErr := runTgRunAllE(t, options, func(runOptions *Options) []string {
+		return FormatArgs(runOptions, "run-all", "apply", "-input=false", "-auto-approve")
+	})
+	if results == nil {
+		return nil, applyErr
+	}
+
+	// The outputs of the units that were applied are returned even if reading the outputs of the others failed.
+	outputResults, err := tgOutputAllE(t, options)
+	if outputResults == nil || (err != nil && applyErr == nil) {
+		return results, err
+	}
+	for path, result := range results {
+		if outputResult, hasOutputs := outputResults[path]; hasOutputs && result.Err == nil {
+			result.Outputs = outputResult.Outputs
+		}
+	}
+	return results, applyErr
+}
+
+// TgPlanAllByUnit runs terragrunt run-all plan in the stack in TerraformDir and returns the result of each unit, with
+// the detailed exit code and plan of each unit. This will fail the test if the plan of any unit fails.
+func TgPlanAllByUnit(t testing.TestingT, options *Options) TgStackResults {
+	results, err := TgPlanAllByUnitE(t, options)
+	require.NoError(t, err)
+	return results
+}
+
+// TgPlanAllByUnitE runs terragrunt run-all plan in the stack in TerraformDir and returns the result of each unit, with
+// the detailed exit code of each unit (DefaultSuccessExitCode if there are no changes, 2 if there are) and its plan,
+// which terragrunt saves as json with --terragrunt-json-out-dir. Units that terragrunt skipped because a unit they
+// depend on failed are marked as such. If any unit fails, the results are returned along with a TgStackFailed error.
+func TgPlanAllByUnitE(t testing.TestingT, options *Options) (TgStackResults, error) {
+	jsonOutDir, err := ioutil.TempDir("", "terratest-tg-plan-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(jsonOutDir)
+
+	results, planErr := runTgRunAllE(t, options, func(runOptions *Options) []string {
+		return append(FormatArgs(runOptions, "run-all", "plan", "-input=false"), tgJsonOutDirFlag, jsonOutDir)
+	})
+	for path, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		planJson, err := ioutil.ReadFile(filepath.Join(jsonOutDir, path, tgPlanJsonFileName))
+		if err == nil {
+			result.Plan, err = parsePlanJson(string(planJson))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return results, err
+		}
+		if tgPlanHasChanges(result) {
+			result.ExitCode = 2
+		}
+	}
+	return results, planErr
+}
+
+// TgDestroyAllByUnit runs terragrunt run-all destroy in the stack in TerraformDir and returns the result of each unit.
+// This will fail the test if the destroy of any unit fails.
+func TgDestroyAllByUnit(t testing.TestingT, options *Options) TgStackResults {
+	results, err := TgDestroyAllByUnitE(t, options)
+	require.NoError(t, err)
+	return results
+}
+
+// TgDestroyAllByUnitE runs terragrunt run-all destroy in the stack in TerraformDir and returns the result of each
+// unit. Units that terragrunt skipped because a unit that depends on them failed to be destroyed are marked as such.
+// If any unit fails, the results are returned along with a TgStackFailed error.
+func TgDestroyAllByUnitE(t testing.TestingT, options *Options) (TgStackResults, error) {
+	return runTgRunAllE(t, options, func(runOptions *Options) []string {
+		return FormatArgs(runOptions, "run-all", "destroy", "-auto-approve", "-input=false")
+	})
+}
+
+// TgOutputAll returns the outputs of each unit of the stack in TerraformDir, keyed by the path of the unit relative
+// to TerraformDir. This will fail the test if the outputs of any unit can't be read.
+func TgOutputAll(t testing.TestingT, options *Options) map[string]map[string]interface{} {
+	outputs, err := TgOutputAllE(t, options)
+	require.NoError(t, err)
+	return outputs
+}
+
+// TgOutputAllE returns the outputs of each unit of the stack in TerraformDir, keyed by the path of the unit relative
+// to TerraformDir, by running terragrunt run-all output -json.
+func TgOutputAllE(t testing.TestingT, options *Options) (map[string]map[string]interface{}, error) {
+	results, err := tgOutputAllE(t, options)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]map[string]interface{}{}
+	for path, result := range results {
+		outputs[path] = result.Outputs
+	}
+	return outputs, nil
+}
+
+// tgOutputAllE runs terragrunt run-all output -json in the stack in TerraformDir and returns the result of each unit,
+// with the outputs of each unit that printed them.
+func tgOutputAllE(t testing.TestingT, options *Options) (TgStackResults, error) {
+	results, err := runTgRunAllE(t, options, func(runOptions *Options) []string {
+		runOptions.JsonOutput = false
+		return []string{"run-all", "output", "-json"}
+	})
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		result.Outputs = map[string]interface{}{}
+		// Terragrunt may print its own messages for the unit around the json document.
+		start, end := strings.Index(result.Output, "{"), strings.LastIndex(result.Output, "}")
+		if start < 0 || end < start {
+			continue
+		}
+		unitOutputs, parseErr := parseOutputAllJson(result.Output[start : end+1])
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		result.Outputs = unitOutputs
+	}
+	return results, err
+}
+
+// parseOutputAllJson parses the output of terraform output -json into a map of the value of each output.
+func parseOutputAllJson(out string) (map[string]interface{}, error) {
+	var outputs map[string]struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(out), &outputs); err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(outputs))
+	for name, output := range outputs {
+		values[name] = output.Value
+	}
+	return values, nil
+}
+
+// runTgRunAllE runs the run-all command that formatArgs returns, with a copy of the options that has JsonOutput set
+// if the binary is known to support it, and splits the output terragrunt prints into a result for each unit of the
+// stack. A unit that reported errors failed; after a failed run-all, a unit that printed nothing was skipped by
+// terragrunt.
+func runTgRunAllE(t testing.TestingT, options *Options, formatArgs func(runOptions *Options) []string) (TgStackResults, error) {
+	units, err := TgStackUnitsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	runOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// Unlike most commands, which leave it to the binary to reject flags it doesn't support, json output is only used
+	// if the binary is known to support it, as it is optional here.
+	supportsJson, err := SupportsFeatureE(t, runOptions, FeatureJsonOutput)
+	runOptions.JsonOutput = err == nil && supportsJson
+	args := append(formatArgs(runOptions), tgModulePrefixFlag)
+
+	out, runErr := RunTerraformCommandE(t, runOptions, args...)
+	unitOutputs := splitTgUnitOutputs(runOptions.TerraformDir, units, out)
+
+	results := TgStackResults{}
+	failed := map[string]error{}
+	for _, path := range units {
+		result := &TgUnitResult{Path: path, Output: unitOutputs[path]}
+		results[path] = result
+		if runOptions.JsonOutput {
+			result.Json = ParseJsonOutput(result.Output)
+		}
+		if runErr == nil {
+			continue
+		}
+
+		if result.Output == "" {
+			result.Skipped = true
+			result.ExitCode = DefaultErrorExitCode
+			result.Err = TgUnitSkipped(path)
+			failed[path] = result.Err
+			continue
+		}
+		var errorDiagnostics []Diagnostic
+		for _, diagnostic := range ParseDiagnostics(result.Output) {
+			if diagnostic.Severity == DiagnosticSeverityError {
+				errorDiagnostics = append(errorDiagnostics, diagnostic)
+			}
+		}
+		if len(errorDiagnostics) > 0 {
+			result.ExitCode = DefaultErrorExitCode
+			result.Err = TerraformError{Diagnostic: errorDiagnostics[0], Diagnostics: errorDiagnostics, Underlying: runErr}
+			failed[path] = result.Err
+		}
+	}
+
+	if runErr == nil {
+		return results, nil
+	}
+	if len(failed) == 0 {
+		// The command failed before any unit reported an error (e.g., terragrunt could not read the stack).
+		return nil, runErr
+	}
+	return results, TgStackFailed(failed)
+}
+
+// splitTgUnitOutputs splits the output of a run-all command, run with --terragrunt-include-module-prefix, into the
+// output of each of the given units, without the prefixes. Lines without a prefix, which terragrunt prints for the
+// stack as a whole, are dropped.
+func splitTgUnitOutputs(stackDir string, units []string, output string) map[string]string {
+	absStackDir, err := filepath.Abs(stackDir)
+	if err != nil {
+		absStackDir = stackDir
+	}
+
+	lines := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		matches := tgModulePrefixRegexp.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if matches == nil {
+			continue
+		}
+		if unit, isUnit := findTgUnit(absStackDir, units, matches[1]); isUnit {
+			lines[unit] = append(lines[unit], matches[2])
+		}
+	}
+
+	outputs := map[string]string{}
+	for unit, unitLines := range lines {
+		outputs[unit] = strings.Join(unitLines, "\n")
+	}
+	return outputs
+}
+
+// findTgUnit returns the unit that the given prefix of a line of run-all output refers to. Depending on its version,
+// terragrunt prefixes lines with the path of the unit relative to the stack, or with its absolute path.
+func findTgUnit(absStackDir string, units []string, prefix string) (string, bool) {
+	prefix = filepath.Clean(prefix)
+	if filepath.IsAbs(prefix) {
+		prefix = tgUnitRelPath(absStackDir, prefix)
+	}
+	for _, unit := range units {
+		if unit == prefix {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+// tgUnitRelPath returns the given path of a unit relative to the stack, or the path itself if the unit is outside of
+// the stack.
+func tgUnitRelPath(absStackDir string, path string) string {
+	relPath, err := filepath.Rel(absStackDir, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return path
+	}
+	return relPath
+}
+
+// tgPlanHasChanges returns true if the plan of the given unit has changes, according to its json plan or, if
+// terragrunt did not save one, the change summary terraform reported. As with the detailed exit code of plan, reading
+// data sources is not a change.
+func tgPlanHasChanges(result *TgUnitResult) bool {
+	if result.Plan != nil {
+		for _, change := range result.Plan.RawPlan.ResourceChanges {
+			if change.Change != nil && !change.Change.Actions.NoOp() && !change.Change.Actions.Read() {
+				return true
+			}
+		}
+		for _, change := range result.Plan.RawPlan.OutputChanges {
+			if change != nil && !change.Actions.NoOp() {
+				return true
+			}
+		}
+		return false
+	}
+	if result.Json != nil && result.Json.ChangeSummary != nil {
+		count := result.Json.ResourceCount()
+		return count.Add+count.Change+count.Destroy > 0
+	}
+	count, err := GetResourceCountE(nil, result.Output)
+	return err == nil && count.Add+count.Change+count.Destroy > 0
+}
@@ -0,0 +1,213 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeTerragruntBinary writes a fake terragrunt binary to a new folder, which is added to the PATH, and returns
+// the path of the log file the binary records each invocation in. The binary emulates a stack of the units vpc,
+// monitoring, db (which depends on vpc) and app (which depends on vpc and db): run-all prints a line prefixed with the
+// path of each unit, fails in the unit set in the FAIL_UNIT environment variable and skips the units set in
+// SKIP_UNITS. Plans have changes in the unit set in CHANGED_UNIT, and only read data sources in the unit set in
+// READ_UNIT. The log records -json when run-all gets it. TERRAGRUNT_TFPATH is set to a binary that doesn't exist, so
+// that the version of the binary terragrunt runs can't be detected unless a test sets it.
+func writeFakeTerragruntBinary(t *testing.T) string {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "invocations.log")
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" != "run-all" ]; then
+  echo "$1" >> %q
+  printf '{"Group 1": ["%%s/vpc", "%%s/monitoring"], "Group 2": ["%%s/db"], "Group 3": ["%%s/app"]}' "$PWD" "$PWD" "$PWD" "$PWD"
+  exit 0
+fi
+command=$2
+previous=""
+json=""
+for arg in "$@"; do
+  if [ "$previous" = "--terragrunt-json-out-dir" ]; then json_out_dir=$arg; fi
+  if [ "$arg" = "-json" ]; then json=" -json"; fi
+  previous=$arg
+done
+echo "$1 $2$json" >> %q
+units="vpc monitoring db app"
+if [ "$command" = "destroy" ]; then units="app db monitoring vpc"; fi
+status=0
+for unit in $units; do
+  case " $SKIP_UNITS " in *" $unit "*) continue ;; esac
+  if [ "$unit" = "$FAIL_UNIT" ]; then
+    echo "[$unit] Error: $command failed in $unit" >&2
+    status=1
+  elif [ "$command" = "output" ]; then
+    echo "[$PWD/$unit] {\"unit\": {\"sensitive\": false, \"type\": \"string\", \"value\": \"$unit\"}}"
+  else
+    if [ -n "$json_out_dir" ]; then
+      mkdir -p "$json_out_dir/$unit"
+      actions='["no-op"]'
+      if [ "$unit" = "$CHANGED_UNIT" ]; then actions='["create"]'; fi
+      if [ "$unit" = "$READ_UNIT" ]; then actions='["read"]'; fi
+      echo "{\"format_version\": \"1.0\", \"resource_changes\": [{\"address\": \"null_resource.$unit\", \"change\": {\"actions\": $actions}}]}" > "$json_out_dir/$unit/tfplan.json"
+    fi
+    echo "[$unit] $command succeeded in $unit"
+  fi
+done
+if [ "$status" != 0 ]; then echo "Encountered errors while running $command" >&2; fi
+exit $status
+`, logPath, logPath)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "terragrunt"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TERRAGRUNT_TFPATH", filepath.Join(dir, "missing-terraform"))
+	return logPath
+}
+
+func TestTgStackUnits(t *testing.T) {
+	writeFakeTerragruntBinary(t)
+
+	units := TgStackUnits(t, &Options{TerraformDir: t.TempDir(), TerraformBinary: "terragrunt"})
+	assert.Equal(t, []string{"monitoring", "vpc", "db", "app"}, units)
+}
+
+func TestSplitTgUnitOutputs(t *testing.T) {
+	t.Parallel()
+
+	stackDir := t.TempDir()
+	output := fmt.Sprintf(`Running the units in 2 groups
+[vpc] Initializing...
+[%s] Error: Invalid reference
+[vpc] Apply complete!
+[modules/shared] Not a unit of the stack
+[%s]`, filepath.Join(stackDir, "db"), filepath.Join(stackDir, "vpc"))
+
+	outputs := splitTgUnitOutputs(stackDir, []string{"vpc", "db", "app"}, output)
+	assert.Equal(t, map[string]string{
+		"vpc": "Initializing...\nApply complete!\n",
+		"db":  "Error: Invalid reference",
+	}, outputs)
+}
+
+func TestTgApplyAllByUnitMarksSkippedUnits(t *testing.T) {
+	logPath := writeFakeTerragruntBinary(t)
+
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: "terragrunt",
+		EnvVars:         map[string]string{"FAIL_UNIT": "db", "SKIP_UNITS": "app"},
+	}
+	results, err := TgApplyAllByUnitE(t, options)
+	require.Error(t, err)
+	assert.IsType(t, TgStackFailed{}, err)
+	assert.Equal(t, []string{"app", "db"}, results.Failed())
+
+	assert.Equal(t, DefaultSuccessExitCode, results["vpc"].ExitCode)
+	assert.Equal(t, "apply succeeded in vpc", results["vpc"].Output)
+	assert.Equal(t, map[string]interface{}{"unit": "vpc"}, results["vpc"].Outputs)
+
+	assert.Equal(t, DefaultErrorExitCode, results["db"].ExitCode)
+	assert.False(t, results["db"].Skipped)
+	var tfErr TerraformError
+	require.ErrorAs(t, results["db"].Err, &tfErr)
+	assert.Equal(t, "apply failed in db", tfErr.Summary)
+	assert.Nil(t, results["db"].Outputs)
+
+	assert.True(t, results["app"].Skipped)
+	assert.Equal(t, TgUnitSkipped("app"), results["app"].Err)
+
+	assert.Equal(t, []string{
+		"output-module-groups", "run-all apply",
+		"output-module-groups", "run-all output -json",
+	}, readInvocations(t, logPath))
+}
+
+func TestTgDestroyAllByUnitMarksSkippedUnits(t *testing.T) {
+	logPath := writeFakeTerragruntBinary(t)
+
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: "terragrunt",
+		EnvVars:         map[string]string{"FAIL_UNIT": "db", "SKIP_UNITS": "vpc"},
+	}
+	results, err := TgDestroyAllByUnitE(t, options)
+	require.Error(t, err)
+	assert.Equal(t, []string{"db", "vpc"}, results.Failed())
+	assert.Equal(t, TgUnitSkipped("vpc"), results["vpc"].Err)
+	assert.Equal(t, "destroy succeeded in app", results["app"].Output)
+	assert.Equal(t, []string{"output-module-groups", "run-all destroy"}, readInvocations(t, logPath))
+}
+
+func TestTgPlanAllByUnitWithFakeTerragrunt(t *testing.T) {
+	writeFakeTerragruntBinary(t)
+
+	options := &Options{
+		TerraformDir:    t.TempDir(),
+		TerraformBinary: "terragrunt",
+		EnvVars:         map[string]string{"CHANGED_UNIT": "db", "READ_UNIT": "app"},
+	}
+	results := TgPlanAllByUnit(t, options)
+	assert.Empty(t, results.Failed())
+	assert.Equal(t, 2, results["db"].ExitCode)
+	assert.Equal(t, DefaultSuccessExitCode, results["vpc"].ExitCode)
+	assert.Equal(t, DefaultSuccessExitCode, results["app"].ExitCode)
+	require.NotNil(t, results["db"].Plan)
+	assert.Contains(t, results["db"].Plan.ResourceChangesMap, "null_resource.db")
+}
+
+func TestTgApplyAllByUnitUsesJsonOutputOnlyIfSupported(t *testing.T) {
+	logPath := writeFakeTerragruntBinary(t)
+	oldBinary, _ := writeFakeVersionBinary(t, "terraform", `{"terraform_version":"0.14.11","platform":"linux_amd64"}`, "Terraform v0.14.11")
+	newBinary, _ := writeFakeVersionBinary(t, "terraform", `{"terraform_version":"1.5.7","platform":"linux_amd64"}`, "Terraform v1.5.7")
+
+	for _, tfPath := range []string{"", oldBinary, newBinary} {
+		options := &Options{TerraformDir: t.TempDir(), TerraformBinary: "terragrunt", EnvVars: map[string]string{}}
+		if tfPath != "" {
+			options.EnvVars["TERRAGRUNT_TFPATH"] = tfPath
+		}
+		results := TgApplyAllByUnit(t, options)
+		assert.Equal(t, "apply succeeded in vpc", results["vpc"].Output)
+	}
+	assert.Equal(t, []string{
+		"output-module-groups", "run-all apply", "output-module-groups", "run-all output -json",
+		"output-module-groups", "run-all apply", "output-module-groups", "run-all output -json",
+		"output-module-groups", "run-all apply -json", "output-module-groups", "run-all output -json",
+	}, readInvocations(t, logPath))
+}
+
+func TestTgOutputAllWithFakeTerragrunt(t *testing.T) {
+	writeFakeTerragruntBinary(t)
+
+	outputs := TgOutputAll(t, &Options{TerraformDir: t.TempDir(), TerraformBinary: "terragrunt"})
+	assert.Equal(t, map[string]map[string]interface{}{
+		"monitoring": {"unit": "monitoring"},
+		"vpc":        {"unit": "vpc"},
+		"db":         {"unit": "db"},
+		"app":        {"unit": "app"},
+	}, outputs)
+}
+
+func TestTgStackByUnit(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerragruntFolderToTemp("../../test/fixtures/terragrunt/terragrunt-stack", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir:    testFolder,
+		TerraformBinary: "terragrunt",
+	}
+	defer TgDestroyAllByUnit(t, options)
+
+	results := TgApplyAllByUnit(t, options)
+	assert.Equal(t, "vpc-terratest", results["vpc"].Outputs["vpc_id"])
+
+	outputs := TgOutputAll(t, options)
+	assert.Equal(t, "vpc-terratest", outputs["app"]["app_vpc_id"])
+
+	plans := TgPlanAllByUnit(t, options)
+	assert.Equal(t, DefaultSuccessExitCode, plans["app"].ExitCode)
+}
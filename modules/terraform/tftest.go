@@ -0,0 +1,275 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	gotesting "testing"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// TestStatus is the status of a test file or run block, as reported by terraform test.
+type TestStatus string
+
+const (
+	TestStatusPending TestStatus = "pending"
+	TestStatusSkip    TestStatus = "skip"
+	TestStatusPass    TestStatus = "pass"
+	TestStatusFail    TestStatus = "fail"
+	TestStatusError   TestStatus = "error"
+)
+
+// JsonTestFile is the progress of a test file, as reported by the test_file event of terraform test.
+type JsonTestFile struct {
+	Path     string     `json:"path"`
+	Progress string     `json:"progress,omitempty"`
+	Status   TestStatus `json:"status,omitempty"`
+}
+
+// JsonTestRun is the progress of a run block, as reported by the test_run event of terraform test.
+type JsonTestRun struct {
+	Path     string     `json:"path"`
+	Run      string     `json:"run"`
+	Progress string     `json:"progress,omitempty"`
+	Status   TestStatus `json:"status,omitempty"`
+}
+
+// JsonTestSummary is the summary of all the tests, as reported by the test_summary event of terraform test.
+type JsonTestSummary struct {
+	Status  TestStatus `json:"status"`
+	Passed  int        `json:"passed"`
+	Failed  int        `json:"failed"`
+	Errored int        `json:"errored"`
+	Skipped int        `json:"skipped"`
+}
+
+// TestResults are the results of running terraform test.
+type TestResults struct {
+	// The overall status of the tests.
+	Status TestStatus
+
+	// The results of each test file, sorted by path.
+	Files []*TestFileResult
+
+	// The summary terraform reported, or nil if terraform did not get to run the tests.
+	Summary *JsonTestSummary
+
+	// The diagnostics terraform reported that are not specific to a test file (e.g., a syntax error).
+	Diagnostics []Diagnostic
+}
+
+// TestFileResult is the result of a single test file.
+type TestFileResult struct {
+	Path   string
+	Status TestStatus
+
+	// The results of the run blocks of the file, in the order they are declared in.
+	Runs []*TestRunResult
+
+	// The diagnostics terraform reported for the file that are not specific to a run block (e.g., a failure to
+	// destroy the resources created by the file).
+	Diagnostics []Diagnostic
+}
+
+// TestRunResult is the result of a single run block.
+type TestRunResult struct {
+	Name        string
+	Status      TestStatus
+	Diagnostics []Diagnostic
+}
+
+// Passed returns true if all the tests passed (or were skipped).
+func (results *TestResults) Passed() bool {
+	return results.Status == TestStatusPass || results.Status == TestStatusSkip
+}
+
+// File returns the result of the test file with the given path, or nil if there is no such file.
+func (results *TestResults) File(path string) *TestFileResult {
+	for _, file := range results.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+	return nil
+}
+
+// Run returns the result of the run block with the given name, or nil if there is no such run block.
+func (file *TestFileResult) Run(name string) *TestRunResult {
+	for _, run := range file.Runs {
+		if run.Name == name {
+			return run
+		}
+	}
+	return nil
+}
+
+// RunTests runs terraform test with the given options and reports the result of each run block as a subtest, named
+// after the test file and the run block (e.g., TestModule/tests/main.tftest.hcl/creates_bucket). Failed and errored
+// run blocks fail their subtest with the diagnostics terraform reported, and skipped run blocks skip it. This fails the
+// test if terraform could not run the tests at all, and reports the error terraform test exited with (if any) on the
+// test itself, as not every error is specific to a run block (e.g., a failure to init a module used by the tests). Note
+// that terraform init must run before the tests.
+func RunTests(t *gotesting.T, options *Options) *TestResults {
+	results, err := RunTestsE(t, options)
+	if results == nil || (err != nil && len(results.Files) == 0) {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, diagnostic := range results.Diagnostics {
+		if diagnostic.Severity == DiagnosticSeverityError {
+			t.Error(diagnostic.String())
+		}
+	}
+	for _, file := range results.Files {
+		file := file
+		t.Run(file.Path, func(t *gotesting.T) {
+			for _, diagnostic := range file.Diagnostics {
+				if diagnostic.Severity == DiagnosticSeverityError {
+					t.Error(diagnostic.String())
+				}
+			}
+			for _, run := range file.Runs {
+				run := run
+				t.Run(run.Name, func(t *gotesting.T) {
+					reportTestRun(t, run)
+				})
+			}
+		})
+	}
+	return results
+}
+
+// reportTestRun reports the result of the given run block on the given subtest.
+func reportTestRun(t *gotesting.T, run *TestRunResult) {
+	for _, diagnostic := range run.Diagnostics {
+		if diagnostic.Severity == DiagnosticSeverityWarning {
+			t.Log(diagnostic.String())
+		}
+	}
+
+	switch run.Status {
+	case TestStatusFail, TestStatusError:
+		for _, diagnostic := range run.Diagnostics {
+			if diagnostic.Severity == DiagnosticSeverityError {
+				t.Error(diagnostic.String())
+			}
+		}
+		if !t.Failed() {
+			t.Errorf("Run block %q finished with status %q", run.Name, run.Status)
+		}
+	case TestStatusSkip, TestStatusPending:
+		t.Skipf("Run block %q was not run (status %q)", run.Name, run.Status)
+	}
+}
+
+// RunTestsE runs terraform test with the given options and returns the result of each test file and run block. As
+// terraform test exits with an error when any test fails, the results are returned along with that error. The tests
+// are never retried, even if RetryableTerraformErrors is set. This returns an UnsupportedFeature error if the binary
+// does not support terraform test. Note that terraform init must run before the tests.
+func RunTestsE(t testing.TestingT, options *Options) (*TestResults, error) {
+	if err := checkFeatureSupport(t, options, FeatureTesting); err != nil {
+		return nil, err
+	}
+
+	testOptions, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+	testOptions.JsonOutput = true
+	// Retrying would run the whole suite again, and a failing test can print anything, including retryable errors.
+	testOptions.RetryableTerraformErrors = nil
+
+	out, err := RunTerraformCommandE(t, testOptions, formatTestArgs(testOptions)...)
+	return ParseTestResults(out), err
+}
+
+// formatTestArgs returns the arguments to run terraform test with the given options.
+func formatTestArgs(options *Options) []string {
+	args := []string{"test"}
+	if options.SetVarsAfterVarFiles {
+		args = append(args, FormatTerraformArgs("-var-file", options.VarFiles)...)
+		args = append(args, formatVarsAsArgs(options)...)
+	} else {
+		args = append(args, formatVarsAsArgs(options)...)
+		args = append(args, FormatTerraformArgs("-var-file", options.VarFiles)...)
+	}
+	for _, filter := range options.TestFilters {
+		args = append(args, fmt.Sprintf("-filter=%s", filter))
+	}
+	if options.TestDirectory != "" {
+		args = append(args, fmt.Sprintf("-test-directory=%s", options.TestDirectory))
+	}
+	if options.NoColor {
+		args = append(args, "-no-color")
+	}
+	return append(args, "-json")
+}
+
+// ParseTestResults parses the json output of terraform test into the results of each test file and run block.
+func ParseTestResults(output string) *TestResults {
+	results := &TestResults{Status: TestStatusPending}
+	files := map[string]*TestFileResult{}
+
+	getFile := func(path string) *TestFileResult {
+		if _, hasFile := files[path]; !hasFile {
+			files[path] = &TestFileResult{Path: path, Status: TestStatusPending}
+		}
+		return files[path]
+	}
+	getRun := func(path string, name string) *TestRunResult {
+		file := getFile(path)
+		if run := file.Run(name); run != nil {
+			return run
+		}
+		run := &TestRunResult{Name: name, Status: TestStatusPending}
+		file.Runs = append(file.Runs, run)
+		return run
+	}
+
+	for _, event := range ParseJsonOutput(output).Events {
+		switch {
+		case event.Type == JsonEventTestAbstract:
+			for path, runs := range event.TestAbstract {
+				for _, name := range runs {
+					getRun(path, name)
+				}
+			}
+		case event.Type == JsonEventTestFile && event.TestFileInfo != nil && event.TestFileInfo.Status != "":
+			getFile(event.TestFileInfo.Path).Status = event.TestFileInfo.Status
+		case event.Type == JsonEventTestRun && event.TestRunInfo != nil && event.TestRunInfo.Status != "":
+			getRun(event.TestRunInfo.Path, event.TestRunInfo.Run).Status = event.TestRunInfo.Status
+		case event.Type == JsonEventTestSummary && event.TestSummary != nil:
+			results.Summary = event.TestSummary
+			results.Status = event.TestSummary.Status
+		case event.Type == JsonEventDiagnostic && event.Diagnostic != nil:
+			switch {
+			case event.TestFile != "" && event.TestRun != "":
+				run := getRun(event.TestFile, event.TestRun)
+				run.Diagnostics = append(run.Diagnostics, *event.Diagnostic)
+			case event.TestFile != "":
+				file := getFile(event.TestFile)
+				file.Diagnostics = append(file.Diagnostics, *event.Diagnostic)
+			default:
+				results.Diagnostics = append(results.Diagnostics, *event.Diagnostic)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		results.Files = append(results.Files, files[path])
+	}
+
+	// If terraform could not run the tests, it does not report a summary.
+	if results.Summary == nil && len(results.Diagnostics) > 0 {
+		results.Status = TestStatusError
+	}
+	return results
+}
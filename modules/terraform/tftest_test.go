@@ -0,0 +1,154 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const terraformTestJsonOutput = `{"@level":"info","@message":"Terraform 1.6.6","@module":"terraform.ui","@timestamp":"2024-01-10T10:00:00.000000Z","terraform":"1.6.6","type":"version","ui":"1.2"}
+{"@level":"info","@message":"Found 2 files and 3 run blocks","@module":"terraform.ui","@timestamp":"2024-01-10T10:00:00.000000Z","test_abstract":{"tests/failing.tftest.hcl":["wrong_greeting"],"tests/greeting.tftest.hcl":["default_greeting","custom_greeting"]},"type":"test_abstract"}
+{"@level":"info","@message":"tests/failing.tftest.hcl... in progress","@module":"terraform.ui","@testfile":"tests/failing.tftest.hcl","@timestamp":"2024-01-10T10:00:00.000000Z","test_file":{"path":"tests/failing.tftest.hcl","progress":"starting"},"type":"test_file"}
+{"@level":"info","@message":"  \"wrong_greeting\"... fail","@module":"terraform.ui","@testfile":"tests/failing.tftest.hcl","@testrun":"wrong_greeting","@timestamp":"2024-01-10T10:00:01.000000Z","test_run":{"path":"tests/failing.tftest.hcl","run":"wrong_greeting","progress":"complete","status":"fail"},"type":"test_run"}
+{"@level":"error","@message":"Error: Test assertion failed","@module":"terraform.ui","@testfile":"tests/failing.tftest.hcl","@testrun":"wrong_greeting","@timestamp":"2024-01-10T10:00:01.000000Z","diagnostic":{"severity":"error","summary":"Test assertion failed","detail":"The greeting is not a goodbye.","range":{"filename":"tests/failing.tftest.hcl","start":{"line":5,"column":21,"byte":64},"end":{"line":5,"column":55,"byte":98}}},"type":"diagnostic"}
+{"@level":"info","@message":"tests/failing.tftest.hcl... fail","@module":"terraform.ui","@testfile":"tests/failing.tftest.hcl","@timestamp":"2024-01-10T10:00:01.000000Z","test_file":{"path":"tests/failing.tftest.hcl","progress":"complete","status":"fail"},"type":"test_file"}
+{"@level":"info","@message":"tests/greeting.tftest.hcl... in progress","@module":"terraform.ui","@testfile":"tests/greeting.tftest.hcl","@timestamp":"2024-01-10T10:00:01.000000Z","test_file":{"path":"tests/greeting.tftest.hcl","progress":"starting"},"type":"test_file"}
+{"@level":"info","@message":"  \"default_greeting\"... pass","@module":"terraform.ui","@testfile":"tests/greeting.tftest.hcl","@testrun":"default_greeting","@timestamp":"2024-01-10T10:00:02.000000Z","test_run":{"path":"tests/greeting.tftest.hcl","run":"default_greeting","progress":"complete","status":"pass"},"type":"test_run"}
+{"@level":"info","@message":"  \"custom_greeting\"... pass","@module":"terraform.ui","@testfile":"tests/greeting.tftest.hcl","@testrun":"custom_greeting","@timestamp":"2024-01-10T10:00:03.000000Z","test_run":{"path":"tests/greeting.tftest.hcl","run":"custom_greeting","progress":"complete","status":"pass"},"type":"test_run"}
+{"@level":"info","@message":"tests/greeting.tftest.hcl... pass","@module":"terraform.ui","@testfile":"tests/greeting.tftest.hcl","@timestamp":"2024-01-10T10:00:03.000000Z","test_file":{"path":"tests/greeting.tftest.hcl","progress":"complete","status":"pass"},"type":"test_file"}
+{"@level":"info","@message":"Failure! 2 passed, 1 failed.","@module":"terraform.ui","@timestamp":"2024-01-10T10:00:03.000000Z","test_summary":{"status":"fail","passed":2,"failed":1,"errored":0,"skipped":0},"type":"test_summary"}`
+
+func TestParseTestResults(t *testing.T) {
+	t.Parallel()
+
+	results := ParseTestResults(terraformTestJsonOutput)
+
+	assert.Equal(t, TestStatusFail, results.Status)
+	assert.False(t, results.Passed())
+	assert.Equal(t, &JsonTestSummary{Status: TestStatusFail, Passed: 2, Failed: 1}, results.Summary)
+	require.Len(t, results.Files, 2)
+	assert.Equal(t, "tests/failing.tftest.hcl", results.Files[0].Path)
+	assert.Equal(t, "tests/greeting.tftest.hcl", results.Files[1].Path)
+
+	failing := results.File("tests/failing.tftest.hcl")
+	assert.Equal(t, TestStatusFail, failing.Status)
+	wrongGreeting := failing.Run("wrong_greeting")
+	require.NotNil(t, wrongGreeting)
+	assert.Equal(t, TestStatusFail, wrongGreeting.Status)
+	require.Len(t, wrongGreeting.Diagnostics, 1)
+	assert.Equal(t, "The greeting is not a goodbye.", wrongGreeting.Diagnostics[0].Detail)
+
+	greeting := results.File("tests/greeting.tftest.hcl")
+	assert.Equal(t, TestStatusPass, greeting.Status)
+	require.Len(t, greeting.Runs, 2)
+	assert.Equal(t, "default_greeting", greeting.Runs[0].Name)
+	assert.Equal(t, "custom_greeting", greeting.Runs[1].Name)
+	assert.Equal(t, TestStatusPass, greeting.Runs[1].Status)
+
+	assert.Nil(t, results.File("tests/missing.tftest.hcl"))
+	assert.Nil(t, greeting.Run("missing"))
+}
+
+func TestParseTestResultsWithoutSummary(t *testing.T) {
+	t.Parallel()
+
+	output := `{"@level":"error","@message":"Error: Unsupported block type","@module":"terraform.ui","@timestamp":"2024-01-10T10:00:00.000000Z","diagnostic":{"severity":"error","summary":"Unsupported block type","detail":"Blocks of type \"runn\" are not expected here."},"type":"diagnostic"}`
+	results := ParseTestResults(output)
+
+	assert.Equal(t, TestStatusError, results.Status)
+	assert.Empty(t, results.Files)
+	require.Len(t, results.Diagnostics, 1)
+	assert.Equal(t, "Unsupported block type", results.Diagnostics[0].Summary)
+}
+
+func TestFormatTestArgs(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		Vars:          map[string]interface{}{"name": "terratest"},
+		VarFiles:      []string{"test.tfvars"},
+		TestFilters:   []string{"tests/a.tftest.hcl", "tests/b.tftest.hcl"},
+		TestDirectory: "unit",
+		NoColor:       true,
+	}
+	assert.Equal(t, []string{
+		"test",
+		"-var", "name=terratest",
+		"-var-file", "test.tfvars",
+		"-filter=tests/a.tftest.hcl",
+		"-filter=tests/b.tftest.hcl",
+		"-test-directory=unit",
+		"-no-color",
+		"-json",
+	}, formatTestArgs(options))
+}
+
+func TestRunTests(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-test", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		TestFilters:  []string{"tests/greeting.tftest.hcl"},
+	}
+	Init(t, options)
+
+	results := RunTests(t, options)
+	assert.True(t, results.Passed())
+	assert.Equal(t, 2, results.Summary.Passed)
+}
+
+func TestRunTestsEWithFailure(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-test", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	Init(t, options)
+
+	results, err := RunTestsE(t, options)
+	require.Error(t, err)
+	assert.Equal(t, TestStatusFail, results.Status)
+	wrongGreeting := results.File("tests/failing.tftest.hcl").Run("wrong_greeting")
+	assert.Equal(t, TestStatusFail, wrongGreeting.Status)
+	require.NotEmpty(t, wrongGreeting.Diagnostics)
+	assert.Equal(t, "The greeting is not a goodbye.", wrongGreeting.Diagnostics[0].Detail)
+}
+
+func TestRunTestsEDoesNotRetry(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "test.json")
+	logPath := filepath.Join(dir, "invocations.log")
+	require.NoError(t, ioutil.WriteFile(outputPath, []byte(terraformTestJsonOutput), 0644))
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$1" >> %q
+if [ "$1" = "version" ]; then echo '{"terraform_version":"1.6.6"}'; exit 0; fi
+cat %q
+exit 1
+`, logPath, outputPath)
+	binaryPath := filepath.Join(dir, "terraform")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+
+	options := &Options{
+		TerraformDir:             t.TempDir(),
+		TerraformBinary:          binaryPath,
+		RetryableTerraformErrors: map[string]string{"greeting": "flaky greeting"},
+		MaxRetries:               3,
+	}
+	results, err := RunTestsE(t, options)
+	require.Error(t, err)
+	assert.Equal(t, TestStatusFail, results.Status)
+	assert.Equal(t, []string{"version", "test"}, readInvocations(t, logPath))
+}
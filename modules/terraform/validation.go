@@ -0,0 +1,198 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The summaries terraform uses for the diagnostics of failed validation rules, conditions and check blocks.
+const (
+	variableValidationFailedSummary = "Invalid value for variable"
+	checkAssertionFailedSummary     = "Check block assertion failed"
+)
+
+// conditionFailedSummaryRegexp matches the summaries of failed preconditions and postconditions, e.g. "Resource
+// precondition failed" or "Module output value precondition failed".
+var conditionFailedSummaryRegexp = regexp.MustCompile(`(pre|post)condition failed$`)
+
+// ValidationErrorCase is a set of invalid variables and the validation error terraform is expected to report for them.
+// See PlanExpectValidationErrors.
+type ValidationErrorCase struct {
+	// The name of the case, used in failure messages.
+	Name string
+
+	// The variables to set, in addition to (and overriding) the Vars of the options.
+	Vars map[string]interface{}
+
+	// The name of the variable whose validation rule is expected to fail.
+	VarName string
+
+	// A regular expression the error message of the validation rule is expected to match.
+	MessageRegex string
+}
+
+// PlanExpectValidationError runs terraform plan with the given options and checks that it fails because a validation
+// rule of the given variable failed with an error message matching the given regular expression, failing the test
+// otherwise.
+func PlanExpectValidationError(t testing.TestingT, options *Options, varName string, messageRegex string) {
+	require.NoError(t, PlanExpectValidationErrorE(t, options, varName, messageRegex))
+}
+
+// PlanExpectValidationErrorE runs terraform plan with the given options and checks that it fails because a validation
+// rule of the given variable failed with an error message matching the given regular expression, returning an error
+// otherwise.
+func PlanExpectValidationErrorE(t testing.TestingT, options *Options, varName string, messageRegex string) error {
+	_, err := PlanE(t, options)
+	description := fmt.Sprintf("a failed validation rule of variable %q with an error message matching %q", varName, messageRegex)
+	return expectErrorDiagnosticE(err, description, messageRegex, func(diagnostic Diagnostic) bool {
+		return diagnostic.Summary == variableValidationFailedSummary && isDiagnosticForVariable(diagnostic, varName)
+	})
+}
+
+// PlanExpectValidationErrors runs terraform plan once for each of the given cases, with the variables of the case, and
+// checks that each plan fails because of the expected validation rule. All the cases are checked, and the test fails
+// if any of them did not fail as expected.
+func PlanExpectValidationErrors(t testing.TestingT, options *Options, cases []ValidationErrorCase) {
+	for _, validationCase := range cases {
+		caseOptions, err := options.Clone()
+		require.NoError(t, err)
+		for key, value := range validationCase.Vars {
+			caseOptions.Vars[key] = value
+		}
+
+		err = PlanExpectValidationErrorE(t, caseOptions, validationCase.VarName, validationCase.MessageRegex)
+		assert.NoError(t, err, "Validation error case %q", validationCase.Name)
+	}
+}
+
+// PlanExpectConditionError runs terraform plan with the given options and checks that it fails because a precondition
+// or postcondition of the resource (or output) with the given address failed with an error message matching the given
+// regular expression, failing the test otherwise. Use an empty address to match a condition on any address.
+func PlanExpectConditionError(t testing.TestingT, options *Options, address string, messageRegex string) {
+	require.NoError(t, PlanExpectConditionErrorE(t, options, address, messageRegex))
+}
+
+// PlanExpectConditionErrorE runs terraform plan with the given options and checks that it fails because a
+// precondition or postcondition of the resource (or output) with the given address failed with an error message
+// matching the given regular expression, returning an error otherwise. Use an empty address to match a condition on
+// any address.
+func PlanExpectConditionErrorE(t testing.TestingT, options *Options, address string, messageRegex string) error {
+	_, err := PlanE(t, options)
+	return expectConditionErrorE(err, address, messageRegex)
+}
+
+// ApplyExpectConditionError runs terraform apply with the given options and checks that it fails because a
+// precondition or postcondition of the resource (or output) with the given address failed with an error message
+// matching the given regular expression, failing the test otherwise. This is useful for postconditions on values that
+// are only known after apply. Note that this method does NOT call destroy and assumes the caller is responsible for
+// cleaning up any resources created by running apply.
+func ApplyExpectConditionError(t testing.TestingT, options *Options, address string, messageRegex string) {
+	require.NoError(t, ApplyExpectConditionErrorE(t, options, address, messageRegex))
+}
+
+// ApplyExpectConditionErrorE runs terraform apply with the given options and checks that it fails because a
+// precondition or postcondition of the resource (or output) with the given address failed with an error message
+// matching the given regular expression, returning an error otherwise. Note that this method does NOT call destroy
+// and assumes the caller is responsible for cleaning up any resources created by running apply.
+func ApplyExpectConditionErrorE(t testing.TestingT, options *Options, address string, messageRegex string) error {
+	_, err := ApplyE(t, options)
+	return expectConditionErrorE(err, address, messageRegex)
+}
+
+func expectConditionErrorE(err error, address string, messageRegex string) error {
+	description := fmt.Sprintf("a failed condition with an error message matching %q", messageRegex)
+	if address != "" {
+		description = fmt.Sprintf("a failed condition of %q with an error message matching %q", address, messageRegex)
+	}
+	return expectErrorDiagnosticE(err, description, messageRegex, func(diagnostic Diagnostic) bool {
+		return conditionFailedSummaryRegexp.MatchString(diagnostic.Summary) && (address == "" || diagnostic.Address == address)
+	})
+}
+
+// AssertCheckBlockFailed checks that the given output of terraform plan or apply reports that an assertion of the
+// check block with the given name failed with an error message matching the given regular expression. As failed
+// check blocks are only reported as warnings, the command itself succeeds.
+func AssertCheckBlockFailed(t testing.TestingT, output string, checkName string, messageRegex string) {
+	assert.NoError(t, checkBlockFailedE(output, checkName, messageRegex))
+}
+
+// RequireCheckBlockFailed checks that the given output of terraform plan or apply reports that an assertion of the
+// check block with the given name failed with an error message matching the given regular expression, failing the test
+// immediately if it does not.
+func RequireCheckBlockFailed(t testing.TestingT, output string, checkName string, messageRegex string) {
+	require.NoError(t, checkBlockFailedE(output, checkName, messageRegex))
+}
+
+func checkBlockFailedE(output string, checkName string, messageRegex string) error {
+	messagePattern, err := regexp.Compile(messageRegex)
+	if err != nil {
+		return err
+	}
+
+	diagnostics := ParseDiagnostics(output)
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Summary == checkAssertionFailedSummary && isDiagnosticForCheck(diagnostic, checkName) && messagePattern.MatchString(diagnostic.Detail) {
+			return nil
+		}
+	}
+	return ExpectedDiagnosticNotFound{
+		Expected:    fmt.Sprintf("a failed assertion of check block %q with an error message matching %q", checkName, messageRegex),
+		Diagnostics: diagnostics,
+	}
+}
+
+// expectErrorDiagnosticE checks that the given error of a terraform command is a TerraformError with a diagnostic that
+// is accepted by the given function and whose detail matches the given regular expression. If the command failed for
+// any other reason, its error is returned as is.
+func expectErrorDiagnosticE(err error, description string, messageRegex string, accept func(Diagnostic) bool) error {
+	messagePattern, compileErr := regexp.Compile(messageRegex)
+	if compileErr != nil {
+		return compileErr
+	}
+	if err == nil {
+		return ExpectedDiagnosticNotFound{Expected: description}
+	}
+
+	var tfErr TerraformError
+	if !errors.As(err, &tfErr) {
+		return err
+	}
+	for _, diagnostic := range tfErr.Diagnostics {
+		if accept(diagnostic) && messagePattern.MatchString(diagnostic.Detail) {
+			return nil
+		}
+	}
+	return ExpectedDiagnosticNotFound{Expected: description, Diagnostics: tfErr.Diagnostics}
+}
+
+// isDiagnosticForVariable returns true if the given diagnostic refers to the variable with the given name. Depending
+// on the version of terraform, the diagnostic refers to the declaration of the variable, the value passed for it, or
+// the condition of the validation rule (in which case the value of the variable is part of the snippet).
+func isDiagnosticForVariable(diagnostic Diagnostic, varName string) bool {
+	traversal := "var." + varName
+	if diagnostic.Snippet != nil {
+		for _, value := range diagnostic.Snippet.Values {
+			if value.Traversal == traversal {
+				return true
+			}
+		}
+		if strings.Contains(diagnostic.Snippet.Code, fmt.Sprintf("variable %q", varName)) {
+			return true
+		}
+	}
+	return diagnostic.Range != nil && strings.Contains(diagnostic.Range.Filename, traversal)
+}
+
+// isDiagnosticForCheck returns true if the given diagnostic refers to the check block with the given name.
+func isDiagnosticForCheck(diagnostic Diagnostic, checkName string) bool {
+	if diagnostic.Address == "check."+checkName {
+		return true
+	}
+	return diagnostic.Snippet != nil && diagnostic.Snippet.Context == fmt.Sprintf("check %q", checkName)
+}
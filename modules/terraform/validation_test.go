@@ -0,0 +1,140 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const preconditionFailedDiagnostics = `╷
+│ Error: Resource precondition failed
+│
+│   on main.tf line 36, in resource "null_resource" "example":
+│   36:       condition     = length(var.name) <= 10
+│     ├────────────────
+│     │ var.name is "a-very-long-name"
+│
+│ The name must be at most 10 characters long.
+╵
+`
+
+const checkBlockFailedOutput = `null_resource.example: Refreshing state... [id=123]
+
+No changes. Your infrastructure matches the configuration.
+╷
+│ Warning: Check block assertion failed
+│
+│   on main.tf line 44, in check "name_is_lowercase":
+│   44:     condition     = lower(var.name) == var.name
+│     ├────────────────
+│     │ var.name is "Example"
+│
+│ The name should be lowercase.
+╵
+`
+
+func TestPlanExpectValidationErrorE(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformBinary: writeFakeTerraformBinary(t, humanReadableDiagnostics),
+		TerraformDir:    t.TempDir(),
+	}
+
+	assert.NoError(t, PlanExpectValidationErrorE(t, options, "instance_type", "must be t3"))
+
+	err := PlanExpectValidationErrorE(t, options, "replicas", "must be t3")
+	require.Error(t, err)
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, err)
+	assert.Contains(t, err.Error(), "Error: Invalid value for variable (at main.tf:1): Instance type must be t3.*.")
+
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, PlanExpectValidationErrorE(t, options, "instance_type", "must be m5"))
+	assert.Error(t, PlanExpectValidationErrorE(t, options, "instance_type", "[invalid"))
+}
+
+func TestPlanExpectConditionErrorE(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		TerraformBinary: writeFakeTerraformBinary(t, preconditionFailedDiagnostics),
+		TerraformDir:    t.TempDir(),
+	}
+
+	assert.NoError(t, PlanExpectConditionErrorE(t, options, "", "at most 10 characters"))
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, PlanExpectConditionErrorE(t, options, "", "lowercase"))
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, PlanExpectValidationErrorE(t, options, "name", "at most 10 characters"))
+}
+
+func TestCheckBlockFailed(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkBlockFailedE(checkBlockFailedOutput, "name_is_lowercase", "should be lowercase"))
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, checkBlockFailedE(checkBlockFailedOutput, "other_check", "should be lowercase"))
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, checkBlockFailedE(checkBlockFailedOutput, "name_is_lowercase", "must be uppercase"))
+	assert.IsType(t, ExpectedDiagnosticNotFound{}, checkBlockFailedE("No changes.", "name_is_lowercase", "should be lowercase"))
+}
+
+func TestPlanExpectValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-conditions", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+	}
+	Init(t, options)
+
+	PlanExpectValidationErrors(t, options, []ValidationErrorCase{
+		{
+			Name:         "non t3 instance type",
+			Vars:         map[string]interface{}{"instance_type": "m5.large"},
+			VarName:      "instance_type",
+			MessageRegex: "must be a t3 instance type",
+		},
+		{
+			Name:         "no replicas",
+			Vars:         map[string]interface{}{"replicas": 0},
+			VarName:      "replicas",
+			MessageRegex: "between 1 and 5",
+		},
+		{
+			Name:         "too many replicas",
+			Vars:         map[string]interface{}{"replicas": 6},
+			VarName:      "replicas",
+			MessageRegex: "between 1 and 5",
+		},
+	})
+}
+
+func TestPlanExpectConditionError(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-conditions", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars:         map[string]interface{}{"name": "a-very-long-name"},
+	}
+	Init(t, options)
+
+	PlanExpectConditionError(t, options, "null_resource.example", "at most 10 characters")
+}
+
+func TestAssertCheckBlockFailed(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-conditions", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars:         map[string]interface{}{"name": "Example"},
+	}
+	out := InitAndPlan(t, options)
+
+	AssertCheckBlockFailed(t, out, "name_is_lowercase", "should be lowercase")
+}
@@ -0,0 +1,66 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// VarsFilePlaceholder is the -var-file arg that FormatArgs uses for the vars when VarsAsFile is set. The vars are
+// written to a new temporary file each time a command runs, and the placeholder is replaced with the path of that file.
+const VarsFilePlaceholder = "terratest-vars.auto.tfvars.json"
+
+// formatVarsAsArgs formats the vars of the given options as command-line args for Terraform. If VarsAsFile is set,
+// this is a -var-file arg pointing at VarsFilePlaceholder, and the -var args otherwise (as well as if the vars can't
+// be serialized to json).
+func formatVarsAsArgs(options *Options) []string {
+	if options.VarsAsFile && len(options.Vars) > 0 {
+		if _, err := json.Marshal(options.Vars); err == nil {
+			return []string{"-var-file", VarsFilePlaceholder}
+		}
+	}
+	return FormatTerraformVarsAsArgs(options.Vars)
+}
+
+// prepareVarsFileE writes the vars of the given options to a new temporary file if the given args point at
+// VarsFilePlaceholder, and returns the args pointing at that file instead, along with a function that removes the
+// file once the command is done.
+func prepareVarsFileE(options *Options, args []string) ([]string, func(), error) {
+	noop := func() {}
+	if !options.VarsAsFile || len(options.Vars) == 0 {
+		return args, noop, nil
+	}
+	placeholderIndex := -1
+	for i := 1; i < len(args); i++ {
+		if args[i-1] == "-var-file" && args[i] == VarsFilePlaceholder {
+			placeholderIndex = i
+			break
+		}
+	}
+	if placeholderIndex < 0 {
+		return args, noop, nil
+	}
+
+	content, err := json.MarshalIndent(options.Vars, "", "  ")
+	if err != nil {
+		return args, noop, err
+	}
+	// TempFile creates a new file that is only readable by the current user, as vars often hold secrets.
+	file, err := ioutil.TempFile("", "terratest-vars-*.auto.tfvars.json")
+	if err != nil {
+		return args, noop, err
+	}
+	release := func() { os.Remove(file.Name()) }
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		release()
+		return args, noop, err
+	}
+
+	fileArgs := append([]string{}, args...)
+	fileArgs[placeholderIndex] = file.Name()
+	return fileArgs, release, nil
+}
@@ -0,0 +1,77 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatArgsWithVarsAsFile(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		Vars:       map[string]interface{}{"name": "terratest", "tags": nil},
+		VarFiles:   []string{"test.tfvars"},
+		VarsAsFile: true,
+	}
+	assert.Equal(t, []string{"plan", "-var-file", VarsFilePlaceholder, "-var-file", "test.tfvars", "-lock=false"}, FormatArgs(options, "plan"))
+
+	options.SetVarsAfterVarFiles = true
+	assert.Equal(t, []string{"plan", "-var-file", "test.tfvars", "-var-file", VarsFilePlaceholder, "-lock=false"}, FormatArgs(options, "plan"))
+}
+
+func TestVarsAsFileWrittenForCommandAndRemoved(t *testing.T) {
+	t.Parallel()
+
+	// The fake binary prints the path, permissions and content of each var file it is passed.
+	binaryPath := filepath.Join(t.TempDir(), "terraform")
+	script := "#!/bin/sh\nwhile [ $# -gt 0 ]; do\n  if [ \"$1\" = \"-var-file\" ]; then echo \"$2\"; ls -l \"$2\" | cut -c1-10; cat \"$2\"; fi\n  shift\ndone\n"
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+
+	options := &Options{
+		TerraformBinary: binaryPath,
+		TerraformDir:    t.TempDir(),
+		Vars: map[string]interface{}{
+			"foo": map[string]interface{}{"nullable_string": nil, "count": 3},
+		},
+		VarsAsFile: true,
+	}
+	out := RunTerraformCommand(t, options, FormatArgs(options, "plan")...)
+	lines := strings.SplitN(out, "\n", 3)
+	require.Len(t, lines, 3)
+	path := lines[0]
+	assert.Regexp(t, `terratest-vars-[0-9]+\.auto\.tfvars\.json$`, path)
+	assert.Equal(t, "-rw-------", lines[1])
+	assert.JSONEq(t, `{"foo": {"nullable_string": null, "count": 3}}`, lines[2])
+	assert.NoFileExists(t, path)
+
+	// Each command gets its own file.
+	otherOut := RunTerraformCommand(t, options, FormatArgs(options, "plan")...)
+	assert.NotEqual(t, path, strings.SplitN(otherOut, "\n", 2)[0])
+
+	// Commands that don't take vars don't write the file.
+	out = RunTerraformCommand(t, options, "output")
+	assert.Empty(t, out)
+}
+
+func TestVarsAsFileWithNull(t *testing.T) {
+	t.Parallel()
+
+	testFolder, err := files.CopyTerraformFolderToTemp("../../test/fixtures/terraform-null", t.Name())
+	require.NoError(t, err)
+
+	options := &Options{
+		TerraformDir: testFolder,
+		Vars: map[string]interface{}{
+			"foo": map[string]interface{}{"nullable_string": nil, "nonnullable_string": t.Name()},
+		},
+		VarsAsFile: true,
+	}
+	InitAndApply(t, options)
+	assert.Equal(t, "I AM NULL", Output(t, options, "bar"))
+}
@@ -0,0 +1,291 @@
+package test_structure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	go_test "testing"
+	"text/tabwriter"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// MatrixAxis is a dimension of a Matrix, such as the regions, versions, or values of a feature flag to test a module
+// with.
+type MatrixAxis struct {
+	Name   string
+	Values []interface{}
+}
+
+// Matrix is a set of test cases, one for each combination of the values of its axes, that RunMatrix runs as subtests.
+type Matrix struct {
+	Axes []MatrixAxis
+
+	// Instead of every combination of values, only run enough combinations to cover every pair of values of any two
+	// axes. This catches most of the bugs caused by interactions between settings with far fewer test cases.
+	Pairwise bool
+
+	// Run the cells in parallel, at most MaxParallel at a time (if MaxParallel is not 0), and at most as many as the
+	// -parallel flag of go test allows.
+	Parallel    bool
+	MaxParallel int
+
+	// If set, each cell gets its own copy of the given module folder within the given root folder, made with
+	// CopyTerraformFolderToTemp, in MatrixCell.TerraformDir.
+	RootFolder            string
+	TerraformModuleFolder string
+}
+
+// MatrixCell is a combination of the values of the axes of a Matrix.
+type MatrixCell struct {
+	// The name of the subtest of the cell (e.g., region=us-east-1,version=1.5).
+	Name string
+
+	// The value of each axis, by axis name.
+	Values map[string]interface{}
+
+	// The copy of the module folder of the cell, if the matrix has a TerraformModuleFolder.
+	TerraformDir string
+}
+
+// Value returns the value of the given axis in the cell.
+func (cell MatrixCell) Value(axis string) interface{} {
+	return cell.Values[axis]
+}
+
+// String returns the value of the given axis in the cell, formatted as a string.
+func (cell MatrixCell) String(axis string) string {
+	return fmt.Sprint(cell.Values[axis])
+}
+
+// MatrixResult is the result of running the test of a cell.
+type MatrixResult struct {
+	Cell    MatrixCell
+	Passed  bool
+	Skipped bool
+}
+
+// Cells returns the cells of the matrix: every combination of the values of its axes, ordered by the first axis, then
+// the second one, and so on, or only enough of them to cover every pair of values if Pairwise is set. This fails the
+// test if the axes are invalid (see CellsE).
+func (matrix *Matrix) Cells(t testing.TestingT) []MatrixCell {
+	cells, err := matrix.CellsE()
+	require.NoError(t, err)
+	return cells
+}
+
+// CellsE returns the cells of the matrix: every combination of the values of its axes, ordered by the first axis,
+// then the second one, and so on, or only enough of them to cover every pair of values if Pairwise is set. This returns
+// an error if an axis has no values, as there would be no combinations to run, or if two axes have the same name, as
+// their values would overwrite each other in the cells. A matrix without axes is an error too.
+func (matrix *Matrix) CellsE() ([]MatrixCell, error) {
+	if len(matrix.Axes) == 0 {
+		return nil, errors.New("The matrix has no axes")
+	}
+	names := map[string]bool{}
+	for _, axis := range matrix.Axes {
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("Axis '%s' of the matrix has no values", axis.Name)
+		}
+		if names[axis.Name] {
+			return nil, fmt.Errorf("The matrix has more than one axis named '%s'", axis.Name)
+		}
+		names[axis.Name] = true
+	}
+
+	var combinations [][]int
+	if matrix.Pairwise && len(matrix.Axes) > 2 {
+		combinations = matrix.pairwiseCombinations()
+	} else {
+		combinations = matrix.allCombinations()
+	}
+
+	cells := make([]MatrixCell, 0, len(combinations))
+	for _, combination := range combinations {
+		cell := MatrixCell{Values: map[string]interface{}{}}
+		nameParts := make([]string, 0, len(matrix.Axes))
+		for axisIndex, valueIndex := range combination {
+			axis := matrix.Axes[axisIndex]
+			cell.Values[axis.Name] = axis.Values[valueIndex]
+			nameParts = append(nameParts, fmt.Sprintf("%s=%v", axis.Name, axis.Values[valueIndex]))
+		}
+		cell.Name = strings.Join(nameParts, ",")
+		cells = append(cells, cell)
+	}
+	return cells, nil
+}
+
+// allCombinations returns every combination of values of the axes, as the index of the value of each axis.
+func (matrix *Matrix) allCombinations() [][]int {
+	combinations := [][]int{{}}
+	for _, axis := range matrix.Axes {
+		var next [][]int
+		for _, combination := range combinations {
+			for valueIndex := range axis.Values {
+				next = append(next, append(append([]int{}, combination...), valueIndex))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// matrixPair is a pair of values of two axes of a matrix, as the index of each axis and of its value.
+type matrixPair struct{ axis1, value1, axis2, value2 int }
+
+// pairwiseCombinations builds combinations of values of the axes until every pair of values of any two axes is
+// covered, without going through every combination, which would take exponential time for large matrices. Each
+// combination starts from the value that is in the most uncovered pairs, then gets the value of each of the other
+// axes, in order, that covers the most uncovered pairs with the values it already has (the first one, on ties). As the
+// first value is in an uncovered pair, every combination covers at least one more pair.
+func (matrix *Matrix) pairwiseCombinations() [][]int {
+	uncovered := map[matrixPair]bool{}
+	for axis1 := range matrix.Axes {
+		for axis2 := axis1 + 1; axis2 < len(matrix.Axes); axis2++ {
+			for value1 := range matrix.Axes[axis1].Values {
+				for value2 := range matrix.Axes[axis2].Values {
+					uncovered[matrixPair{axis1, value1, axis2, value2}] = true
+				}
+			}
+		}
+	}
+	// pairOf returns the pair of the given values of the given axes, with the axes in order.
+	pairOf := func(axis1, value1, axis2, value2 int) matrixPair {
+		if axis1 > axis2 {
+			return matrixPair{axis2, value2, axis1, value1}
+		}
+		return matrixPair{axis1, value1, axis2, value2}
+	}
+
+	var combinations [][]int
+	for len(uncovered) > 0 {
+		firstAxis, firstValue, firstCount := 0, 0, 0
+		for axis := range matrix.Axes {
+			for value := range matrix.Axes[axis].Values {
+				count := 0
+				for pair := range uncovered {
+					if (pair.axis1 == axis && pair.value1 == value) || (pair.axis2 == axis && pair.value2 == value) {
+						count++
+					}
+				}
+				if count > firstCount {
+					firstAxis, firstValue, firstCount = axis, value, count
+				}
+			}
+		}
+
+		combination := make([]int, len(matrix.Axes))
+		assigned := map[int]bool{firstAxis: true}
+		combination[firstAxis] = firstValue
+		for axis := range matrix.Axes {
+			if assigned[axis] {
+				continue
+			}
+			bestValue, bestCount := 0, -1
+			for value := range matrix.Axes[axis].Values {
+				count := 0
+				for assignedAxis := range assigned {
+					if uncovered[pairOf(assignedAxis, combination[assignedAxis], axis, value)] {
+						count++
+					}
+				}
+				if count > bestCount {
+					bestValue, bestCount = value, count
+				}
+			}
+			combination[axis] = bestValue
+			assigned[axis] = true
+		}
+
+		for axis1 := range combination {
+			for axis2 := axis1 + 1; axis2 < len(combination); axis2++ {
+				delete(uncovered, matrixPair{axis1, combination[axis1], axis2, combination[axis2]})
+			}
+		}
+		combinations = append(combinations, combination)
+	}
+	return combinations
+}
+
+// RunMatrix runs the given test as a subtest for each cell of the given matrix, and logs a summary table of the
+// results of the cells once they have all run. Only the results of the cells that ran are returned, so cells that the
+// -run flag of go test filters out are left out. Note that go_test is an alias to Golang's native testing package, as
+// t.Run is not part of Terratest's testing.TestingT.
+func RunMatrix(t *go_test.T, matrix *Matrix, test func(t *go_test.T, cell MatrixCell)) []MatrixResult {
+	cells := matrix.Cells(t)
+	results := make([]*MatrixResult, len(cells))
+
+	var semaphore chan struct{}
+	if matrix.Parallel && matrix.MaxParallel > 0 {
+		semaphore = make(chan struct{}, matrix.MaxParallel)
+	}
+	var mutex sync.Mutex
+
+	// Parallel subtests only run once their parent test function returns, so the cells are grouped in a subtest
+	// that returns once they have all run.
+	t.Run("matrix", func(t *go_test.T) {
+		for i, cell := range cells {
+			i, cell := i, cell
+			t.Run(cell.Name, func(t *go_test.T) {
+				defer func() {
+					mutex.Lock()
+					defer mutex.Unlock()
+					results[i] = &MatrixResult{Cell: cell, Passed: !t.Failed(), Skipped: t.Skipped()}
+				}()
+				if matrix.Parallel {
+					t.Parallel()
+				}
+				if semaphore != nil {
+					semaphore <- struct{}{}
+					defer func() { <-semaphore }()
+				}
+				if matrix.TerraformModuleFolder != "" {
+					cell.TerraformDir = CopyTerraformFolderToTemp(t, matrix.RootFolder, matrix.TerraformModuleFolder)
+				}
+				test(t, cell)
+			})
+		}
+	})
+
+	// t.Run doesn't call the test of the cells that -run filters out, which leaves their results unset.
+	ranResults := []MatrixResult{}
+	for _, result := range results {
+		if result != nil {
+			ranResults = append(ranResults, *result)
+		}
+	}
+	logger.Logf(t, "Results of the test matrix:\n%s", formatMatrixResults(matrix, ranResults))
+	return ranResults
+}
+
+// formatMatrixResults renders the given results as a table, with a column for each axis.
+func formatMatrixResults(matrix *Matrix, results []MatrixResult) string {
+	var out bytes.Buffer
+	writer := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	header := make([]string, 0, len(matrix.Axes)+1)
+	for _, axis := range matrix.Axes {
+		header = append(header, strings.ToUpper(axis.Name))
+	}
+	fmt.Fprintln(writer, strings.Join(append(header, "RESULT"), "\t"))
+
+	for _, result := range results {
+		row := make([]string, 0, len(matrix.Axes)+1)
+		for _, axis := range matrix.Axes {
+			row = append(row, result.Cell.String(axis.Name))
+		}
+		status := "FAIL"
+		switch {
+		case result.Skipped:
+			status = "SKIP"
+		case result.Passed:
+			status = "PASS"
+		}
+		fmt.Fprintln(writer, strings.Join(append(row, status), "\t"))
+	}
+	writer.Flush()
+	return out.String()
+}
@@ -0,0 +1,208 @@
+package test_structure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixCells(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Matrix{Axes: []MatrixAxis{
+		{Name: "region", Values: []interface{}{"us-east-1", "eu-west-1"}},
+		{Name: "version", Values: []interface{}{"1.4", "1.5", "1.6"}},
+	}}
+	cells := matrix.Cells(t)
+	require.Len(t, cells, 6)
+	assert.Equal(t, "region=us-east-1,version=1.4", cells[0].Name)
+	assert.Equal(t, "region=us-east-1,version=1.5", cells[1].Name)
+	assert.Equal(t, "region=eu-west-1,version=1.6", cells[5].Name)
+	assert.Equal(t, "eu-west-1", cells[5].Value("region"))
+	assert.Equal(t, "1.6", cells[5].String("version"))
+}
+
+func TestMatrixPairwiseCellsCoverAllPairs(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Matrix{Pairwise: true, Axes: []MatrixAxis{
+		{Name: "region", Values: []interface{}{"us-east-1", "eu-west-1", "ap-south-1"}},
+		{Name: "version", Values: []interface{}{"1.4", "1.5", "1.6"}},
+		{Name: "encrypted", Values: []interface{}{true, false}},
+		{Name: "multi_az", Values: []interface{}{true, false}},
+	}}
+	cells := matrix.Cells(t)
+	assert.Less(t, len(cells), 3*3*2*2)
+
+	for i, axis1 := range matrix.Axes {
+		for _, axis2 := range matrix.Axes[i+1:] {
+			for _, value1 := range axis1.Values {
+				for _, value2 := range axis2.Values {
+					covered := false
+					for _, cell := range cells {
+						if cell.Value(axis1.Name) == value1 && cell.Value(axis2.Name) == value2 {
+							covered = true
+						}
+					}
+					assert.True(t, covered, "%s=%v,%s=%v not covered", axis1.Name, value1, axis2.Name, value2)
+				}
+			}
+		}
+	}
+}
+
+func TestMatrixCellsRejectsInvalidAxes(t *testing.T) {
+	t.Parallel()
+
+	for _, pairwise := range []bool{false, true} {
+		matrix := &Matrix{Pairwise: pairwise, Axes: []MatrixAxis{
+			{Name: "region", Values: []interface{}{"us-east-1", "eu-west-1"}},
+			{Name: "version", Values: []interface{}{}},
+			{Name: "encrypted", Values: []interface{}{true, false}},
+		}}
+		_, err := matrix.CellsE()
+		assert.EqualError(t, err, "Axis 'version' of the matrix has no values")
+	}
+
+	matrix := &Matrix{Axes: []MatrixAxis{
+		{Name: "region", Values: []interface{}{"us-east-1"}},
+		{Name: "region", Values: []interface{}{"eu-west-1"}},
+	}}
+	_, err := matrix.CellsE()
+	assert.EqualError(t, err, "The matrix has more than one axis named 'region'")
+
+	_, err = (&Matrix{}).CellsE()
+	assert.EqualError(t, err, "The matrix has no axes")
+}
+
+func TestMatrixPairwiseCellsScaleToLargeMatrices(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Matrix{Pairwise: true}
+	for i := 0; i < 10; i++ {
+		matrix.Axes = append(matrix.Axes, MatrixAxis{Name: fmt.Sprintf("axis%d", i), Values: []interface{}{0, 1, 2, 3, 4}})
+	}
+	cells := matrix.Cells(t)
+	assert.Less(t, len(cells), 60)
+
+	covered := map[string]bool{}
+	for _, cell := range cells {
+		for i, axis1 := range matrix.Axes {
+			for _, axis2 := range matrix.Axes[i+1:] {
+				covered[fmt.Sprintf("%s=%v,%s=%v", axis1.Name, cell.Value(axis1.Name), axis2.Name, cell.Value(axis2.Name))] = true
+			}
+		}
+	}
+	assert.Len(t, covered, 45*5*5)
+}
+
+func TestRunMatrixRecordsResults(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Matrix{Axes: []MatrixAxis{
+		{Name: "region", Values: []interface{}{"us-east-1", "eu-west-1"}},
+		{Name: "encrypted", Values: []interface{}{true, false}},
+	}}
+	results := RunMatrix(t, matrix, func(t *testing.T, cell MatrixCell) {
+		if cell.Value("encrypted") == false {
+			t.Skip("unencrypted not supported")
+		}
+	})
+	require.Len(t, results, 4)
+	assert.Equal(t, "region=us-east-1,encrypted=true", results[0].Cell.Name)
+	assert.True(t, results[0].Passed)
+	assert.True(t, results[1].Skipped)
+
+	results[2].Passed = false
+	results[2].Skipped = false
+	assert.Equal(t,
+		"REGION     ENCRYPTED  RESULT\n"+
+			"us-east-1  true       PASS\n"+
+			"us-east-1  false      SKIP\n"+
+			"eu-west-1  true       FAIL\n"+
+			"eu-west-1  false      SKIP\n",
+		formatMatrixResults(matrix, results))
+}
+
+// runMatrixFilteredEnvVar is set when TestRunMatrixReturnsOnlyCellsThatRan runs itself with a -run flag that filters
+// out some of the cells.
+const runMatrixFilteredEnvVar = "TERRATEST_RUN_MATRIX_FILTERED"
+
+func TestRunMatrixReturnsOnlyCellsThatRan(t *testing.T) {
+	matrix := &Matrix{Axes: []MatrixAxis{
+		{Name: "region", Values: []interface{}{"us-east-1", "eu-west-1"}},
+		{Name: "encrypted", Values: []interface{}{true, false}},
+	}}
+	if os.Getenv(runMatrixFilteredEnvVar) != "" {
+		results := RunMatrix(t, matrix, func(t *testing.T, cell MatrixCell) {})
+		require.Len(t, results, 2)
+		assert.Equal(t, "region=eu-west-1,encrypted=true", results[0].Cell.Name)
+		assert.Equal(t, "region=eu-west-1,encrypted=false", results[1].Cell.Name)
+		assert.Equal(t,
+			"REGION     ENCRYPTED  RESULT\n"+
+				"eu-west-1  true       PASS\n"+
+				"eu-west-1  false      PASS\n",
+			formatMatrixResults(matrix, results))
+		return
+	}
+	t.Parallel()
+
+	cmd := exec.Command(os.Args[0], "-test.v", "-test.run", "^TestRunMatrixReturnsOnlyCellsThatRan$/^matrix$/region=eu-west-1")
+	cmd.Env = append(os.Environ(), runMatrixFilteredEnvVar+"=true")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "--- PASS: TestRunMatrixReturnsOnlyCellsThatRan/matrix/region=eu-west-1,encrypted=false")
+	assert.NotContains(t, string(out), "region=us-east-1")
+}
+
+func TestRunMatrixLimitsParallelism(t *testing.T) {
+	t.Parallel()
+
+	var running, maxRunning int32
+	var mutex sync.Mutex
+	matrix := &Matrix{Parallel: true, MaxParallel: 2, Axes: []MatrixAxis{
+		{Name: "index", Values: []interface{}{1, 2, 3, 4, 5, 6}},
+	}}
+	results := RunMatrix(t, matrix, func(t *testing.T, cell MatrixCell) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mutex.Lock()
+		if current > maxRunning {
+			maxRunning = current
+		}
+		mutex.Unlock()
+		time.Sleep(50 * time.Millisecond)
+	})
+	assert.Len(t, results, 6)
+	assert.LessOrEqual(t, maxRunning, int32(2))
+}
+
+func TestRunMatrixCopiesModulePerCell(t *testing.T) {
+	t.Parallel()
+
+	rootFolder := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootFolder, "main.tf"), []byte(""), 0644))
+
+	var mutex sync.Mutex
+	dirs := map[string]bool{}
+	matrix := &Matrix{RootFolder: rootFolder, TerraformModuleFolder: ".", Axes: []MatrixAxis{
+		{Name: "version", Values: []interface{}{"1.4", "1.5"}},
+	}}
+	RunMatrix(t, matrix, func(t *testing.T, cell MatrixCell) {
+		assert.NotEqual(t, rootFolder, cell.TerraformDir)
+		assert.FileExists(t, filepath.Join(cell.TerraformDir, "main.tf"))
+		mutex.Lock()
+		dirs[cell.TerraformDir] = true
+		mutex.Unlock()
+	})
+	assert.Len(t, dirs, 2)
+}
@@ -183,7 +183,8 @@ func FormatTestDataPath(testFolder string, filename string) string {
 }
 
 // SaveTestData serializes and saves a value used at test time to the given path. This allows you to create some sort of test data
-// (e.g., TerraformOptions) during setup and to reuse this data later during validation and teardown.
+// (e.g., TerraformOptions) during setup and to reuse this data later during validation and teardown. See Save for typed
+// test data that is namespaced per test, versioned, and can have encrypted secret fields.
 func SaveTestData(t testing.TestingT, path string, value interface{}) {
 	logger.Logf(t, "Storing test data in %s so it can be reused later", path)
 
@@ -0,0 +1,431 @@
+package test_structure
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// The environment variables that select the stages a StageRunner runs. Each of them can also be set with the test
+// flag of the same name in lower case, with dashes (e.g., go test -args -stage-only=validate), which takes precedence
+// over the environment variable. The flags are only available in test packages that call RegisterStageFlags.
+const (
+	// STAGE_ONLY_ENV_VAR is a comma separated list of the only stages to run (e.g., validate,teardown).
+	STAGE_ONLY_ENV_VAR = "STAGE_ONLY"
+
+	// STAGE_FROM_ENV_VAR is the first stage to run. The stages before it are skipped.
+	STAGE_FROM_ENV_VAR = "STAGE_FROM"
+
+	// STAGE_UNTIL_ENV_VAR is the last stage to run. The stages after it (including teardown stages) are skipped.
+	STAGE_UNTIL_ENV_VAR = "STAGE_UNTIL"
+)
+
+// The names of the test flags that select the stages a StageRunner runs.
+const (
+	stageOnlyFlagName  = "stage-only"
+	stageFromFlagName  = "stage-from"
+	stageUntilFlagName = "stage-until"
+)
+
+// RegisterStageFlags defines the -stage-only, -stage-from and -stage-until flags on the command line flags of the test
+// binary. Call it from TestMain, before flag.Parse, in test packages that want to select stages with flags as well as
+// with the STAGE_ONLY, STAGE_FROM and STAGE_UNTIL environment variables. The flags are not defined on import, so that
+// they can't clash with the flags of the test package.
+func RegisterStageFlags() {
+	flag.String(stageOnlyFlagName, "", "A comma separated list of the only test stages to run. Overrides the "+STAGE_ONLY_ENV_VAR+" environment variable.")
+	flag.String(stageFromFlagName, "", "The first test stage to run. Overrides the "+STAGE_FROM_ENV_VAR+" environment variable.")
+	flag.String(stageUntilFlagName, "", "The last test stage to run. Overrides the "+STAGE_UNTIL_ENV_VAR+" environment variable.")
+}
+
+// Stage is a named stage of a test (e.g., deploy, validate, teardown), run by a StageRunner.
+type Stage struct {
+	Name string
+
+	// The stages that must have completed, in this run or in an earlier one, before this stage can run.
+	DependsOn []string
+
+	// Teardown stages run after all the other stages, even if one of them fails. They don't require the stages they
+	// depend on to have completed (so that they can clean up after a failed deploy), and once they complete, the
+	// stages they depend on are no longer recorded as completed.
+	Teardown bool
+
+	Run func(stage *StageContext)
+}
+
+// StageRecord is the state of a stage, which is saved in the .test-data folder so that it is available to later runs.
+type StageRecord struct {
+	Completed   bool                       `json:"completed"`
+	CompletedAt time.Time                  `json:"completedAt,omitempty"`
+	Outputs     map[string]json.RawMessage `json:"outputs,omitempty"`
+}
+
+// StageContext is passed to each stage, to save its outputs and load the outputs of the stages it depends on.
+type StageContext struct {
+	T    testing.TestingT
+	Name string
+
+	runner *StageRunner
+	record *StageRecord
+}
+
+// SaveOutput saves the given value as an output of the stage, so that later stages can load it with LoadOutput, even
+// in later runs. The output is saved immediately, so it is available to teardown stages if this stage fails.
+func (stage *StageContext) SaveOutput(name string, value interface{}) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		stage.T.Fatalf("Failed to convert output %s of stage %s to JSON: %v", name, stage.Name, err)
+	}
+	stage.record.Outputs[name] = bytes
+	stage.runner.saveRecord(stage.T, stage.Name, stage.record)
+}
+
+// LoadOutput loads the output with the given name saved by the given stage into value, which should be a pointer.
+// This fails the test if the stage did not save that output.
+func (stage *StageContext) LoadOutput(stageName string, name string, value interface{}) {
+	record := stage.runner.loadRecord(stage.T, stageName)
+	bytes, hasOutput := record.Outputs[name]
+	if !hasOutput {
+		stage.T.Fatalf("Stage %s did not save an output named %s", stageName, name)
+	}
+	if err := json.Unmarshal(bytes, value); err != nil {
+		stage.T.Fatalf("Failed to parse JSON for output %s of stage %s: %v", name, stageName, err)
+	}
+}
+
+// HasOutput returns true if the given stage saved an output with the given name.
+func (stage *StageContext) HasOutput(stageName string, name string) bool {
+	_, hasOutput := stage.runner.loadRecord(stage.T, stageName).Outputs[name]
+	return hasOutput
+}
+
+// StageRunner runs the stages of a test in the order of their dependencies, recording the completion and outputs of
+// each stage in the .test-data folder of TestFolder. The stages to run can be selected with the STAGE_ONLY,
+// STAGE_FROM and STAGE_UNTIL environment variables (or the matching test flags), in which case a stage only runs if
+// the stages it depends on have completed, so that, e.g., rerunning a failed validate stage never deploys again by
+// accident. The SKIP_<stage> environment variables of RunTestStage are supported too.
+type StageRunner struct {
+	TestFolder string
+
+	// The stages to run. These default to the values of the environment variables and flags.
+	Only  []string
+	From  string
+	Until string
+
+	stages []*Stage
+}
+
+// NewStageRunner creates a StageRunner that saves the state of its stages in the .test-data folder of the given
+// folder, selecting the stages to run from the STAGE_ONLY, STAGE_FROM and STAGE_UNTIL environment variables or flags.
+func NewStageRunner(testFolder string) *StageRunner {
+	runner := &StageRunner{
+		TestFolder: testFolder,
+		From:       stageSelection(stageFromFlagName, STAGE_FROM_ENV_VAR),
+		Until:      stageSelection(stageUntilFlagName, STAGE_UNTIL_ENV_VAR),
+	}
+	if only := stageSelection(stageOnlyFlagName, STAGE_ONLY_ENV_VAR); only != "" {
+		for _, name := range strings.Split(only, ",") {
+			runner.Only = append(runner.Only, strings.TrimSpace(name))
+		}
+	}
+	return runner
+}
+
+// stageSelection returns the value of the given flag, or of the given environment variable if the flag is not defined
+// or not set.
+func stageSelection(flagName string, envVarName string) string {
+	if stageFlag := flag.Lookup(flagName); stageFlag != nil && stageFlag.Value.String() != "" {
+		return stageFlag.Value.String()
+	}
+	return os.Getenv(envVarName)
+}
+
+// AddStage adds a stage that runs after the given stages.
+func (runner *StageRunner) AddStage(name string, run func(stage *StageContext), dependsOn ...string) {
+	runner.stages = append(runner.stages, &Stage{Name: name, DependsOn: dependsOn, Run: run})
+}
+
+// AddTeardownStage adds a teardown stage, which runs after all the other stages, even if one of them fails. See
+// Stage.Teardown.
+func (runner *StageRunner) AddTeardownStage(name string, run func(stage *StageContext), dependsOn ...string) {
+	runner.stages = append(runner.stages, &Stage{Name: name, DependsOn: dependsOn, Teardown: true, Run: run})
+}
+
+// Run runs the selected stages. This fails the test if the stages are invalid (e.g., they depend on each other in a
+// cycle), or if a selected stage depends on a stage that has not completed.
+func (runner *StageRunner) Run(t testing.TestingT) {
+	ordered, err := runner.orderStages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := runner.selectStages(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Teardown stages are deferred in reverse, so that they run in order even if a stage calls t.FailNow.
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if stage := ordered[i]; stage.Teardown && selected[stage.Name] {
+			defer runner.runStage(t, stage)
+		}
+	}
+
+	for _, stage := range ordered {
+		if stage.Teardown {
+			continue
+		}
+		if !selected[stage.Name] {
+			logger.Logf(t, "Skipping stage '%s'.", stage.Name)
+			continue
+		}
+		for _, dependency := range stage.DependsOn {
+			if !runner.loadRecord(t, dependency).Completed {
+				t.Fatalf("Stage '%s' depends on stage '%s', which has not completed. Run stage '%s' first.", stage.Name, dependency, dependency)
+			}
+		}
+		runner.runStage(t, stage)
+	}
+}
+
+// runStage runs the given stage, recording it as completed if it returns without failing. The stage gets a T that
+// records its own failures, as the test may have failed before the stage ran (e.g., a teardown stage runs after a
+// failed deploy, or a stage runs after an earlier stage failed with t.Fail). Stages that fail the test through another
+// T (e.g., the t of the test function) are only caught if the test was passing before the stage ran.
+func (runner *StageRunner) runStage(t testing.TestingT, stage *Stage) {
+	logger.Logf(t, "Executing stage '%s'.", stage.Name)
+	failedBefore := testFailed(t)
+
+	record := runner.loadRecord(t, stage.Name)
+	record.Completed = false
+	record.CompletedAt = time.Time{}
+	runner.saveRecord(t, stage.Name, record)
+
+	stageT := &stageT{TestingT: t}
+	stage.Run(&StageContext{T: stageT, Name: stage.Name, runner: runner, record: record})
+
+	// Stages that fail with t.FailNow never get here, but those that fail with t.Fail do.
+	if stageT.failed || (!failedBefore && testFailed(t)) {
+		logger.Logf(t, "Stage '%s' failed.", stage.Name)
+		return
+	}
+	record.Completed = true
+	record.CompletedAt = time.Now()
+	runner.saveRecord(t, stage.Name, record)
+
+	if stage.Teardown {
+		for _, name := range runner.transitiveDependencies(stage) {
+			CleanupTestData(t, runner.recordPath(name))
+		}
+	}
+}
+
+// testFailed returns true if the given test has failed, if it can tell.
+func testFailed(t testing.TestingT) bool {
+	failed, canFail := t.(interface{ Failed() bool })
+	return canFail && failed.Failed()
+}
+
+// stageT is the T of a single stage. It fails the test like the T it wraps, but also records whether the stage itself
+// failed, which the T of the test can't tell once an earlier stage has failed.
+type stageT struct {
+	testing.TestingT
+	failed bool
+}
+
+func (t *stageT) Fail() {
+	t.failed = true
+	t.TestingT.Fail()
+}
+
+func (t *stageT) FailNow() {
+	t.failed = true
+	t.TestingT.FailNow()
+}
+
+func (t *stageT) Fatal(args ...interface{}) {
+	t.failed = true
+	t.TestingT.Fatal(args...)
+}
+
+func (t *stageT) Fatalf(format string, args ...interface{}) {
+	t.failed = true
+	t.TestingT.Fatalf(format, args...)
+}
+
+func (t *stageT) Error(args ...interface{}) {
+	t.failed = true
+	t.TestingT.Error(args...)
+}
+
+func (t *stageT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+	t.TestingT.Errorf(format, args...)
+}
+
+// Failed returns true if the stage failed, or if the test it runs in has failed.
+func (t *stageT) Failed() bool {
+	return t.failed || testFailed(t.TestingT)
+}
+
+// Helper marks the calling function as a test helper of the wrapped T, if it supports that.
+func (t *stageT) Helper() {
+	if helper, isHelper := t.TestingT.(interface{ Helper() }); isHelper {
+		helper.Helper()
+	}
+}
+
+// orderStages returns the stages sorted so that each stage comes after the stages it depends on, with teardown stages
+// last. Otherwise, the stages keep the order they were added in.
+func (runner *StageRunner) orderStages() ([]*Stage, error) {
+	byName := map[string]*Stage{}
+	for _, stage := range runner.stages {
+		if _, isDuplicate := byName[stage.Name]; isDuplicate {
+			return nil, fmt.Errorf("Stage '%s' was added more than once", stage.Name)
+		}
+		byName[stage.Name] = stage
+	}
+	for _, stage := range runner.stages {
+		for _, dependency := range stage.DependsOn {
+			dependencyStage, exists := byName[dependency]
+			if !exists {
+				return nil, fmt.Errorf("Stage '%s' depends on stage '%s', which does not exist", stage.Name, dependency)
+			}
+			if dependencyStage.Teardown && !stage.Teardown {
+				return nil, fmt.Errorf("Stage '%s' can't depend on teardown stage '%s'", stage.Name, dependency)
+			}
+		}
+	}
+
+	var ordered []*Stage
+	placed := map[string]bool{}
+	for len(ordered) < len(runner.stages) {
+		progressed := false
+		for _, teardown := range []bool{false, true} {
+			for _, stage := range runner.stages {
+				if placed[stage.Name] || stage.Teardown != teardown || !allPlaced(stage.DependsOn, placed) {
+					continue
+				}
+				ordered = append(ordered, stage)
+				placed[stage.Name] = true
+				progressed = true
+			}
+			if progressed {
+				break
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, stage := range runner.stages {
+				if !placed[stage.Name] {
+					cycle = append(cycle, stage.Name)
+				}
+			}
+			return nil, fmt.Errorf("Stages %v depend on each other in a cycle", cycle)
+		}
+	}
+	return ordered, nil
+}
+
+// allPlaced returns true if all the given stages have been placed.
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, name := range names {
+		if !placed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// selectStages returns the names of the given ordered stages that were selected to run.
+func (runner *StageRunner) selectStages(ordered []*Stage) (map[string]bool, error) {
+	index := map[string]int{}
+	for i, stage := range ordered {
+		index[stage.Name] = i
+	}
+	for _, name := range append([]string{runner.From, runner.Until}, runner.Only...) {
+		if _, exists := index[name]; name != "" && !exists {
+			return nil, fmt.Errorf("Stage '%s' was selected to run, but it does not exist", name)
+		}
+	}
+
+	only := map[string]bool{}
+	for _, name := range runner.Only {
+		only[name] = true
+	}
+	selected := map[string]bool{}
+	for i, stage := range ordered {
+		switch {
+		case len(only) > 0 && !only[stage.Name]:
+		case runner.From != "" && i < index[runner.From]:
+		case runner.Until != "" && i > index[runner.Until]:
+		case os.Getenv(SKIP_STAGE_ENV_VAR_PREFIX+stage.Name) != "":
+		default:
+			selected[stage.Name] = true
+		}
+	}
+	return selected, nil
+}
+
+// transitiveDependencies returns the names of the stages the given stage depends on, directly or indirectly.
+func (runner *StageRunner) transitiveDependencies(stage *Stage) []string {
+	byName := map[string]*Stage{}
+	for _, stage := range runner.stages {
+		byName[stage.Name] = stage
+	}
+	var out []string
+	seen := map[string]bool{}
+	queue := append([]string{}, stage.DependsOn...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+		queue = append(queue, byName[name].DependsOn...)
+	}
+	return out
+}
+
+// recordPath returns the path of the file the state of the given stage is saved in.
+func (runner *StageRunner) recordPath(stageName string) string {
+	return FormatTestDataPath(runner.TestFolder, fmt.Sprintf("stage-%s.json", stageName))
+}
+
+// loadRecord loads the state of the given stage, which is empty if the stage never ran.
+func (runner *StageRunner) loadRecord(t testing.TestingT, stageName string) *StageRecord {
+	record := &StageRecord{}
+	path := runner.recordPath(stageName)
+	if files.FileExists(path) {
+		LoadTestData(t, path, record)
+	}
+	if record.Outputs == nil {
+		record.Outputs = map[string]json.RawMessage{}
+	}
+	return record
+}
+
+// saveRecord saves the state of the given stage. Unlike SaveTestData, this does not warn about overwriting the
+// previous state, as stages always do.
+func (runner *StageRunner) saveRecord(t testing.TestingT, stageName string, record *StageRecord) {
+	path := runner.recordPath(stageName)
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Failed to convert the state of stage %s to JSON: %v", stageName, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Failed to create folder %s: %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, bytes, 0644); err != nil {
+		t.Fatalf("Failed to save the state of stage %s: %v", stageName, err)
+	}
+}
@@ -0,0 +1,225 @@
+package test_structure
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fatalT is a testing.TestingT that records the first fatal failure, and stops the goroutine like testing.T does. Errors
+// mark it as failed without failing the underlying test.
+type fatalT struct {
+	*testing.T
+	fatal  string
+	failed bool
+}
+
+func (t *fatalT) Fatal(args ...interface{}) {
+	t.fatal = fmt.Sprint(args...)
+	t.failed = true
+	runtime.Goexit()
+}
+
+func (t *fatalT) Fatalf(format string, args ...interface{}) {
+	t.fatal = fmt.Sprintf(format, args...)
+	t.failed = true
+	runtime.Goexit()
+}
+
+func (t *fatalT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+}
+
+func (t *fatalT) Failed() bool {
+	return t.failed
+}
+
+// captureFatal runs the given function in a new goroutine with a fatalT, and returns the fatal failure, if any.
+func captureFatal(t *testing.T, run func(fatal *fatalT)) string {
+	fatal := &fatalT{T: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(fatal)
+	}()
+	<-done
+	return fatal.fatal
+}
+
+// runWithFatalT runs the given runner with a fatalT, and returns the fatal failure, if any.
+func runWithFatalT(t *testing.T, runner *StageRunner) string {
+	return captureFatal(t, func(fatal *fatalT) { runner.Run(fatal) })
+}
+
+// newTestStageRunner returns a runner with deploy, validate and teardown stages, which record the stages that ran in
+// the returned list.
+func newTestStageRunner(testFolder string, ran *[]string) *StageRunner {
+	runner := &StageRunner{TestFolder: testFolder}
+	runner.AddTeardownStage("teardown", func(stage *StageContext) {
+		*ran = append(*ran, stage.Name)
+	}, "deploy")
+	runner.AddStage("validate", func(stage *StageContext) {
+		var url string
+		stage.LoadOutput("deploy", "url", &url)
+		*ran = append(*ran, stage.Name+" "+url)
+	}, "deploy")
+	runner.AddStage("deploy", func(stage *StageContext) {
+		stage.SaveOutput("url", "http://example.com")
+		*ran = append(*ran, stage.Name)
+	})
+	return runner
+}
+
+func TestStageRunnerRunsStagesInOrder(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	var ran []string
+	newTestStageRunner(testFolder, &ran).Run(t)
+	assert.Equal(t, []string{"deploy", "validate http://example.com", "teardown"}, ran)
+
+	// The teardown invalidates the deploy stage, but not its own record.
+	assert.False(t, files.FileExists(FormatTestDataPath(testFolder, "stage-deploy.json")))
+	assert.True(t, files.FileExists(FormatTestDataPath(testFolder, "stage-validate.json")))
+}
+
+func TestStageRunnerRerunsOnlySelectedStages(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	var ran []string
+	runner := newTestStageRunner(testFolder, &ran)
+	runner.Until = "validate"
+	runner.Run(t)
+	assert.Equal(t, []string{"deploy", "validate http://example.com"}, ran)
+
+	ran = nil
+	runner = newTestStageRunner(testFolder, &ran)
+	runner.Only = []string{"validate"}
+	runner.Run(t)
+	assert.Equal(t, []string{"validate http://example.com"}, ran)
+
+	ran = nil
+	runner = newTestStageRunner(testFolder, &ran)
+	runner.From = "validate"
+	runner.Run(t)
+	assert.Equal(t, []string{"validate http://example.com", "teardown"}, ran)
+}
+
+func TestStageRunnerRequiresCompletedDependencies(t *testing.T) {
+	t.Parallel()
+
+	var ran []string
+	runner := newTestStageRunner(t.TempDir(), &ran)
+	runner.Only = []string{"validate"}
+	assert.Equal(t, "Stage 'validate' depends on stage 'deploy', which has not completed. Run stage 'deploy' first.", runWithFatalT(t, runner))
+	assert.Empty(t, ran)
+}
+
+func TestStageRunnerRunsTeardownAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	var ran []string
+	runner := &StageRunner{TestFolder: testFolder}
+	runner.AddStage("deploy", func(stage *StageContext) {
+		stage.SaveOutput("id", 42)
+		stage.T.Fatalf("deploy failed")
+	})
+	runner.AddTeardownStage("teardown", func(stage *StageContext) {
+		var id int
+		stage.LoadOutput("deploy", "id", &id)
+		ran = append(ran, fmt.Sprintf("%s %d", stage.Name, id))
+	}, "deploy")
+	assert.Equal(t, "deploy failed", runWithFatalT(t, runner))
+	assert.Equal(t, []string{"teardown 42"}, ran)
+
+	record := runner.loadRecord(t, "teardown")
+	assert.True(t, record.Completed)
+}
+
+func TestStageRunnerRecordsStagesThatRunAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	runner := &StageRunner{TestFolder: testFolder}
+	runner.AddStage("deploy", func(stage *StageContext) {
+		stage.T.Errorf("deploy failed")
+	})
+	runner.AddStage("report", func(*StageContext) {})
+	runner.AddTeardownStage("teardown", func(*StageContext) {}, "deploy")
+	assert.Empty(t, runWithFatalT(t, runner))
+
+	assert.True(t, runner.loadRecord(t, "report").Completed)
+	assert.True(t, runner.loadRecord(t, "teardown").Completed)
+	assert.False(t, files.FileExists(FormatTestDataPath(testFolder, "stage-deploy.json")))
+
+	runner = &StageRunner{TestFolder: testFolder}
+	runner.AddStage("deploy", func(stage *StageContext) {
+		stage.T.Errorf("deploy failed")
+	})
+	assert.Empty(t, runWithFatalT(t, runner))
+	assert.False(t, runner.loadRecord(t, "deploy").Completed)
+}
+
+func TestStageRunnerRecordsEachFailedStage(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	runner := &StageRunner{TestFolder: testFolder}
+	runner.AddStage("deploy", func(stage *StageContext) {
+		stage.T.Errorf("deploy failed")
+	})
+	runner.AddStage("validate", func(stage *StageContext) {
+		stage.T.Errorf("validate failed")
+	})
+	runner.AddStage("report", func(*StageContext) {})
+	assert.Empty(t, runWithFatalT(t, runner))
+
+	assert.False(t, runner.loadRecord(t, "deploy").Completed)
+	assert.False(t, runner.loadRecord(t, "validate").Completed)
+	assert.True(t, runner.loadRecord(t, "report").Completed)
+}
+
+func TestStageRunnerRejectsInvalidStages(t *testing.T) {
+	t.Parallel()
+
+	runner := &StageRunner{TestFolder: t.TempDir()}
+	runner.AddStage("a", func(*StageContext) {}, "b")
+	runner.AddStage("b", func(*StageContext) {}, "a")
+	assert.Equal(t, "Stages [a b] depend on each other in a cycle", runWithFatalT(t, runner))
+
+	runner = &StageRunner{TestFolder: t.TempDir(), Only: []string{"deploy"}}
+	runner.AddStage("setup", func(*StageContext) {})
+	assert.Equal(t, "Stage 'deploy' was selected to run, but it does not exist", runWithFatalT(t, runner))
+
+	runner = &StageRunner{TestFolder: t.TempDir()}
+	runner.AddStage("validate", func(*StageContext) {}, "deploy")
+	require.Equal(t, "Stage 'validate' depends on stage 'deploy', which does not exist", runWithFatalT(t, runner))
+}
+
+func TestNewStageRunnerReadsEnvVars(t *testing.T) {
+	t.Setenv(STAGE_ONLY_ENV_VAR, "validate, teardown")
+	t.Setenv(STAGE_UNTIL_ENV_VAR, "teardown")
+
+	runner := NewStageRunner(t.TempDir())
+	assert.Equal(t, []string{"validate", "teardown"}, runner.Only)
+	assert.Equal(t, "", runner.From)
+	assert.Equal(t, "teardown", runner.Until)
+}
+
+func TestStageSelectionPrefersRegisteredFlags(t *testing.T) {
+	t.Setenv(STAGE_FROM_ENV_VAR, "deploy")
+	assert.Equal(t, "deploy", stageSelection(stageFromFlagName, STAGE_FROM_ENV_VAR))
+
+	RegisterStageFlags()
+	assert.Equal(t, "deploy", stageSelection(stageFromFlagName, STAGE_FROM_ENV_VAR))
+	require.NoError(t, flag.Set(stageFromFlagName, "validate"))
+	defer flag.Set(stageFromFlagName, "")
+	assert.Equal(t, "validate", NewStageRunner(t.TempDir()).From)
+}
@@ -0,0 +1,202 @@
+package test_structure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/require"
+)
+
+// TEARDOWN_LEDGER_ENV_VAR is the environment variable that sets the folder of the default teardown ledger.
+const TEARDOWN_LEDGER_ENV_VAR = "TERRATEST_TEARDOWN_LEDGER"
+
+// DefaultTeardownLedgerDir returns the folder of the default teardown ledger, which is the value of the
+// TERRATEST_TEARDOWN_LEDGER environment variable, or terratest-teardown-ledger in the temp folder if that is not set.
+// Note that the temp folder is not durable: it is often cleared on reboot, and CI runners usually throw it away with
+// the machine, along with the record of the modules left to destroy. Set TERRATEST_TEARDOWN_LEDGER to a folder that
+// outlives the test run (e.g., a folder in the repo, or on a persistent volume of the CI runner) for the ledger to
+// survive the crashes it is meant for.
+func DefaultTeardownLedgerDir() string {
+	if dir := os.Getenv(TEARDOWN_LEDGER_ENV_VAR); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "terratest-teardown-ledger")
+}
+
+// TeardownLedger is a record, on disk, of the terraform modules that tests applied and did not destroy yet. Tests
+// record their TerraformOptions in the ledger before apply, and remove them once destroy succeeds, so that when a test
+// is killed (e.g., by go test -timeout) or crashes before it destroys its resources, the resources can still be
+// destroyed later with Replay (or the terratest_teardown command). For example:
+//
+//	ledger := test_structure.NewTeardownLedger(test_structure.DefaultTeardownLedgerDir())
+//	entryID := ledger.Record(t, terraformOptions)
+//	defer ledger.Destroy(t, entryID)
+//	terraform.InitAndApply(t, terraformOptions)
+//
+// The ledger is only as durable as its folder, so see DefaultTeardownLedgerDir for where to keep it. Note that the
+// TerraformDir of the options must still exist when the entry is replayed, so it should not be a folder that is
+// removed when the test ends.
+type TeardownLedger struct {
+	Dir string
+}
+
+// TeardownEntry is a terraform module recorded in a TeardownLedger.
+type TeardownEntry struct {
+	ID           string
+	TestName     string
+	TerraformDir string
+	RecordedAt   time.Time
+
+	// The number of times destroying the module failed, and the error of the last failure.
+	FailedDestroys int
+	LastError      string
+}
+
+// NewTeardownLedger creates a ledger that keeps its entries in the given folder.
+func NewTeardownLedger(dir string) *TeardownLedger {
+	return &TeardownLedger{Dir: dir}
+}
+
+var unsafeEntryIDCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Record records the given options in the ledger, and returns the ID of the new entry. Call this before apply, so
+// that the resources of a partial apply are destroyed too. The TerraformDir is recorded as an absolute path, so that
+// the entry can be replayed from any working directory.
+func (ledger *TeardownLedger) Record(t testing.TestingT, terraformOptions *terraform.Options) string {
+	terraformDir, err := filepath.Abs(terraformOptions.TerraformDir)
+	require.NoError(t, err)
+	recordedOptions, err := terraformOptions.Clone()
+	require.NoError(t, err)
+	recordedOptions.TerraformDir = terraformDir
+
+	entry := &TeardownEntry{
+		ID:           fmt.Sprintf("%s-%s", unsafeEntryIDCharsRegexp.ReplaceAllString(t.Name(), "_"), random.UniqueId()),
+		TestName:     t.Name(),
+		TerraformDir: terraformDir,
+		RecordedAt:   time.Now(),
+	}
+	SaveTerraformOptions(t, ledger.entryDir(entry.ID), recordedOptions)
+	ledger.saveEntry(t, entry)
+	logger.Logf(t, "Recorded %s in teardown ledger %s as entry %s", entry.TerraformDir, ledger.Dir, entry.ID)
+	return entry.ID
+}
+
+// Destroy runs terraform destroy with the options recorded in the given entry, and removes the entry from the ledger.
+// This will fail the test if destroy fails, in which case the entry is kept, so that destroy can be replayed.
+func (ledger *TeardownLedger) Destroy(t testing.TestingT, entryID string) {
+	require.NoError(t, ledger.DestroyE(t, entryID))
+}
+
+// DestroyE runs terraform destroy with the options recorded in the given entry, and removes the entry from the
+// ledger. If destroy fails, the entry is kept, so that destroy can be replayed.
+func (ledger *TeardownLedger) DestroyE(t testing.TestingT, entryID string) error {
+	entry := ledger.loadEntry(t, entryID)
+	terraformOptions := LoadTerraformOptions(t, ledger.entryDir(entryID))
+
+	if _, err := terraform.DestroyE(t, terraformOptions); err != nil {
+		entry.FailedDestroys++
+		entry.LastError = err.Error()
+		ledger.saveEntry(t, entry)
+		return err
+	}
+	return ledger.Remove(t, entryID)
+}
+
+// Remove removes the given entry from the ledger, without destroying anything.
+func (ledger *TeardownLedger) Remove(t testing.TestingT, entryID string) error {
+	logger.Logf(t, "Removing entry %s from teardown ledger %s", entryID, ledger.Dir)
+	return os.RemoveAll(ledger.entryDir(entryID))
+}
+
+// Entries returns the entries of the ledger, from the most recently recorded to the least recently recorded.
+func (ledger *TeardownLedger) Entries(t testing.TestingT) []*TeardownEntry {
+	entries, err := ledger.EntriesE(t)
+	require.NoError(t, err)
+	return entries
+}
+
+// EntriesE returns the entries of the ledger, from the most recently recorded to the least recently recorded.
+func (ledger *TeardownLedger) EntriesE(t testing.TestingT) ([]*TeardownEntry, error) {
+	dirs, err := ioutil.ReadDir(ledger.Dir)
+	if os.IsNotExist(err) {
+		return []*TeardownEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*TeardownEntry{}
+	for _, dir := range dirs {
+		// Skip the entries that are still being recorded.
+		if dir.IsDir() && files.FileExists(ledger.entryPath(dir.Name())) {
+			entries = append(entries, ledger.loadEntry(t, dir.Name()))
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].RecordedAt.After(entries[j].RecordedAt)
+	})
+	return entries, nil
+}
+
+// Replay runs terraform destroy for each entry of the ledger recorded more than olderThan ago, from the most recently
+// recorded to the least recently recorded, since later applies may depend on earlier ones. The entries that are
+// destroyed are removed from the ledger. Entries that were recorded more recently may belong to tests that are still
+// running, so they are skipped. This will fail the test if any destroy fails.
+func (ledger *TeardownLedger) Replay(t testing.TestingT, olderThan time.Duration) {
+	require.NoError(t, ledger.ReplayE(t, olderThan))
+}
+
+// ReplayE runs terraform destroy for each entry of the ledger recorded more than olderThan ago, from the most recently
+// recorded to the least recently recorded, since later applies may depend on earlier ones. The entries that are
+// destroyed are removed from the ledger. Entries that were recorded more recently may belong to tests that are still
+// running, so they are skipped. This keeps going if a destroy fails, and returns all the errors.
+func (ledger *TeardownLedger) ReplayE(t testing.TestingT, olderThan time.Duration) error {
+	entries, err := ledger.EntriesE(t)
+	if err != nil {
+		return err
+	}
+
+	var allErrs *multierror.Error
+	for _, entry := range entries {
+		if time.Since(entry.RecordedAt) < olderThan {
+			logger.Logf(t, "Skipping entry %s of teardown ledger %s, as it was recorded less than %s ago", entry.ID, ledger.Dir, olderThan)
+			continue
+		}
+		logger.Logf(t, "Replaying destroy of %s (recorded by %s at %s)", entry.TerraformDir, entry.TestName, entry.RecordedAt.Format(time.RFC3339))
+		if err := ledger.DestroyE(t, entry.ID); err != nil {
+			allErrs = multierror.Append(allErrs, fmt.Errorf("entry %s: %w", entry.ID, err))
+		}
+	}
+	return allErrs.ErrorOrNil()
+}
+
+// entryDir returns the folder of the given entry, in which SaveTerraformOptions saves the options of the entry.
+func (ledger *TeardownLedger) entryDir(entryID string) string {
+	return filepath.Join(ledger.Dir, entryID)
+}
+
+// entryPath returns the path of the file the given entry is saved in.
+func (ledger *TeardownLedger) entryPath(entryID string) string {
+	return FormatTestDataPath(ledger.entryDir(entryID), "TeardownEntry.json")
+}
+
+func (ledger *TeardownLedger) saveEntry(t testing.TestingT, entry *TeardownEntry) {
+	SaveTestData(t, ledger.entryPath(entry.ID), entry)
+}
+
+func (ledger *TeardownLedger) loadEntry(t testing.TestingT, entryID string) *TeardownEntry {
+	var entry TeardownEntry
+	LoadTestData(t, ledger.entryPath(entryID), &entry)
+	return &entry
+}
@@ -0,0 +1,111 @@
+package test_structure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeDestroyBinary writes a fake terraform binary that records the folder of each destroy in the returned log
+// file, and fails in folders that contain a file named fail.
+func writeFakeDestroyBinary(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "destroyed.log")
+	script := fmt.Sprintf("#!/bin/sh\nif [ -f fail ]; then echo 'Error: destroy failed' >&2; exit 1; fi\necho \"$1 $(basename \"$PWD\")\" >> %q\n", logPath)
+	binaryPath := filepath.Join(dir, "terraform")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte(script), 0755))
+	return binaryPath, logPath
+}
+
+func newLedgerTestModule(t *testing.T, binaryPath string, name string) *terraform.Options {
+	dir := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	return &terraform.Options{TerraformDir: dir, TerraformBinary: binaryPath, Vars: map[string]interface{}{"name": name}}
+}
+
+func readDestroyed(t *testing.T, logPath string) []string {
+	out, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestTeardownLedgerDestroyRemovesEntry(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakeDestroyBinary(t)
+	ledger := NewTeardownLedger(t.TempDir())
+
+	entryID := ledger.Record(t, newLedgerTestModule(t, binaryPath, "app"))
+	entries := ledger.Entries(t)
+	require.Len(t, entries, 1)
+	assert.Equal(t, entryID, entries[0].ID)
+	assert.Equal(t, t.Name(), entries[0].TestName)
+
+	ledger.Destroy(t, entryID)
+	assert.Equal(t, []string{"destroy app"}, readDestroyed(t, logPath))
+	assert.Empty(t, ledger.Entries(t))
+}
+
+func TestTeardownLedgerRecordsAbsoluteTerraformDir(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, _ := writeFakeDestroyBinary(t)
+	ledger := NewTeardownLedger(t.TempDir())
+	options := newLedgerTestModule(t, binaryPath, "app")
+	absDir := options.TerraformDir
+	workingDir, err := os.Getwd()
+	require.NoError(t, err)
+	options.TerraformDir, err = filepath.Rel(workingDir, absDir)
+	require.NoError(t, err)
+
+	entryID := ledger.Record(t, options)
+	assert.Equal(t, absDir, ledger.Entries(t)[0].TerraformDir)
+	assert.Equal(t, absDir, LoadTerraformOptions(t, ledger.entryDir(entryID)).TerraformDir)
+	assert.NotEqual(t, absDir, options.TerraformDir)
+}
+
+func TestTeardownLedgerReplay(t *testing.T) {
+	t.Parallel()
+
+	binaryPath, logPath := writeFakeDestroyBinary(t)
+	ledger := NewTeardownLedger(t.TempDir())
+
+	ledger.Record(t, newLedgerTestModule(t, binaryPath, "vpc"))
+	time.Sleep(10 * time.Millisecond)
+	failingOptions := newLedgerTestModule(t, binaryPath, "db")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(failingOptions.TerraformDir, "fail"), nil, 0644))
+	failingID := ledger.Record(t, failingOptions)
+	time.Sleep(10 * time.Millisecond)
+	ledger.Record(t, newLedgerTestModule(t, binaryPath, "app"))
+
+	// The entries are too recent to be replayed.
+	require.NoError(t, ledger.ReplayE(t, time.Hour))
+	assert.Len(t, ledger.Entries(t), 3)
+
+	err := ledger.ReplayE(t, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "entry "+failingID)
+	assert.Equal(t, []string{"destroy app", "destroy vpc"}, readDestroyed(t, logPath))
+
+	entries := ledger.Entries(t)
+	require.Len(t, entries, 1)
+	assert.Equal(t, failingID, entries[0].ID)
+	assert.Equal(t, 1, entries[0].FailedDestroys)
+	assert.Contains(t, entries[0].LastError, "destroy failed")
+}
+
+func TestTeardownLedgerEntriesOfMissingLedger(t *testing.T) {
+	t.Parallel()
+
+	ledger := NewTeardownLedger(filepath.Join(t.TempDir(), "does-not-exist"))
+	assert.Empty(t, ledger.Entries(t))
+	require.NoError(t, ledger.ReplayE(t, 0))
+}
@@ -20,7 +20,7 @@ import (
 const SKIP_STAGE_ENV_VAR_PREFIX = "SKIP_"
 
 // RunTestStage executes the given test stage (e.g., setup, teardown, validation) if an environment variable of the name
-// `SKIP_<stageName>` (e.g., SKIP_teardown) is not set.
+// `SKIP_<stageName>` (e.g., SKIP_teardown) is not set. See StageRunner for stages that depend on each other.
 func RunTestStage(t testing.TestingT, stageName string, stage func()) {
 	envVarName := fmt.Sprintf("%s%s", SKIP_STAGE_ENV_VAR_PREFIX, stageName)
 	if os.Getenv(envVarName) == "" {
@@ -0,0 +1,346 @@
+package test_structure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// TEST_DATA_KEY_ENV_VAR is the environment variable that sets the passphrase the secret fields of test data are
+// encrypted with. See TestDataStore.
+const TEST_DATA_KEY_ENV_VAR = "TERRATEST_TEST_DATA_KEY"
+
+// testDataFormatVersion is the version of the format of the files Save writes.
+const testDataFormatVersion = 1
+
+// encryptedValuePrefix marks the values of secret fields that are encrypted.
+const encryptedValuePrefix = "encrypted:v1:"
+
+// VersionedTestData can be implemented by the types saved with Save, to stamp the data with the version of their
+// schema. Load then fails if the data was saved with another version (e.g., by an older version of the test), instead
+// of silently loading zero values into new or renamed fields.
+type VersionedTestData interface {
+	TestDataSchemaVersion() int
+}
+
+// TestDataStore is where Save and Load keep typed test data: the .test-data folder of TestFolder, in a subfolder per
+// Namespace. The fields of structs tagged with `testdata:"secret"` are encrypted at rest (with AES-GCM) if
+// EncryptionKey is set, and saved in plain text otherwise. Only the top level fields of a struct can be secret.
+type TestDataStore struct {
+	TestFolder string
+
+	// The namespace of the data, which defaults to the name of the test, so that tests sharing a folder don't
+	// overwrite each other's data. Set the same namespace in several tests to share data between them.
+	Namespace string
+
+	// The 32 byte key secret fields are encrypted with. This defaults to the SHA-256 hash of the
+	// TERRATEST_TEST_DATA_KEY environment variable, if it is set.
+	EncryptionKey []byte
+}
+
+// TestDataEntry describes a value saved in a test folder.
+type TestDataEntry struct {
+	// The namespace and name of the value. The namespace is empty for values saved with SaveTestData and the other
+	// untyped functions, which are listed too.
+	Namespace string
+	Name      string
+
+	// The Go type, schema version, and time the value was saved with Save, and whether it has encrypted fields.
+	Type          string
+	SchemaVersion int
+	SavedAt       time.Time
+	Encrypted     bool
+
+	Path string
+}
+
+// testDataEnvelope is the format of the files Save writes.
+type testDataEnvelope struct {
+	FormatVersion int             `json:"formatVersion"`
+	SchemaVersion int             `json:"schemaVersion"`
+	Type          string          `json:"type"`
+	SavedAt       time.Time       `json:"savedAt"`
+	Encrypted     []string        `json:"encrypted,omitempty"`
+	Value         json.RawMessage `json:"value"`
+}
+
+var unsafeNamespaceCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// NewTestDataStore creates a store for the typed test data of the given test in the given folder.
+func NewTestDataStore(t testing.TestingT, testFolder string) *TestDataStore {
+	store := &TestDataStore{
+		TestFolder: testFolder,
+		Namespace:  unsafeNamespaceCharsRegexp.ReplaceAllString(t.Name(), "_"),
+	}
+	if passphrase := os.Getenv(TEST_DATA_KEY_ENV_VAR); passphrase != "" {
+		key := sha256.Sum256([]byte(passphrase))
+		store.EncryptionKey = key[:]
+	}
+	return store
+}
+
+// path returns the path of the file the value with the given name is saved in. The namespace and name are sanitized
+// like the namespace NewTestDataStore derives from the test name, so that they can't point outside of the .test-data
+// folder (e.g., a name of ../x is saved as .._x).
+func (store *TestDataStore) path(name string) string {
+	namespace := unsafeNamespaceCharsRegexp.ReplaceAllString(store.Namespace, "_")
+	name = unsafeNamespaceCharsRegexp.ReplaceAllString(name, "_")
+	return FormatTestDataPath(store.TestFolder, filepath.Join(namespace, fmt.Sprintf("%s.json", name)))
+}
+
+// Save saves the given value with the given name in the store, so that it can be loaded with Load in later test
+// stages. This fails the test if the value can't be saved.
+func Save[T any](t testing.TestingT, store *TestDataStore, name string, value T) {
+	path := store.path(name)
+	logger.Logf(t, "Storing test data %s in %s so it can be reused later", name, path)
+
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to convert test data %s to JSON: %v", name, err)
+	}
+	envelope := testDataEnvelope{
+		FormatVersion: testDataFormatVersion,
+		SchemaVersion: testDataSchemaVersion[T](),
+		Type:          reflect.TypeOf((*T)(nil)).Elem().String(),
+		SavedAt:       time.Now(),
+		Value:         valueJson,
+	}
+
+	if secretFields := secretJsonFields(reflect.TypeOf((*T)(nil)).Elem()); len(secretFields) > 0 {
+		if store.EncryptionKey == nil {
+			logger.Logf(t, "[WARNING] Test data %s has secret fields, but no encryption key is set, so they are saved in plain text. Set the %s environment variable to encrypt them.", name, TEST_DATA_KEY_ENV_VAR)
+		} else {
+			envelope.Value, envelope.Encrypted, err = encryptJsonFields(valueJson, secretFields, store.EncryptionKey)
+			if err != nil {
+				t.Fatalf("Failed to encrypt the secret fields of test data %s: %v", name, err)
+			}
+		}
+	}
+
+	bytes, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to convert test data %s to JSON: %v", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Failed to create folder %s: %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, bytes, 0600); err != nil {
+		t.Fatalf("Failed to save test data %s: %v", name, err)
+	}
+}
+
+// Load loads the value with the given name saved with Save in the store. This fails the test if there is no such
+// value, if it was saved with another schema version (see VersionedTestData), or if its secret fields can't be
+// decrypted.
+func Load[T any](t testing.TestingT, store *TestDataStore, name string) T {
+	path := store.path(name)
+	logger.Logf(t, "Loading test data %s from %s", name, path)
+
+	var value T
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load test data %s from %s: %v", name, path, err)
+	}
+	var envelope testDataEnvelope
+	if err := json.Unmarshal(bytes, &envelope); err != nil {
+		t.Fatalf("Failed to parse JSON for test data %s: %v", name, err)
+	}
+	if envelope.FormatVersion != testDataFormatVersion {
+		t.Fatalf("Test data %s was not saved with Save, or was saved with an unsupported format version (%d)", name, envelope.FormatVersion)
+	}
+	if expected := testDataSchemaVersion[T](); envelope.SchemaVersion != expected {
+		t.Fatalf("Test data %s was saved with schema version %d of %s, but the current schema version is %d. Run the test stage that saves it again.", name, envelope.SchemaVersion, envelope.Type, expected)
+	}
+
+	valueJson := []byte(envelope.Value)
+	if len(envelope.Encrypted) > 0 {
+		if store.EncryptionKey == nil {
+			t.Fatalf("Test data %s has encrypted fields, but no encryption key is set. Set the %s environment variable.", name, TEST_DATA_KEY_ENV_VAR)
+		}
+		valueJson, err = decryptJsonFields(valueJson, envelope.Encrypted, store.EncryptionKey)
+		if err != nil {
+			t.Fatalf("Failed to decrypt the secret fields of test data %s: %v", name, err)
+		}
+	}
+	if err := json.Unmarshal(valueJson, &value); err != nil {
+		t.Fatalf("Failed to parse JSON for test data %s: %v", name, err)
+	}
+	return value
+}
+
+// List returns the test data saved in the given test folder, in all namespaces, sorted by namespace and name. This
+// includes the values saved with SaveTestData and the other untyped functions.
+func List(t testing.TestingT, testFolder string) []TestDataEntry {
+	root := FormatTestDataPath(testFolder, "")
+	entries := []TestDataEntry{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entry := TestDataEntry{
+			Namespace: filepath.ToSlash(filepath.Dir(relPath)),
+			Name:      strings.TrimSuffix(filepath.Base(relPath), ".json"),
+			Path:      path,
+		}
+		if entry.Namespace == "." {
+			entry.Namespace = ""
+		}
+
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var envelope testDataEnvelope
+		if json.Unmarshal(bytes, &envelope) == nil && envelope.FormatVersion == testDataFormatVersion {
+			entry.Type = envelope.Type
+			entry.SchemaVersion = envelope.SchemaVersion
+			entry.SavedAt = envelope.SavedAt
+			entry.Encrypted = len(envelope.Encrypted) > 0
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to list the test data in %s: %v", root, err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// testDataSchemaVersion returns the schema version of T, if it implements VersionedTestData, and 0 otherwise.
+func testDataSchemaVersion[T any]() int {
+	var value T
+	if versioned, isVersioned := any(value).(VersionedTestData); isVersioned {
+		return versioned.TestDataSchemaVersion()
+	}
+	if versioned, isVersioned := any(&value).(VersionedTestData); isVersioned {
+		return versioned.TestDataSchemaVersion()
+	}
+	return 0
+}
+
+// secretJsonFields returns the JSON names of the top level fields of the given struct type (or pointer to struct type)
+// that are tagged with `testdata:"secret"`.
+func secretJsonFields(valueType reflect.Type) []string {
+	if valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	if valueType.Kind() != reflect.Struct {
+		return nil
+	}
+	var out []string
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.Tag.Get("testdata") != "secret" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// encryptJsonFields encrypts the given fields of the given JSON object with the given key, and returns the resulting
+// JSON object, along with the fields that were present (and so encrypted).
+func encryptJsonFields(valueJson []byte, fields []string, key []byte) (json.RawMessage, []string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(valueJson, &object); err != nil || object == nil {
+		// A nil pointer has no fields to encrypt.
+		return valueJson, nil, nil
+	}
+	gcm, err := newTestDataCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var encrypted []string
+	for _, field := range fields {
+		plaintext, hasField := object[field]
+		if !hasField {
+			continue
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, nil, err
+		}
+		ciphertext := gcm.Seal(nonce, nonce, plaintext, []byte(field))
+		object[field], err = json.Marshal(encryptedValuePrefix + base64.StdEncoding.EncodeToString(ciphertext))
+		if err != nil {
+			return nil, nil, err
+		}
+		encrypted = append(encrypted, field)
+	}
+	out, err := json.Marshal(object)
+	return out, encrypted, err
+}
+
+// decryptJsonFields decrypts the given fields of the given JSON object, encrypted by encryptJsonFields, with the given
+// key.
+func decryptJsonFields(valueJson []byte, fields []string, key []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(valueJson, &object); err != nil {
+		return nil, err
+	}
+	gcm, err := newTestDataCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		var encoded string
+		if err := json.Unmarshal(object[field], &encoded); err != nil || !strings.HasPrefix(encoded, encryptedValuePrefix) {
+			return nil, fmt.Errorf("field %s is not encrypted", field)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encoded, encryptedValuePrefix))
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphertext) < gcm.NonceSize() {
+			return nil, fmt.Errorf("field %s is too short to be encrypted", field)
+		}
+		plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], []byte(field))
+		if err != nil {
+			return nil, fmt.Errorf("field %s can't be decrypted with the given key: %w", field, err)
+		}
+		object[field] = plaintext
+	}
+	return json.Marshal(object)
+}
+
+func newTestDataCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
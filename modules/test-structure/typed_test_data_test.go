@@ -0,0 +1,124 @@
+package test_structure
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDatabase struct {
+	Endpoint string
+	Port     int
+	Password string `json:"password" testdata:"secret"`
+}
+
+type testVpcV2 struct {
+	ID      string
+	Subnets []string
+}
+
+func (testVpcV2) TestDataSchemaVersion() int { return 2 }
+
+type testVpcV3 testVpcV2
+
+func (testVpcV3) TestDataSchemaVersion() int { return 3 }
+
+func TestSaveAndLoadTyped(t *testing.T) {
+	t.Parallel()
+
+	store := NewTestDataStore(t, t.TempDir())
+	assert.Equal(t, "TestSaveAndLoadTyped", store.Namespace)
+
+	Save(t, store, "count", 3)
+	Save(t, store, "names", []string{"a", "b"})
+	Save(t, store, "vpc", testVpcV2{ID: "vpc-123", Subnets: []string{"subnet-1"}})
+	Save(t, store, "db", &testDatabase{Endpoint: "db.example.com", Port: 5432, Password: "hunter2"})
+
+	assert.Equal(t, 3, Load[int](t, store, "count"))
+	assert.Equal(t, []string{"a", "b"}, Load[[]string](t, store, "names"))
+	assert.Equal(t, testVpcV2{ID: "vpc-123", Subnets: []string{"subnet-1"}}, Load[testVpcV2](t, store, "vpc"))
+	assert.Equal(t, &testDatabase{Endpoint: "db.example.com", Port: 5432, Password: "hunter2"}, Load[*testDatabase](t, store, "db"))
+
+	// Stores with another namespace don't see the data.
+	other := &TestDataStore{TestFolder: store.TestFolder, Namespace: "other"}
+	assert.Contains(t, captureFatal(t, func(fatal *fatalT) { Load[int](fatal, other, "count") }), "Failed to load test data count")
+}
+
+func TestLoadTypedWithAnotherSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	store := NewTestDataStore(t, t.TempDir())
+	Save(t, store, "vpc", testVpcV2{ID: "vpc-123"})
+	assert.Equal(t,
+		"Test data vpc was saved with schema version 2 of test_structure.testVpcV2, but the current schema version is 3. Run the test stage that saves it again.",
+		captureFatal(t, func(fatal *fatalT) { Load[testVpcV3](fatal, store, "vpc") }))
+}
+
+func TestSaveTypedEncryptsSecretFields(t *testing.T) {
+	t.Parallel()
+
+	key := sha256.Sum256([]byte("passphrase"))
+	store := &TestDataStore{TestFolder: t.TempDir(), Namespace: "shared", EncryptionKey: key[:]}
+	Save(t, store, "db", testDatabase{Endpoint: "db.example.com", Password: "hunter2"})
+
+	bytes, err := ioutil.ReadFile(store.path("db"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(bytes), "hunter2")
+	assert.Contains(t, string(bytes), "db.example.com")
+	assert.Equal(t, "hunter2", Load[testDatabase](t, store, "db").Password)
+
+	wrongKey := sha256.Sum256([]byte("wrong"))
+	wrongStore := &TestDataStore{TestFolder: store.TestFolder, Namespace: "shared", EncryptionKey: wrongKey[:]}
+	assert.Contains(t, captureFatal(t, func(fatal *fatalT) { Load[testDatabase](fatal, wrongStore, "db") }), "can't be decrypted")
+
+	noKeyStore := &TestDataStore{TestFolder: store.TestFolder, Namespace: "shared"}
+	assert.Contains(t, captureFatal(t, func(fatal *fatalT) { Load[testDatabase](fatal, noKeyStore, "db") }), "no encryption key is set")
+}
+
+func TestSaveTypedKeepsDataInTestDataFolder(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	store := &TestDataStore{TestFolder: testFolder, Namespace: "../shared"}
+	Save(t, store, "../../vpc", testVpcV3{ID: "vpc-123"})
+
+	assert.Equal(t, FormatTestDataPath(testFolder, filepath.Join(".._shared", ".._.._vpc.json")), store.path("../../vpc"))
+	assert.Equal(t, "vpc-123", Load[testVpcV3](t, store, "../../vpc").ID)
+}
+
+func TestNewTestDataStoreReadsKeyFromEnv(t *testing.T) {
+	t.Setenv(TEST_DATA_KEY_ENV_VAR, "passphrase")
+
+	key := sha256.Sum256([]byte("passphrase"))
+	assert.Equal(t, key[:], NewTestDataStore(t, t.TempDir()).EncryptionKey)
+}
+
+func TestListTestData(t *testing.T) {
+	t.Parallel()
+
+	testFolder := t.TempDir()
+	assert.Empty(t, List(t, testFolder))
+
+	key := sha256.Sum256([]byte("passphrase"))
+	store := &TestDataStore{TestFolder: testFolder, Namespace: "deploy", EncryptionKey: key[:]}
+	Save(t, store, "vpc", testVpcV2{ID: "vpc-123"})
+	Save(t, store, "db", testDatabase{Password: "hunter2"})
+	SaveString(t, testFolder, "region", "us-east-1")
+
+	entries := List(t, testFolder)
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{"/region", "deploy/db", "deploy/vpc"}, []string{
+		entries[0].Namespace + "/" + entries[0].Name,
+		entries[1].Namespace + "/" + entries[1].Name,
+		entries[2].Namespace + "/" + entries[2].Name,
+	})
+	assert.Equal(t, "", entries[0].Type)
+	assert.True(t, entries[1].Encrypted)
+	assert.Equal(t, "test_structure.testVpcV2", entries[2].Type)
+	assert.Equal(t, 2, entries[2].SchemaVersion)
+	assert.False(t, entries[2].SavedAt.IsZero())
+}
@@ -0,0 +1,46 @@
+variable "instance_type" {
+  type    = string
+  default = "t3.micro"
+
+  validation {
+    condition     = can(regex("^t3\\.", var.instance_type))
+    error_message = "The instance_type must be a t3 instance type."
+  }
+}
+
+variable "replicas" {
+  type    = number
+  default = 1
+
+  validation {
+    condition     = var.replicas > 0 && var.replicas <= 5
+    error_message = "The number of replicas must be between 1 and 5."
+  }
+}
+
+variable "name" {
+  type    = string
+  default = "example"
+}
+
+resource "null_resource" "example" {
+  triggers = {
+    name          = var.name
+    instance_type = var.instance_type
+    replicas      = var.replicas
+  }
+
+  lifecycle {
+    precondition {
+      condition     = length(var.name) <= 10
+      error_message = "The name must be at most 10 characters long."
+    }
+  }
+}
+
+check "name_is_lowercase" {
+  assert {
+    condition     = lower(var.name) == var.name
+    error_message = "The name should be lowercase."
+  }
+}
@@ -0,0 +1,4 @@
+resource "local_file" "test" {
+  filename = "${path.module}/drift.txt"
+  content  = "managed by terraform"
+}
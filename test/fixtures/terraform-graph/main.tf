@@ -0,0 +1,19 @@
+resource "terraform_data" "kms_key" {
+  input = "terratest-key"
+}
+
+module "storage" {
+  source = "./modules/storage"
+
+  key_id = terraform_data.kms_key.output
+}
+
+module "app" {
+  source = "./modules/app"
+
+  bucket = module.storage.bucket
+}
+
+output "app" {
+  value = module.app.app
+}
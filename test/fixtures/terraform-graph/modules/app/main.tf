@@ -0,0 +1,11 @@
+variable "bucket" {
+  type = string
+}
+
+resource "terraform_data" "app" {
+  input = "app-using-${var.bucket}"
+}
+
+output "app" {
+  value = terraform_data.app.output
+}
@@ -0,0 +1,11 @@
+variable "key_id" {
+  type = string
+}
+
+resource "terraform_data" "bucket" {
+  input = "bucket-encrypted-with-${var.key_id}"
+}
+
+output "bucket" {
+  value = terraform_data.bucket.output
+}
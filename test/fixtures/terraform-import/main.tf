@@ -0,0 +1,4 @@
+resource "random_string" "test" {
+  length  = 8
+  special = false
+}
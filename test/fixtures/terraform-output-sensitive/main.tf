@@ -0,0 +1,19 @@
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+resource "null_resource" "test" {
+  triggers = {
+    password = var.password
+  }
+}
+
+output "password" {
+  value     = var.password
+  sensitive = true
+}
+
+output "username" {
+  value = "admin"
+}
@@ -0,0 +1,8 @@
+run "wrong_greeting" {
+  command = plan
+
+  assert {
+    condition     = output.greeting == "Goodbye, world!"
+    error_message = "The greeting is not a goodbye."
+  }
+}
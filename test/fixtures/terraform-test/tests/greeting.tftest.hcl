@@ -0,0 +1,21 @@
+run "default_greeting" {
+  command = plan
+
+  assert {
+    condition     = output.greeting == "Hello, world!"
+    error_message = "The default greeting is wrong."
+  }
+}
+
+run "custom_greeting" {
+  command = plan
+
+  variables {
+    name = "terratest"
+  }
+
+  assert {
+    condition     = output.greeting == "Hello, terratest!"
+    error_message = "The custom greeting is wrong."
+  }
+}
@@ -0,0 +1 @@
+# Empty: the unit uses the terraform code in its folder.